@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/jwt"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) adminListUsers(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query string
+		Role  string
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	input.Query = app.stringFromQuery(qs, "q", "")
+	input.Role = app.stringFromQuery(qs, "role", "")
+	input.Filters.Page = app.intFromQuery(qs, "page", 1)
+	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
+	input.Filters.Sort = app.stringFromQuery(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "email", "created", "-id", "-email", "-created"}
+
+	v := validator.New()
+
+	if input.Role != "" {
+		v.PermittedValue("role", input.Role, data.RoleUser, data.RoleAdmin)
+	}
+
+	if v.Exec(&input.Filters); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	users, metadata, err := app.models.Users.Search(input.Query, input.Role, input.Filters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "users": users})
+}
+
+func (app *application) adminGetUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := app.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	usage, err := app.models.Usage.ForUser(u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"user": u, "usage": usage})
+}
+
+func (app *application) adminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := app.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role *string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+
+	if input.Role != nil {
+		v.PermittedValue("role", *input.Role, data.RoleUser, data.RoleAdmin)
+	}
+
+	if !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	if input.Role != nil {
+		err = app.models.Users.SetRole(u.ID, *input.Role)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.recordAudit(r, &data.AuditEvent{Action: data.AuditUserUpdate, EntityType: "user", EntityID: u.ID},
+			responsePayload{"role": u.Role}, responsePayload{"role": *input.Role})
+
+		u.Role = *input.Role
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"user": u})
+}
+
+func (app *application) adminDisableUser(w http.ResponseWriter, r *http.Request) {
+	app.adminSetDisabled(w, r, true)
+}
+
+func (app *application) adminEnableUser(w http.ResponseWriter, r *http.Request) {
+	app.adminSetDisabled(w, r, false)
+}
+
+func (app *application) adminSetDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	claims, _ := app.claimsFromContext(r.Context())
+
+	u, ok := app.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if u.ID == claims.UserID {
+		app.badRequest(w, "you cannot disable your own account")
+		return
+	}
+
+	err := app.models.Users.SetDisabled(u.ID, disabled)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditUserUpdate, EntityType: "user", EntityID: u.ID},
+		responsePayload{"disabled": u.Disabled}, responsePayload{"disabled": disabled})
+
+	if disabled {
+		err = app.models.Tokens.DeleteForUser(data.ScopeRefresh, u.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.recordAudit(r, &data.AuditEvent{Action: data.AuditTokenRevoke, EntityType: "user", EntityID: u.ID},
+			nil, responsePayload{"scope": data.ScopeRefresh, "reason": "account_disabled"})
+	}
+
+	u.Disabled = disabled
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"user": u})
+}
+
+func (app *application) adminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := app.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Users.Unlock(u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditUserUpdate, EntityType: "user", EntityID: u.ID},
+		responsePayload{"locked_until": u.LockedUntil}, responsePayload{"locked_until": nil})
+
+	err = app.models.Tokens.DeleteForUser(data.ScopeUnlock, u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.models.LoginAttempts.DeleteForEmail(strings.ToLower(u.Email))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	u.LockedUntil = nil
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"user": u})
+}
+
+func (app *application) adminLogoutUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := app.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Tokens.DeleteForUser(data.ScopeRefresh, u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditTokenRevoke, EntityType: "user", EntityID: u.ID},
+		nil, responsePayload{"scope": data.ScopeRefresh, "reason": "admin_logout"})
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"message": "user successfully logged out"})
+}
+
+func (app *application) adminImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	claims, _ := app.claimsFromContext(r.Context())
+
+	u, ok := app.adminUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	dto := &data.CreateImpersonationDTO{}
+
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	if u.ID == claims.UserID || u.Role == data.RoleAdmin || u.Disabled {
+		app.forbidden(w)
+		return
+	}
+
+	exp := time.Now().Add(15 * time.Minute)
+
+	imp, err := app.models.Impersonations.Insert(claims.UserID, u.ID, dto.Reason, exp)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	token, err := app.jwtService.Sign(jwt.UserClaims{UserID: u.ID, Role: u.Role, ImpersonatorID: claims.UserID}, exp)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.infoLog.Printf("user %d started impersonating user %d: %s", claims.UserID, u.ID, dto.Reason)
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditUserImpersonate, EntityType: "user", EntityID: u.ID}, nil, imp)
+
+	app.writeJSON(w, http.StatusCreated, responsePayload{"access_token": token, "user": u, "impersonation": imp})
+}
+
+func (app *application) adminListImpersonations(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID int
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	input.UserID = app.intFromQuery(qs, "user_id", 0)
+	input.Filters.Page = app.intFromQuery(qs, "page", 1)
+	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
+	input.Filters.Sort = app.stringFromQuery(qs, "sort", "-created")
+	input.Filters.SortSafeList = []string{"id", "created", "-id", "-created"}
+
+	v := validator.New()
+	if v.Exec(&input.Filters); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	impersonations, metadata, err := app.models.Impersonations.GetAll(input.UserID, input.Filters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "impersonations": impersonations})
+}
+
+func (app *application) adminShowUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := app.models.Usage.Global()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"usage": usage})
+}
+
+func (app *application) adminUserFromPath(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return nil, false
+	}
+
+	u, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return u, true
+}
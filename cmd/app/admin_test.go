@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestAdminListUsers(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Admin user", "/admin/users", http.StatusOK, []byte("admin@example.com"), "admin"},
+		{"Search by role", "/admin/users?role=admin", http.StatusOK, []byte("admin@example.com"), "admin"},
+		{"Invalid role", "/admin/users?role=owner", http.StatusUnprocessableEntity, nil, "admin"},
+		{"Regular user", "/admin/users", http.StatusForbidden, nil, "123"},
+		{"Impersonating admin", "/admin/users", http.StatusForbidden, nil, "impersonated-admin"},
+		{"Invalid user", "/admin/users", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, r.Body)
+			}
+		})
+	}
+}
+
+func TestAdminGetUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid ID", "/admin/users/1", http.StatusOK, []byte("active_sessions"), "admin"},
+		{"Non-existent ID", "/admin/users/99", http.StatusNotFound, nil, "admin"},
+		{"Regular user", "/admin/users/1", http.StatusForbidden, nil, "123"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, r.Body)
+			}
+		})
+	}
+}
+
+func TestAdminUserActions(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		token    string
+	}{
+		{"Disable user", "/admin/users/1/disable", "", http.StatusOK, "admin"},
+		{"Disable self", "/admin/users/3/disable", "", http.StatusBadRequest, "admin"},
+		{"Enable user", "/admin/users/1/enable", "", http.StatusOK, "admin"},
+		{"Unlock user", "/admin/users/1/unlock", "", http.StatusOK, "admin"},
+		{"Force logout", "/admin/users/1/logout", "", http.StatusOK, "admin"},
+		{"Non-existent user", "/admin/users/99/logout", "", http.StatusNotFound, "admin"},
+		{"Regular user", "/admin/users/1/disable", "", http.StatusForbidden, "123"},
+		{"Impersonate user", "/admin/users/1/impersonate", `{"reason": "support ticket #42"}`, http.StatusCreated, "admin"},
+		{"Impersonate admin", "/admin/users/3/impersonate", `{"reason": "support ticket #42"}`, http.StatusForbidden, "admin"},
+		{"Impersonate without reason", "/admin/users/1/impersonate", `{"reason": ""}`, http.StatusUnprocessableEntity, "admin"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, tt.body, tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestAdminUpdateUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Promote user", `{"role": "admin"}`, http.StatusOK, []byte(`"role": "admin"`)},
+		{"Invalid role", `{"role": "owner"}`, http.StatusUnprocessableEntity, nil},
+	}
+	rm := getRequestMaker(app.routes(), "PATCH", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1/admin/users/2", tt.body, "admin")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, r.Body)
+			}
+		})
+	}
+}
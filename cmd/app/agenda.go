@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+type agendaDay struct {
+	Date  string       `json:"date"`
+	Tasks []*data.Task `json:"tasks"`
+}
+
+// agenda groups tasks by the days they take place on in TZ, so tasks that
+// span several days are listed on each. Overdue holds the unfinished tasks
+// that ended before From, and Undated the unfinished tasks without a date.
+// Each part is capped on its own, and Truncated, OverdueTruncated and
+// UndatedTruncated are set if it had more tasks than could be returned. Only
+// the most recent overdue tasks are kept.
+type agenda struct {
+	TZ               string       `json:"tz"`
+	From             string       `json:"from"`
+	To               string       `json:"to"`
+	Overdue          []*data.Task `json:"overdue"`
+	Days             []*agendaDay `json:"days"`
+	Undated          []*data.Task `json:"undated"`
+	Truncated        bool         `json:"truncated"`
+	OverdueTruncated bool         `json:"overdue_truncated"`
+	UndatedTruncated bool         `json:"undated_truncated"`
+}
+
+func (app *application) getAgenda(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	loc, err := app.userLocation(claims.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	loc = app.locationFromQuery(qs, "tz", loc, v)
+	from, to := app.dayRangeFromQuery(qs, loc, v)
+
+	if !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	end := to.AddDate(0, 0, 1)
+
+	tasks, err := app.models.Tasks.GetAgenda(claims.UserID, from, end)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	a := &agenda{
+		TZ:      loc.String(),
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		Overdue: tasks.Overdue,
+		Days:    []*agendaDay{},
+		Undated: tasks.Undated,
+	}
+
+	scheduled := tasks.Scheduled
+	if len(scheduled) > data.MaxAgendaTasks {
+		scheduled = scheduled[:data.MaxAgendaTasks]
+		a.Truncated = true
+	}
+
+	// The extra overdue task is the oldest, so it is dropped from the front.
+	if n := len(a.Overdue); n > data.MaxAgendaTasks {
+		a.Overdue = a.Overdue[n-data.MaxAgendaTasks:]
+		a.OverdueTruncated = true
+	}
+
+	if len(a.Undated) > data.MaxAgendaTasks {
+		a.Undated = a.Undated[:data.MaxAgendaTasks]
+		a.UndatedTruncated = true
+	}
+
+	var starts []time.Time
+	for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+		starts = append(starts, day)
+		a.Days = append(a.Days, &agendaDay{Date: day.Format("2006-01-02"), Tasks: []*data.Task{}})
+	}
+
+	for _, t := range scheduled {
+		start, end := taskSpan(t, loc)
+		for i, day := range starts {
+			if takesPlace(start, end, day, day.AddDate(0, 0, 1)) {
+				a.Days[i].Tasks = append(a.Days[i].Tasks, t)
+			}
+		}
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"agenda": a})
+}
+
+// taskSpan returns when a dated task starts and ends in loc. The end is
+// exclusive, and equal to the start for tasks that take place at an instant.
+func taskSpan(t *data.Task, loc *time.Location) (start, end time.Time) {
+	if !t.AllDay {
+		start = *t.Datetime
+		end = start
+		if t.EndDatetime != nil {
+			end = *t.EndDatetime
+		}
+		return start, end
+	}
+
+	// All-day tasks fall on dates, stored at midnight UTC.
+	y, m, d := t.Datetime.UTC().Date()
+	start = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	end = start.AddDate(0, 0, 1)
+	if t.EndDatetime != nil {
+		y, m, d = t.EndDatetime.UTC().Date()
+		end = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+
+	return start, end
+}
+
+// takesPlace reports whether a task from start to end takes place, at least in
+// part, on or after from and before to. A zero to has no limit.
+func takesPlace(start, end, from, to time.Time) bool {
+	if !to.IsZero() && !start.Before(to) {
+		return false
+	}
+
+	if start.Equal(end) {
+		return !start.Before(from)
+	}
+
+	return end.After(from)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGetAgenda(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Default range", "/users/me/agenda", http.StatusOK, []byte(`"tz": "UTC"`), "123"},
+		{"Time zone", "/users/me/agenda?from=2030-01-01&to=2030-01-03&tz=America/New_York", http.StatusOK, []byte(`"date": "2030-01-03"`), "123"},
+		{"Invalid time zone", "/users/me/agenda?tz=Mars/Olympus_Mons", http.StatusUnprocessableEntity, []byte("tz"), "123"},
+		{"Local time zone", "/users/me/agenda?tz=Local", http.StatusUnprocessableEntity, []byte("tz"), "123"},
+		{"Invalid date", "/users/me/agenda?from=01-01-2030", http.StatusUnprocessableEntity, []byte("from"), "123"},
+		{"Reversed range", "/users/me/agenda?from=2030-01-02&to=2030-01-01", http.StatusUnprocessableEntity, []byte("to"), "123"},
+		{"Range too long", "/users/me/agenda?from=2030-01-01&to=2030-06-01", http.StatusUnprocessableEntity, []byte("to"), "123"},
+		{"Invalid user", "/users/me/agenda", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestAgendaGrouping(t *testing.T) {
+	app := newTestApplication(t)
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	r := rm("/api/v1/users/me/agenda", "", "123")
+	if r.Code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, r.Code)
+	}
+
+	var res struct {
+		Agenda agenda `json:"agenda"`
+	}
+	if err := json.Unmarshal(r.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	// One mock task is due today, one takes all of today and tomorrow, and
+	// the other is undated.
+	a := res.Agenda
+	if len(a.Days) != defaultRangeDays {
+		t.Fatalf("want %d days; got %d", defaultRangeDays, len(a.Days))
+	}
+	if len(a.Days[0].Tasks) != 2 || len(a.Days[1].Tasks) != 1 || len(a.Days[2].Tasks) != 0 {
+		t.Errorf("want 2 tasks today, 1 tomorrow and none after; got %d, %d and %d", len(a.Days[0].Tasks), len(a.Days[1].Tasks), len(a.Days[2].Tasks))
+	}
+	if len(a.Undated) != 1 {
+		t.Errorf("want 1 undated task; got %d", len(a.Undated))
+	}
+	if len(a.Overdue) != 0 {
+		t.Errorf("want no overdue tasks; got %d", len(a.Overdue))
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/blob"
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+func (app *application) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.writableTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	maxSize := app.config.attachments.maxSize
+	quota := app.config.attachments.quota
+
+	used, err := app.models.Attachments.Used(claims.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if used >= quota {
+		app.payloadTooLarge(w, "attachment storage quota exceeded")
+		return
+	}
+
+	// Allow some headroom over the file size for the multipart envelope.
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			app.payloadTooLarge(w, fmt.Sprintf("attachments must not be larger than %d bytes", maxSize))
+			return
+		}
+		app.badRequest(w, "body must be a multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.badRequest(w, "body must contain a file field")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		app.payloadTooLarge(w, fmt.Sprintf("attachments must not be larger than %d bytes", maxSize))
+		return
+	}
+
+	if header.Size == 0 {
+		app.badRequest(w, "file must not be empty")
+		return
+	}
+
+	head := make([]byte, 512)
+
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		app.serverError(w, err)
+		return
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	key, err := attachmentKey(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	a := &data.Attachment{
+		TaskID:      t.ID,
+		UserID:      claims.UserID,
+		Filename:    sanitizeFilename(header.Filename),
+		ContentType: http.DetectContentType(head[:n]),
+		Size:        header.Size,
+		StorageKey:  key,
+	}
+
+	err = app.blobs.Put(r.Context(), a.StorageKey, file, a.Size, a.ContentType)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.models.Attachments.Insert(a, quota)
+	if err != nil {
+		if err := app.blobs.Delete(context.Background(), a.StorageKey); err != nil {
+			app.errorLog.Print(err)
+		}
+
+		switch {
+		case errors.Is(err, data.ErrQuotaExceeded):
+			app.payloadTooLarge(w, "attachment storage quota exceeded")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.afterRollback(func() {
+		if err := app.blobs.Delete(context.Background(), a.StorageKey); err != nil {
+			app.errorLog.Print(err)
+		}
+	})
+
+	a.URL = app.attachmentURL(a.ID)
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditAttachmentCreate, EntityType: "attachment", EntityID: a.ID}, nil, a)
+
+	app.writeJSON(w, http.StatusCreated, responsePayload{"attachment": a})
+}
+
+func (app *application) getAttachmentsByTask(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.ownedTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	attachments, err := app.models.Attachments.GetByTask(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	for _, a := range attachments {
+		a.URL = app.attachmentURL(a.ID)
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"attachments": attachments})
+}
+
+func (app *application) removeAttachment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	attachmentID, err := strconv.Atoi(vars["attachment_id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.writableTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	a, err := app.models.Attachments.Get(attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if a.TaskID != t.ID {
+		app.notFound(w)
+		return
+	}
+
+	if err = app.models.Attachments.Delete(a.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.afterCommit(func() {
+		if err := app.blobs.Delete(context.Background(), a.StorageKey); err != nil {
+			app.errorLog.Print(err)
+		}
+	})
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditAttachmentDelete, EntityType: "attachment", EntityID: a.ID}, a, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	qs := r.URL.Query()
+
+	expires, err := strconv.ParseInt(qs.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		app.forbidden(w)
+		return
+	}
+
+	signature, err := hex.DecodeString(qs.Get("signature"))
+	if err != nil || !hmac.Equal(signature, app.signAttachment(id, expires)) {
+		app.forbidden(w)
+		return
+	}
+
+	a, err := app.models.Attachments.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if a.TaskID == 0 {
+		app.notFound(w)
+		return
+	}
+
+	rc, err := app.blobs.Get(r.Context(), a.StorageKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if _, err = io.Copy(w, rc); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+func (app *application) attachmentURL(id int) string {
+	expires := time.Now().Add(app.config.attachments.urlTTL).Unix()
+	signature := hex.EncodeToString(app.signAttachment(id, expires))
+
+	return fmt.Sprintf("/api/v1/attachments/%d/download?expires=%d&signature=%s", id, expires, signature)
+}
+
+func (app *application) signAttachment(id int, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(app.config.attachments.secret))
+	fmt.Fprintf(mac, "attachment:%d:%d", id, expires)
+
+	return mac.Sum(nil)
+}
+
+func attachmentKey(taskID int) (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tasks/%d/%s", taskID, hex.EncodeToString(randomBytes)), nil
+}
+
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+
+	if len([]rune(name)) > 255 {
+		name = string([]rune(name)[:255])
+	}
+
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+
+	return name
+}
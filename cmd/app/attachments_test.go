@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/blob"
+)
+
+func multipartBody(t *testing.T, field, filename string, content []byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+
+	fw, err := mw.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = fw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return body, mw.FormDataContentType()
+}
+
+func uploadRequest(t *testing.T, h http.Handler, path, token, field string, content []byte) *httptest.ResponseRecorder {
+	body, contentType := multipartBody(t, field, "notes.txt", content)
+
+	req, _ := http.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestUploadAttachment(t *testing.T) {
+	tests := []struct {
+		name     string
+		urlPath  string
+		field    string
+		content  []byte
+		quota    int64
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid upload", "/tasks/1/attachments", "file", []byte("hello world"), 0, http.StatusCreated, []byte("text/plain"), "123"},
+		{"Sniffs content type", "/tasks/1/attachments", "file", []byte("\x89PNG\r\n\x1a\n0000"), 0, http.StatusCreated, []byte("image/png"), "123"},
+		{"Too large", "/tasks/1/attachments", "file", make([]byte, 1<<20+1), 0, http.StatusRequestEntityTooLarge, nil, "123"},
+		{"Quota exceeded", "/tasks/1/attachments", "file", []byte("hello world"), 5, http.StatusRequestEntityTooLarge, []byte("quota"), "123"},
+		{"Empty file", "/tasks/1/attachments", "file", []byte{}, 0, http.StatusBadRequest, nil, "123"},
+		{"Missing file field", "/tasks/1/attachments", "upload", []byte("hello world"), 0, http.StatusBadRequest, nil, "123"},
+		{"Non-existent task", "/tasks/2/attachments", "file", []byte("hello world"), 0, http.StatusNotFound, nil, "123"},
+		{"Forbidden user", "/tasks/1/attachments", "file", []byte("hello world"), 0, http.StatusForbidden, nil, "456"},
+		{"Unauthorised user", "/tasks/1/attachments", "file", []byte("hello world"), 0, http.StatusUnauthorized, nil, "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			if tt.quota != 0 {
+				app.config.attachments.quota = tt.quota
+			}
+
+			r := uploadRequest(t, app.routes(), "/api/v1"+tt.urlPath, tt.token, tt.field, tt.content)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestUploadAttachmentNotMultipart(t *testing.T) {
+	app := newTestApplication(t)
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	r := rm("/api/v1/tasks/1/attachments", `{"file": "hello"}`, "123")
+
+	if r.Code != http.StatusBadRequest {
+		t.Errorf("want %d; got %d", http.StatusBadRequest, r.Code)
+	}
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	app := newTestApplication(t)
+
+	err := app.blobs.Put(context.Background(), "tasks/1/notes", strings.NewReader("hello world"), 11, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := time.Now().Add(-time.Minute).Unix()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid signature", app.attachmentURL(1), http.StatusOK, []byte("hello world")},
+		{"Expired signature", fmt.Sprintf("/api/v1/attachments/1/download?expires=%d&signature=%x", expired, app.signAttachment(1, expired)), http.StatusForbidden, nil},
+		{"Tampered signature", strings.Replace(app.attachmentURL(1), "signature=", "signature=00", 1), http.StatusForbidden, nil},
+		{"Signature for another attachment", strings.Replace(app.attachmentURL(1), "/attachments/1/", "/attachments/2/", 1), http.StatusForbidden, nil},
+		{"Missing signature", "/api/v1/attachments/1/download", http.StatusForbidden, nil},
+		{"Non-existent attachment", app.attachmentURL(2), http.StatusNotFound, nil},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm(tt.urlPath, "", "")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	r := rm(app.attachmentURL(1), "", "")
+
+	if cd := r.Header().Get("Content-Disposition"); cd != `attachment; filename=notes.txt` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+
+	if nosniff := r.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+		t.Errorf("want X-Content-Type-Options nosniff; got %q", nosniff)
+	}
+}
+
+func TestGetAttachmentsByTask(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid request", "/tasks/1/attachments", http.StatusOK, []byte("/api/v1/attachments/1/download?expires="), "123"},
+		{"Forbidden user", "/tasks/1/attachments", http.StatusForbidden, nil, "456"},
+		{"Unauthorised user", "/tasks/1/attachments", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRemoveAttachment(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		token    string
+	}{
+		{"Valid request", "/tasks/1/attachments/1", http.StatusNoContent, "123"},
+		{"Non-existent attachment", "/tasks/1/attachments/2", http.StatusNotFound, "123"},
+		{"Forbidden user", "/tasks/1/attachments/1", http.StatusForbidden, "456"},
+		{"Unauthorised user", "/tasks/1/attachments/1", http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "DELETE", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	app.wg.Wait()
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"notes.txt", "notes.txt"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\report.pdf`, "report.pdf"},
+		{"evil\"\r\nname.txt", "evilname.txt"},
+		{"", "attachment"},
+		{"/", "attachment"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeFilename(tt.name); got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// s3StandIn is a minimal in-memory S3-compatible server that checks
+// requests carry a SigV4 authorization header.
+type s3StandIn struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+var sigV4Header = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=test-key/\d{8}/us-east-1/s3/aws4_request, SignedHeaders=[a-z0-9;-]*host[a-z0-9;-]*x-amz-date[a-z0-9;-]*, Signature=[0-9a-f]{64}$`)
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sigV4Header.MatchString(r.Header.Get("Authorization")) || r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		s.objects[r.URL.Path] = b
+		s.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		b, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	standIn := &s3StandIn{objects: map[string][]byte{}, types: map[string]string{}}
+	ts := httptest.NewServer(standIn)
+	defer ts.Close()
+
+	store, err := blob.NewS3Store(ts.URL, "us-east-1", "attachments", "test-key", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	err = store.Put(ctx, "tasks/1/notes", strings.NewReader("hello world"), 11, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(standIn.objects["/attachments/tasks/1/notes"]); got != "hello world" {
+		t.Errorf("want stored object %q; got %q", "hello world", got)
+	}
+
+	if got := standIn.types["/attachments/tasks/1/notes"]; got != "text/plain" {
+		t.Errorf("want content type %q; got %q", "text/plain", got)
+	}
+
+	rc, err := store.Get(ctx, "tasks/1/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+
+	if string(b) != "hello world" {
+		t.Errorf("want %q; got %q", "hello world", b)
+	}
+
+	if err = store.Delete(ctx, "tasks/1/notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.Get(ctx, "tasks/1/notes"); err != blob.ErrNotFound {
+		t.Errorf("want ErrNotFound; got %v", err)
+	}
+
+	bad, _ := blob.NewS3Store(ts.URL, "us-east-1", "attachments", "wrong-key", "test-secret")
+	if err = bad.Put(ctx, "tasks/1/notes", strings.NewReader("x"), 1, "text/plain"); err == nil {
+		t.Error("want error for rejected credentials")
+	}
+}
+
+func TestUploadAttachmentToS3(t *testing.T) {
+	standIn := &s3StandIn{objects: map[string][]byte{}, types: map[string]string{}}
+	ts := httptest.NewServer(standIn)
+	defer ts.Close()
+
+	store, err := blob.NewS3Store(ts.URL, "us-east-1", "attachments", "test-key", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(t)
+	app.blobs = store
+
+	r := uploadRequest(t, app.routes(), "/api/v1/tasks/1/attachments", "123", "file", []byte("hello world"))
+	if r.Code != http.StatusCreated {
+		t.Fatalf("want %d; got %d", http.StatusCreated, r.Code)
+	}
+
+	if len(standIn.objects) != 1 {
+		t.Errorf("want 1 stored object; got %d", len(standIn.objects))
+	}
+
+	for key, b := range standIn.objects {
+		if !strings.HasPrefix(key, "/attachments/tasks/1/") || string(b) != "hello world" {
+			t.Errorf("unexpected object %q: %q", key, b)
+		}
+	}
+}
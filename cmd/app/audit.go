@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+	"github.com/tomasen/realip"
+)
+
+// recordAudit stores e, failing the request's transaction if it can't, so
+// that no change is committed without its audit event.
+func (app *application) recordAudit(r *http.Request, e *data.AuditEvent, before, after interface{}) {
+	if claims, ok := app.claimsFromContext(r.Context()); ok {
+		if e.UserID == 0 {
+			e.UserID = claims.UserID
+		}
+		e.ImpersonatorID = claims.ImpersonatorID
+	}
+
+	e.IP = realip.FromRequest(r)
+
+	b, a, err := data.AuditDiff(before, after)
+	if err != nil {
+		app.failTx(err)
+		return
+	}
+
+	e.Before = b
+	e.After = a
+
+	err = app.models.Audit.Insert(e)
+	if err != nil {
+		app.failTx(err)
+	}
+}
+
+func (app *application) getAuditByUser(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	v := validator.New()
+
+	filter, filters := app.auditInputFromQuery(r.URL.Query(), v)
+	filter.UserID = claims.UserID
+
+	if v.Exec(&filters); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	events, metadata, err := app.models.Audit.GetAll(filter, filters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "events": events})
+}
+
+func (app *application) adminListAudit(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	filter, filters := app.auditInputFromQuery(qs, v)
+	filter.UserID = app.intFromQuery(qs, "user_id", 0)
+
+	if v.Exec(&filters); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	events, metadata, err := app.models.Audit.GetAll(filter, filters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "events": events})
+}
+
+func (app *application) auditInputFromQuery(qs url.Values, v *validator.Validator) (data.AuditFilter, data.Filters) {
+	var filter data.AuditFilter
+	var filters data.Filters
+
+	filter.Action = app.stringFromQuery(qs, "action", "")
+	filter.EntityType = app.stringFromQuery(qs, "entity_type", "")
+	filter.From = app.dateFromQuery(qs, "from", time.Time{}, time.UTC)
+	filter.To = app.dateFromQuery(qs, "to", time.Time{}, time.UTC)
+
+	if !filter.To.IsZero() {
+		filter.To = filter.To.Add(24 * time.Hour)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() {
+		v.Check(filter.From.Before(filter.To), "from", "must not be after to")
+	}
+
+	filters.Page = app.intFromQuery(qs, "page", 1)
+	filters.PageSize = app.intFromQuery(qs, "page_size", 50)
+	filters.Sort = app.stringFromQuery(qs, "sort", "-created")
+	filters.SortSafeList = []string{"id", "created", "-id", "-created"}
+
+	return filter, filters
+}
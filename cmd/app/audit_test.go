@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/data/mock"
+)
+
+type auditRecorder struct {
+	events []*data.AuditEvent
+}
+
+func (a *auditRecorder) Insert(e *data.AuditEvent) error {
+	a.events = append(a.events, e)
+	return nil
+}
+
+func (a *auditRecorder) GetAll(filter data.AuditFilter, filters data.Filters) ([]*data.AuditEvent, data.MetaData, error) {
+	return a.events, data.MetaData{}, nil
+}
+
+func TestGetAuditByUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid user", "/users/me/audit", http.StatusOK, []byte("folder.create"), "123"},
+		{"Filtered", "/users/me/audit?action=folder.create&from=2023-01-01&to=2023-02-01", http.StatusOK, []byte("folder.create"), "123"},
+		{"Invalid range", "/users/me/audit?from=2023-02-01&to=2023-01-01", http.StatusUnprocessableEntity, nil, "123"},
+		{"Invalid sort", "/users/me/audit?sort=action", http.StatusUnprocessableEntity, nil, "123"},
+		{"Invalid user", "/users/me/audit", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, r.Body)
+			}
+		})
+	}
+}
+
+func TestAdminListAudit(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		token    string
+	}{
+		{"Admin user", "/admin/audit?user_id=1", http.StatusOK, "admin"},
+		{"Regular user", "/admin/audit", http.StatusForbidden, "123"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestAuditRecorded(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		urlPath    string
+		body       string
+		token      string
+		wantAction string
+		wantUserID int
+	}{
+		{"Folder created", "POST", "/users/me/folders", `{"name": "Test"}`, "123", data.AuditFolderCreate, 1},
+		{"Folder deleted", "DELETE", "/folders/1", "", "123", data.AuditFolderDelete, 1},
+		{"Task updated", "PATCH", "/tasks/1", `{"title": "New title"}`, "123", data.AuditTaskUpdate, 1},
+		{"Login failed", "POST", "/auth/login", `{"email": "invalid", "password": "Test1234"}`, "", data.AuditLoginFailure, 0},
+		{"Login succeeded", "POST", "/auth/login", `{"email": "mock@example.com", "password": "Test1234"}`, "", data.AuditLoginSuccess, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			rec := &auditRecorder{}
+			app.models.Audit = rec
+
+			rm := getRequestMaker(app.routes(), tt.method, t)
+			rm("/api/v1"+tt.urlPath, tt.body, tt.token)
+
+			if len(rec.events) == 0 {
+				t.Fatal("want audit event to be recorded")
+			}
+
+			e := rec.events[0]
+
+			if e.Action != tt.wantAction {
+				t.Errorf("want action %q; got %q", tt.wantAction, e.Action)
+			}
+
+			if e.UserID != tt.wantUserID {
+				t.Errorf("want user %d; got %d", tt.wantUserID, e.UserID)
+			}
+
+			for _, raw := range [][]byte{e.Before, e.After} {
+				if len(raw) > 0 && !json.Valid(raw) {
+					t.Errorf("want valid JSON; got %q", raw)
+				}
+			}
+		})
+	}
+}
+
+type failingAudit struct {
+	mock.AuditModel
+}
+
+func (failingAudit) Insert(e *data.AuditEvent) error {
+	return errors.New("audit unavailable")
+}
+
+type recordingTx struct {
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *recordingTx) Commit() error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	tx.rolledBack = true
+	return nil
+}
+
+func TestAuditFailureRollsBack(t *testing.T) {
+	tests := []struct {
+		name         string
+		failAudit    bool
+		wantCode     int
+		wantCommit   bool
+		wantRollback bool
+	}{
+		{"Audit recorded", false, http.StatusCreated, true, false},
+		{"Audit failed", true, http.StatusInternalServerError, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			if tt.failAudit {
+				app.models.Audit = failingAudit{}
+			}
+
+			tx := &recordingTx{}
+			app.beginModels = func(context.Context) (data.Models, modelsTx, error) {
+				return app.models, tx, nil
+			}
+
+			rm := getRequestMaker(app.routes(), "POST", t)
+			r := rm("/api/v1/users/me/folders", `{"name": "Test"}`, "123")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tx.committed != tt.wantCommit {
+				t.Errorf("want committed %t; got %t", tt.wantCommit, tx.committed)
+			}
+
+			if tx.rolledBack != tt.wantRollback {
+				t.Errorf("want rolled back %t; got %t", tt.wantRollback, tx.rolledBack)
+			}
+		})
+	}
+}
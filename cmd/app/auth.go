@@ -3,9 +3,12 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/jwt"
+	"github.com/tomasen/realip"
 )
 
 func (app *application) login(w http.ResponseWriter, r *http.Request) {
@@ -17,14 +20,90 @@ func (app *application) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	email := strings.ToLower(creds.Email)
+	ip := realip.FromRequest(r)
+
+	failures, err := app.models.LoginAttempts.Record(email, ip, time.Now().Add(-app.config.lockout.window))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if failures.IP > app.config.lockout.maxIPAttempts {
+		err = app.rejectLogin(r, failures.ID, email, "ip_blocked")
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.tooManyLoginAttempts(w, app.config.lockout.window)
+		return
+	}
+
+	wait := loginDelay(failures.Email-1) - time.Since(failures.LastEmail)
+	if ipWait := loginDelay(failures.IP-1) - time.Since(failures.LastIP); ipWait > wait {
+		wait = ipWait
+	}
+
+	if wait > 0 {
+		err = app.rejectLogin(r, failures.ID, email, "throttled")
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.tooManyLoginAttempts(w, wait)
+		return
+	}
+
 	u, err := app.models.Users.Authenticate(creds)
 	if err != nil {
-		app.unauthorized(w)
+		switch {
+		case errors.Is(err, data.ErrAccountLocked):
+			err = app.rejectLogin(r, failures.ID, email, "locked")
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			app.accountLocked(w)
+		case errors.Is(err, data.ErrAccountDisabled):
+			err = app.rejectLogin(r, failures.ID, email, "disabled")
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			app.accountDisabled(w)
+		case errors.Is(err, data.ErrInvalidCredentials):
+			app.recordLoginFailure(r, email, "invalid_credentials")
+			app.loginFailed(w, r, email, failures.Email)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.models.LoginAttempts.DeleteForEmail(email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.startSession(w, r, u, "password")
+}
+
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, u *data.User, method string) {
+	if u.Disabled {
+		app.recordAudit(r, &data.AuditEvent{UserID: u.ID, Action: data.AuditLoginFailure}, nil, responsePayload{"method": method, "reason": "disabled"})
+		app.accountDisabled(w)
+		return
+	}
+
+	settings, err := app.models.UserSettings.Get(u.ID)
+	if err != nil {
+		app.serverError(w, err)
 		return
 	}
 
 	exp := time.Now().Add(5 * time.Minute)
-	accessToken, err := app.jwtService.Sign(u.ID, exp)
+	accessToken, err := app.jwtService.Sign(jwt.UserClaims{UserID: u.ID, Role: u.Role}, exp)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -47,17 +126,144 @@ func (app *application) login(w http.ResponseWriter, r *http.Request) {
 
 	http.SetCookie(w, cookie)
 
-	app.writeJSON(w, http.StatusOK, responsePayload{"access_token": accessToken, "user": u})
+	app.recordAudit(r, &data.AuditEvent{UserID: u.ID, Action: data.AuditLoginSuccess}, nil, responsePayload{"method": method})
+	app.recordAudit(r, &data.AuditEvent{UserID: u.ID, Action: data.AuditTokenCreate, EntityType: "token"}, nil, responsePayload{"scope": data.ScopeRefresh, "expiry": exp})
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"access_token": accessToken, "user": u, "settings": settings})
+}
+
+func (app *application) recordLoginFailure(r *http.Request, email, reason string) {
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditLoginFailure}, nil, responsePayload{"email": email, "reason": reason})
+}
+
+// rejectLogin records why an attempt was turned away without its password
+// being checked, and deletes it so that it doesn't count as a failure.
+func (app *application) rejectLogin(r *http.Request, attemptID int, email, reason string) error {
+	app.recordLoginFailure(r, email, reason)
+	return app.models.LoginAttempts.Delete(attemptID)
+}
+
+func (app *application) loginFailed(w http.ResponseWriter, r *http.Request, email string, failures int) {
+	if failures >= app.config.lockout.maxAttempts {
+		err := app.lockAccount(r, email)
+		if err != nil && !errors.Is(err, data.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.unauthorized(w)
+}
+
+func (app *application) lockAccount(r *http.Request, email string) error {
+	u, err := app.models.Users.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(app.config.lockout.duration)
+
+	err = app.models.Users.Lock(u.ID, until)
+	if err != nil {
+		return err
+	}
+
+	token, err := app.models.Tokens.New(u.ID, until, data.ScopeUnlock)
+	if err != nil {
+		return err
+	}
+
+	app.recordAudit(r, &data.AuditEvent{UserID: u.ID, Action: data.AuditTokenCreate, EntityType: "token"}, nil, responsePayload{"scope": data.ScopeUnlock, "expiry": until})
+
+	app.afterCommit(func() {
+		emailData := map[string]interface{}{
+			"firstName":   u.FirstName,
+			"lockedUntil": until.UTC().Format(time.RFC1123),
+			"unlockToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(u.Email, "account_locked.tmpl", emailData)
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+	})
+
+	return nil
+}
+
+func (app *application) unlockAccount(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	u, err := app.models.Users.GetByToken(data.ScopeUnlock, input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.unauthorized(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.models.Users.Unlock(u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.models.Tokens.DeleteForUser(data.ScopeUnlock, u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{UserID: u.ID, Action: data.AuditTokenRevoke, EntityType: "token"}, nil, responsePayload{"scope": data.ScopeUnlock, "reason": "unlocked"})
+
+	err = app.models.LoginAttempts.DeleteForEmail(strings.ToLower(u.Email))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"message": "account successfully unlocked"})
+}
+
+func loginDelay(failures int) time.Duration {
+	if failures < 3 {
+		return 0
+	}
+
+	if failures > 8 {
+		return time.Minute
+	}
+
+	return time.Second << (failures - 3)
 }
 
 func (app *application) logout(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("refresh_token")
 	if nil == err {
+		e := &data.AuditEvent{Action: data.AuditLogout, EntityType: "token"}
+
+		if u, err := app.models.Users.GetByToken(data.ScopeRefresh, cookie.Value); err == nil {
+			e.UserID = u.ID
+		}
+
 		err := app.models.Tokens.Delete(cookie.Value)
 		if err != nil {
 			app.serverError(w, err)
 			return
 		}
+
+		app.recordAudit(r, e, nil, responsePayload{"scope": data.ScopeRefresh})
 	}
 
 	cookie = &http.Cookie{
@@ -86,6 +292,8 @@ func (app *application) logoutEverywhere(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditTokenRevoke, EntityType: "token"}, nil, responsePayload{"scope": data.ScopeRefresh, "reason": "logout_everywhere"})
+
 	cookie := &http.Cookie{
 		Name:     "refresh_token",
 		Value:    "",
@@ -120,8 +328,24 @@ func (app *application) refreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if u.Disabled {
+		app.accountDisabled(w)
+		return
+	}
+
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		app.accountLocked(w)
+		return
+	}
+
+	err = app.models.Users.Touch(u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	exp := time.Now().Add(5 * time.Minute)
-	token, err := app.jwtService.Sign(u.ID, exp)
+	token, err := app.jwtService.Sign(jwt.UserClaims{UserID: u.ID, Role: u.Role}, exp)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -131,18 +355,11 @@ func (app *application) refreshToken(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) guestLogin(w http.ResponseWriter, r *http.Request) {
-	u, err := app.models.Users.GetByEmail("guest@example.com")
-	if err != nil {
-		app.unauthorized(w)
-		return
-	}
-
-	exp := time.Now().Add(24 * time.Hour)
-	token, err := app.jwtService.Sign(u.ID, exp)
+	u, err := app.models.Users.InsertGuest()
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.writeJSON(w, http.StatusOK, responsePayload{"access_token": token, "user": u})
+	app.startSession(w, r, u, "guest")
 }
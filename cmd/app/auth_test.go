@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/data/mock"
 )
 
 func TestLogin(t *testing.T) {
@@ -22,10 +25,18 @@ func TestLogin(t *testing.T) {
 	}{
 		{"Valid credentials", "/auth/login", http.StatusOK, []byte("123"), "",
 			&data.Credentials{Email: "mock@example.com", Password: "Test1234"}},
+		{"Settings", "/auth/login", http.StatusOK, []byte(`"settings"`), "",
+			&data.Credentials{Email: "mock@example.com", Password: "Test1234"}},
 		{"Invalid credentials", "/auth/login", http.StatusUnauthorized, nil, "",
 			&data.Credentials{Email: "invalid", Password: "Test1234"}},
 		{"Trailing slash", "/auth/login/", http.StatusNotFound, nil, "",
 			&data.Credentials{Email: "mock@example.com", Password: "Test1234"}},
+		{"Locked account", "/auth/login", http.StatusLocked, nil, "",
+			&data.Credentials{Email: "locked@example.com", Password: "Test1234"}},
+		{"Throttled account", "/auth/login", http.StatusTooManyRequests, nil, "",
+			&data.Credentials{Email: "throttled@example.com", Password: "Test1234"}},
+		{"Disabled account", "/auth/login", http.StatusForbidden, nil, "",
+			&data.Credentials{Email: "disabled@example.com", Password: "Test1234"}},
 	}
 	rm := getRequestMaker(app.routes(), "POST", t)
 
@@ -44,3 +55,150 @@ func TestLogin(t *testing.T) {
 		})
 	}
 }
+
+// lockRecorder records which accounts are locked.
+type lockRecorder struct {
+	mock.UserModel
+	locked []int
+}
+
+func (l *lockRecorder) Lock(id int, until time.Time) error {
+	l.locked = append(l.locked, id)
+	return nil
+}
+
+// failedAttempts reports every attempt as the last one allowed.
+type failedAttempts struct {
+	mock.LoginAttemptModel
+	count int
+}
+
+func (f failedAttempts) Record(email, ip string, since time.Time) (*data.LoginFailures, error) {
+	return &data.LoginFailures{ID: 1, Email: f.count, IP: 1}, nil
+}
+
+func TestLoginLockoutIgnoresEmailCase(t *testing.T) {
+	app := newTestApplication(t)
+
+	rec := &lockRecorder{}
+	app.models.Users = rec
+	app.models.LoginAttempts = failedAttempts{count: app.config.lockout.maxAttempts}
+
+	rm := getRequestMaker(app.routes(), "POST", t)
+	r := rm("/api/v1/auth/login", `{"email": "Mock@Example.com", "password": "wrong"}`, "")
+
+	if code := r.Code; code != http.StatusUnauthorized {
+		t.Errorf("want %d; got %d", http.StatusUnauthorized, code)
+	}
+
+	app.wg.Wait()
+
+	if len(rec.locked) != 1 || rec.locked[0] != 1 {
+		t.Errorf("want user 1 locked; got %v", rec.locked)
+	}
+}
+
+func TestUnlockAccount(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		body     string
+	}{
+		{"Valid token", "/auth/unlock", http.StatusOK, `{"token": "ABC123"}`},
+		{"Invalid token", "/auth/unlock", http.StatusUnauthorized, `{"token": "invalid"}`},
+		{"Empty body", "/auth/unlock", http.StatusBadRequest, ""},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, tt.body, "")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{5, 4 * time.Second},
+		{8, 32 * time.Second},
+		{20, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d): want %s; got %s", tt.failures, tt.want, got)
+		}
+	}
+}
+
+func TestGuestLogin(t *testing.T) {
+	app := newTestApplication(t)
+
+	rm := getRequestMaker(app.routes(), "POST", t)
+	r := rm("/api/v1/auth/guest", "", "")
+
+	if code := r.Code; code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+
+	if body := r.Body.Bytes(); !bytes.Contains(body, []byte("guest-1234@guest.go-todo.local")) {
+		t.Errorf("want body to contain guest user; got %q", body)
+	}
+
+	if cookie := r.Result().Cookies(); len(cookie) == 0 || cookie[0].Name != "refresh_token" {
+		t.Error("want refresh_token cookie to be set")
+	}
+}
+
+func TestGuestLoginRequiresPost(t *testing.T) {
+	app := newTestApplication(t)
+
+	rm := getRequestMaker(app.routes(), "GET", t)
+	r := rm("/api/v1/auth/guest", "", "")
+
+	if code := r.Code; code != http.StatusMethodNotAllowed {
+		t.Errorf("want %d; got %d", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		cookie   string
+		wantCode int
+	}{
+		{"Valid token", "123", http.StatusOK},
+		{"Invalid token", "invalid", http.StatusUnauthorized},
+		{"Disabled user", "disabled", http.StatusForbidden},
+		{"Locked user", "locked", http.StatusLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/auth/refresh-token", nil)
+			req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tt.cookie})
+
+			app.routes().ServeHTTP(w, req)
+
+			if code := w.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+const maxBatchOperations = 50
+
+var batchRefRX = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,50}$`)
+
+type batchOperation struct {
+	Ref     string            `json:"ref"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// batchHeaders are the headers an operation may set for itself. Everything
+// else is taken from the batch request.
+var batchHeaders = []string{"If-Match", "If-None-Match"}
+
+type batchDTO struct {
+	Transactional bool              `json:"transactional"`
+	Operations    []*batchOperation `json:"operations"`
+}
+
+func (d *batchDTO) Validate(v *validator.Validator) {
+	v.Check(len(d.Operations) > 0, "operations", "must not be empty")
+	v.Check(len(d.Operations) <= maxBatchOperations, "operations", fmt.Sprintf("must not contain more than %d operations", maxBatchOperations))
+
+	refs := make(map[string]bool, len(d.Operations))
+
+	for i, op := range d.Operations {
+		key := fmt.Sprintf("operations[%d]", i)
+
+		v.PermittedValue(key+".method", op.Method, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete)
+		v.Check(strings.HasPrefix(op.Path, "/"), key+".path", "must start with /")
+
+		u, err := url.Parse("/api/v1" + op.Path)
+		v.Check(err == nil, key+".path", "must be a valid path")
+		v.Check(err != nil || path.Clean(u.Path) != "/api/v1/batch", key+".path", "batches cannot be nested")
+
+		for name := range op.Headers {
+			v.PermittedValue(key+".headers", http.CanonicalHeaderKey(name), batchHeaders...)
+		}
+
+		if op.Ref != "" {
+			v.Check(batchRefRX.MatchString(op.Ref), key+".ref", "must contain only letters, digits, - and _")
+			v.Check(!refs[op.Ref], key+".ref", "must be unique within the batch")
+			refs[op.Ref] = true
+		}
+
+		if d.Transactional && op.Method != http.MethodGet {
+			// Stored files are not covered by the database transaction.
+			v.Check(!strings.Contains(op.Path, "/attachments"), key+".path", "attachments cannot be changed in a transactional batch")
+		}
+	}
+}
+
+type batchResult struct {
+	Ref    string          `json:"ref,omitempty"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// errBatchRef is returned when an operation refers to one that has not run,
+// failed or did not create anything.
+type errBatchRef string
+
+func (e errBatchRef) Error() string {
+	return fmt.Sprintf("operation %q did not return an id", string(e))
+}
+
+func (app *application) batch(w http.ResponseWriter, r *http.Request) {
+	dto := &batchDTO{}
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	if !dto.Transactional {
+		results := app.runBatch(r, app.router(), dto.Operations, false)
+		app.writeJSON(w, http.StatusOK, responsePayload{"results": results})
+		return
+	}
+
+	txApp, err := app.beginTx(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	defer txApp.rollback()
+
+	results := app.runBatch(r, txApp.router(), dto.Operations, true)
+
+	committed := results[len(results)-1].Status < 400
+	if committed {
+		if err := txApp.commit(); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"committed": committed, "results": results})
+}
+
+// runBatch dispatches each operation to h in order. When stopOnError is set,
+// operations following a failure are not run and are reported as 424 Failed
+// Dependency.
+func (app *application) runBatch(r *http.Request, h http.Handler, ops []*batchOperation, stopOnError bool) []*batchResult {
+	refs := make(batchRefs, len(ops))
+	for _, op := range ops {
+		if op.Ref != "" {
+			refs[op.Ref] = nil
+		}
+	}
+
+	results := make([]*batchResult, 0, len(ops))
+
+	for i, op := range ops {
+		res := app.runBatchOperation(r, h, op, refs)
+		results = append(results, res)
+
+		if stopOnError && res.Status >= 400 {
+			for _, skipped := range ops[i+1:] {
+				results = append(results, &batchResult{
+					Ref:    skipped.Ref,
+					Status: http.StatusFailedDependency,
+					Body:   batchMessage("not run because an earlier operation failed"),
+				})
+			}
+			break
+		}
+	}
+
+	return results
+}
+
+// runBatchOperation dispatches op to h, recording the id it creates in refs.
+func (app *application) runBatchOperation(r *http.Request, h http.Handler, op *batchOperation, refs batchRefs) *batchResult {
+	res := &batchResult{Ref: op.Ref}
+
+	req, err := app.batchRequest(r, op, refs)
+	if err != nil {
+		res.Status = http.StatusFailedDependency
+		res.Body = batchMessage(err.Error())
+		return res
+	}
+
+	rec := newResponseRecorder()
+	h.ServeHTTP(rec, req)
+
+	res.Status = rec.Status()
+	if rec.body.Len() > 0 {
+		res.Body = json.RawMessage(rec.body.Bytes())
+	}
+
+	if op.Ref != "" && res.Status < 300 {
+		if id, ok := batchResponseID(rec.body.Bytes()); ok {
+			refs[op.Ref] = &id
+		}
+	}
+
+	return res
+}
+
+// batchRefs holds the id created by each named operation, or nil if the
+// operation has not run or did not succeed.
+type batchRefs map[string]*int
+
+// resolve reports whether s is a reference to a named operation and, if so,
+// the id it created.
+func (refs batchRefs) resolve(s string) (int, bool, error) {
+	if !strings.HasPrefix(s, "$") {
+		return 0, false, nil
+	}
+
+	id, declared := refs[s[1:]]
+	if !declared {
+		return 0, false, nil
+	}
+	if id == nil {
+		return 0, true, errBatchRef(s[1:])
+	}
+
+	return *id, true, nil
+}
+
+// batchRequest builds the request for op, substituting references to other
+// operations with the ids they created.
+func (app *application) batchRequest(r *http.Request, op *batchOperation, refs batchRefs) (*http.Request, error) {
+	segments := strings.Split(op.Path, "/")
+	for i, s := range segments {
+		id, ok, err := refs.resolve(s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			segments[i] = strconv.Itoa(id)
+		}
+	}
+
+	var body []byte
+
+	if len(op.Body) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(op.Body))
+		dec.UseNumber()
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+
+		v, err := refs.replace(v)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), op.Method, "/api/v1"+strings.Join(segments, "/"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = r.Header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Del("Idempotency-Key")
+	for _, name := range batchHeaders {
+		req.Header.Del(name)
+	}
+	for name, value := range op.Headers {
+		req.Header.Set(name, value)
+	}
+	req.RemoteAddr = r.RemoteAddr
+
+	return req, nil
+}
+
+// replace substitutes every string in a decoded JSON value that references
+// a named operation.
+func (refs batchRefs) replace(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		id, ok, err := refs.resolve(v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return id, nil
+		}
+	case []interface{}:
+		for i := range v {
+			resolved, err := refs.replace(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	case map[string]interface{}:
+		for k := range v {
+			resolved, err := refs.replace(v[k])
+			if err != nil {
+				return nil, err
+			}
+			v[k] = resolved
+		}
+	}
+
+	return v, nil
+}
+
+// batchResponseID extracts the id of the resource in a response such as
+// {"task": {"id": 1, ...}}.
+func batchResponseID(body []byte) (int, bool) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil || len(envelope) != 1 {
+		return 0, false
+	}
+
+	for _, raw := range envelope {
+		var resource struct {
+			ID *int `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &resource); err != nil || resource.ID == nil {
+			return 0, false
+		}
+		return *resource.ID, true
+	}
+
+	return 0, false
+}
+
+func batchMessage(msg string) json.RawMessage {
+	b, _ := json.Marshal(responsePayload{"message": msg})
+	return b
+}
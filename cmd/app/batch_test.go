@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	app := newTestApplication(t)
+
+	tooMany := `{"operations": [` + strings.TrimSuffix(strings.Repeat(`{"method": "GET", "path": "/tasks/1"},`, maxBatchOperations+1), ",") + `]}`
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{
+			"Back-reference",
+			`{"operations": [
+				{"ref": "folder", "method": "POST", "path": "/users/me/folders", "body": {"name": "Work"}},
+				{"method": "POST", "path": "/folders/$folder/tasks", "body": {"title": "Task", "datetime": "2030-01-01T00:00:00Z", "status": "low"}}
+			]}`,
+			http.StatusOK, []byte(`"status": 201`), "123",
+		},
+		{
+			"Back-reference in body",
+			`{"operations": [
+				{"ref": "folder", "method": "POST", "path": "/users/me/folders", "body": {"name": "Work"}},
+				{"method": "POST", "path": "/tasks/1/move", "body": {"folder_id": "$folder"}}
+			]}`,
+			http.StatusOK, []byte(`"status": 200`), "123",
+		},
+		{
+			"Reference to failed operation",
+			`{"operations": [
+				{"ref": "task", "method": "GET", "path": "/tasks/2"},
+				{"method": "DELETE", "path": "/tasks/$task"}
+			]}`,
+			http.StatusOK, []byte(`"status": 424`), "123",
+		},
+		{
+			"Continues after failure",
+			`{"operations": [
+				{"method": "GET", "path": "/tasks/2"},
+				{"method": "GET", "path": "/tasks/1"}
+			]}`,
+			http.StatusOK, []byte(`"status": 200`), "123",
+		},
+		{
+			"Transactional rollback",
+			`{"transactional": true, "operations": [
+				{"method": "POST", "path": "/users/me/folders", "body": {"name": "Work"}},
+				{"method": "GET", "path": "/tasks/2"},
+				{"method": "GET", "path": "/tasks/1"}
+			]}`,
+			http.StatusOK, []byte(`"committed": false`), "123",
+		},
+		{
+			"Transactional commit",
+			`{"transactional": true, "operations": [
+				{"method": "POST", "path": "/users/me/folders", "body": {"name": "Work"}},
+				{"method": "GET", "path": "/tasks/1"}
+			]}`,
+			http.StatusOK, []byte(`"committed": true`), "123",
+		},
+		{
+			"Forbidden operation",
+			`{"operations": [{"method": "GET", "path": "/tasks/1"}]}`,
+			http.StatusOK, []byte(`"status": 403`), "456",
+		},
+		{
+			"Transactional attachment change",
+			`{"transactional": true, "operations": [{"method": "DELETE", "path": "/tasks/1/attachments/1"}]}`,
+			http.StatusUnprocessableEntity, []byte("attachments"), "123",
+		},
+		{
+			"Nested batch",
+			`{"operations": [{"method": "POST", "path": "/batch", "body": {}}]}`,
+			http.StatusUnprocessableEntity, []byte("nested"), "123",
+		},
+		{
+			"Nested batch with query",
+			`{"operations": [{"method": "POST", "path": "/batch?x=1", "body": {}}]}`,
+			http.StatusUnprocessableEntity, []byte("nested"), "123",
+		},
+		{
+			"Nested batch with extra slashes",
+			`{"operations": [{"method": "POST", "path": "//batch/", "body": {}}]}`,
+			http.StatusUnprocessableEntity, []byte("nested"), "123",
+		},
+		{
+			"Duplicate ref",
+			`{"operations": [{"ref": "a", "method": "GET", "path": "/tasks/1"}, {"ref": "a", "method": "GET", "path": "/tasks/1"}]}`,
+			http.StatusUnprocessableEntity, []byte("unique"), "123",
+		},
+		{"Invalid method", `{"operations": [{"method": "TRACE", "path": "/tasks/1"}]}`, http.StatusUnprocessableEntity, []byte("method"), "123"},
+		{"Too many operations", tooMany, http.StatusUnprocessableEntity, []byte(fmt.Sprint(maxBatchOperations)), "123"},
+		{"No operations", `{"operations": []}`, http.StatusUnprocessableEntity, []byte("operations"), "123"},
+		{"Invalid user", `{"operations": [{"method": "GET", "path": "/tasks/1"}]}`, http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1/batch", tt.body, tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
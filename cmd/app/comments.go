@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) createComment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.writableTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	dto := &data.CreateCommentDTO{}
+	err = app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	c, err := app.models.Comments.Insert(t.ID, claims.UserID, dto)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditCommentCreate, EntityType: "comment", EntityID: c.ID}, nil, c)
+
+	app.writeJSON(w, http.StatusCreated, responsePayload{"comment": c})
+}
+
+func (app *application) getCommentsByTask(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.ownedTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.intFromQuery(qs, "page", 1)
+	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
+	input.Filters.Sort = app.stringFromQuery(qs, "sort", "created")
+	input.Filters.SortSafeList = []string{"id", "created", "-id", "-created"}
+
+	v := validator.New()
+	if v.Exec(&input.Filters); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	comments, metadata, err := app.models.Comments.GetByTask(t.ID, input.Filters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "comments": comments})
+}
+
+func (app *application) updateComment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	c, ok := app.authoredComment(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+
+	dto := &data.UpdateCommentDTO{}
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	before := c
+
+	c, err = app.models.Comments.Update(c.ID, dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditCommentUpdate, EntityType: "comment", EntityID: c.ID}, before, c)
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"comment": c})
+}
+
+func (app *application) removeComment(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	c, ok := app.authoredComment(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Comments.Delete(c.ID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditCommentDelete, EntityType: "comment", EntityID: c.ID}, c, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) authoredComment(w http.ResponseWriter, r *http.Request, userID int) (*data.Comment, bool) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		app.notFound(w)
+		return nil, false
+	}
+
+	commentID, err := strconv.Atoi(vars["comment_id"])
+	if err != nil {
+		app.notFound(w)
+		return nil, false
+	}
+
+	t, ok := app.writableTask(w, userID, id)
+	if !ok {
+		return nil, false
+	}
+
+	c, err := app.models.Comments.Get(t.ID, commentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if c.Author == nil || c.Author.ID != userID {
+		app.forbidden(w)
+		return nil, false
+	}
+
+	return c, true
+}
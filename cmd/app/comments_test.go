@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestCreateComment(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		token    string
+	}{
+		{"Valid request", "/tasks/1/comments", `{"body": "Looks **good**"}`, http.StatusCreated, "123"},
+		{"Empty body", "/tasks/1/comments", `{"body": ""}`, http.StatusUnprocessableEntity, "123"},
+		{"Malformed JSON", "/tasks/1/comments", `{"body": }`, http.StatusBadRequest, "123"},
+		{"Non-existent task", "/tasks/2/comments", `{"body": "Hi"}`, http.StatusNotFound, "123"},
+		{"Forbidden user", "/tasks/1/comments", `{"body": "Hi"}`, http.StatusForbidden, "456"},
+		{"Unauthorised user", "/tasks/1/comments", `{"body": "Hi"}`, http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, tt.body, tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestGetCommentsByTask(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid request", "/tasks/1/comments", http.StatusOK, []byte("Looks **good**"), "123"},
+		{"Invalid sort", "/tasks/1/comments?sort=body", http.StatusUnprocessableEntity, nil, "123"},
+		{"Forbidden user", "/tasks/1/comments", http.StatusForbidden, nil, "456"},
+		{"Unauthorised user", "/tasks/1/comments", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		token    string
+	}{
+		{"Valid request", "/tasks/1/comments/1", `{"body": "Edited"}`, http.StatusOK, "123"},
+		{"Empty body", "/tasks/1/comments/1", `{"body": ""}`, http.StatusUnprocessableEntity, "123"},
+		{"Not the author", "/tasks/1/comments/2", `{"body": "Edited"}`, http.StatusForbidden, "123"},
+		{"Non-existent comment", "/tasks/1/comments/3", `{"body": "Edited"}`, http.StatusNotFound, "123"},
+		{"Forbidden user", "/tasks/1/comments/1", `{"body": "Edited"}`, http.StatusForbidden, "456"},
+		{"Unauthorised user", "/tasks/1/comments/1", `{"body": "Edited"}`, http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "PATCH", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, tt.body, tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestRemoveComment(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		token    string
+	}{
+		{"Valid request", "/tasks/1/comments/1", http.StatusNoContent, "123"},
+		{"Not the author", "/tasks/1/comments/2", http.StatusForbidden, "123"},
+		{"Non-existent comment", "/tasks/1/comments/3", http.StatusNotFound, "123"},
+		{"Unauthorised user", "/tasks/1/comments/1", http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "DELETE", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
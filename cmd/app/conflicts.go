@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+// taskConflict is a pair of tasks that overlap from Start to End.
+type taskConflict struct {
+	Tasks [2]*data.Task `json:"tasks"`
+	Start time.Time     `json:"start"`
+	End   time.Time     `json:"end"`
+}
+
+func (app *application) getConflicts(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	loc, err := app.userLocation(claims.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	loc = app.locationFromQuery(qs, "tz", loc, v)
+	from, to := app.dayRangeFromQuery(qs, loc, v)
+
+	if !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	end := to.AddDate(0, 0, 1)
+
+	tasks, err := app.models.Tasks.GetOverlapping(claims.UserID, from, end, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	truncated := len(tasks) > data.MaxConflictTasks
+	if truncated {
+		tasks = tasks[:data.MaxConflictTasks]
+	}
+
+	conflicts, more := findConflicts(tasks, from, end)
+
+	app.writeJSON(w, http.StatusOK, responsePayload{
+		"tz":        loc.String(),
+		"from":      from.Format("2006-01-02"),
+		"to":        to.Format("2006-01-02"),
+		"conflicts": conflicts,
+		"truncated": truncated || more,
+	})
+}
+
+// findConflicts pairs up tasks, sorted by when they start, that overlap at
+// some point in [from, to). At most data.MaxConflictTasks pairs are
+// returned, and truncated is set if there were more.
+func findConflicts(tasks []*data.Task, from, to time.Time) (conflicts []*taskConflict, truncated bool) {
+	conflicts = []*taskConflict{}
+
+	for i, a := range tasks {
+		for _, b := range tasks[i+1:] {
+			// b starts no earlier than a, so neither do the tasks after it.
+			if !b.Datetime.Before(*a.EndDatetime) {
+				break
+			}
+
+			start, end := *b.Datetime, *a.EndDatetime
+			if b.EndDatetime.Before(end) {
+				end = *b.EndDatetime
+			}
+
+			if !start.Before(to) || !end.After(from) {
+				continue
+			}
+
+			if len(conflicts) == data.MaxConflictTasks {
+				return conflicts, true
+			}
+
+			conflicts = append(conflicts, &taskConflict{Tasks: [2]*data.Task{a, b}, Start: start, End: end})
+		}
+	}
+
+	return conflicts, false
+}
+
+// conflictsAllowed checks a task that will take place from start to end, if
+// it is timed, against the user's other tasks when the request asks for
+// check_conflicts. Unless force is also set, it responds with 409 Conflict
+// and the tasks it overlaps.
+func (app *application) conflictsAllowed(w http.ResponseWriter, r *http.Request, userID, taskID int, start, end time.Time, timed bool) bool {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	check := app.optionalBoolFromQuery(qs, "check_conflicts", v)
+	force := app.optionalBoolFromQuery(qs, "force", v)
+
+	if !v.Valid() {
+		app.validationFailed(w, v)
+		return false
+	}
+
+	if !timed || check == nil || !*check || force != nil && *force {
+		return true
+	}
+
+	tasks, err := app.models.Tasks.GetOverlapping(userID, start, end, taskID)
+	if err != nil {
+		app.serverError(w, err)
+		return false
+	}
+
+	if len(tasks) == 0 {
+		return true
+	}
+
+	if len(tasks) > data.MaxConflictTasks {
+		tasks = tasks[:data.MaxConflictTasks]
+	}
+
+	app.writeJSON(w, http.StatusConflict, responsePayload{
+		"message":   "the task overlaps other tasks; send force=true to save it anyway",
+		"conflicts": tasks,
+	})
+	return false
+}
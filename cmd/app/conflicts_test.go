@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+func TestGetConflicts(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Conflict", "/users/me/conflicts?from=2030-01-01&to=2030-01-01", http.StatusOK, []byte(`"start": "2030-01-01T09:30:00Z"`), "123"},
+		{"Time zone", "/users/me/conflicts?from=2030-01-01&to=2030-01-01&tz=Pacific/Auckland", http.StatusOK, []byte(`"Planning"`), "123"},
+		{"No conflicts", "/users/me/conflicts?from=2030-01-02&to=2030-01-08", http.StatusOK, []byte(`"conflicts": []`), "123"},
+		{"Reversed range", "/users/me/conflicts?from=2030-01-02&to=2030-01-01", http.StatusUnprocessableEntity, []byte("to"), "123"},
+		{"Invalid user", "/users/me/conflicts", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	app := newTestApplication(t)
+
+	overlapping := `{"title": "Review", "datetime": "2030-01-01T09:45:00Z", "duration": 30}`
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		body     string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Create conflicting", http.MethodPost, "/folders/1/tasks?check_conflicts=true", overlapping, http.StatusConflict, []byte(`"Standup"`)},
+		{"Create forced", http.MethodPost, "/folders/1/tasks?check_conflicts=true&force=true", overlapping, http.StatusCreated, []byte(`"task"`)},
+		{"Create unchecked", http.MethodPost, "/folders/1/tasks", overlapping, http.StatusCreated, []byte(`"task"`)},
+		{"Create free slot", http.MethodPost, "/folders/1/tasks?check_conflicts=true", `{"title": "Lunch", "datetime": "2030-01-01T12:00:00Z", "duration": 60}`, http.StatusCreated, []byte(`"task"`)},
+		{"Create without end", http.MethodPost, "/folders/1/tasks?check_conflicts=true", `{"title": "Call", "datetime": "2030-01-01T09:45:00Z"}`, http.StatusCreated, []byte(`"task"`)},
+		{"Invalid flag", http.MethodPost, "/folders/1/tasks?check_conflicts=maybe", overlapping, http.StatusUnprocessableEntity, []byte("check_conflicts")},
+		{"Update conflicting", http.MethodPatch, "/tasks/1?check_conflicts=true", `{"datetime": "2030-01-01T09:45:00Z", "end_datetime": "2030-01-01T10:15:00Z"}`, http.StatusConflict, []byte(`"Planning"`)},
+		{"Update completed", http.MethodPatch, "/tasks/1?check_conflicts=true", `{"datetime": "2030-01-01T09:45:00Z", "end_datetime": "2030-01-01T10:15:00Z", "status": "completed"}`, http.StatusOK, []byte(`"task"`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/api/v1"+tt.urlPath, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer 123")
+
+			app.routes().ServeHTTP(w, req)
+
+			if code := w.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := w.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	task := func(id int, start, end string) *data.Task {
+		s, _ := time.Parse(time.RFC3339, start)
+		e, _ := time.Parse(time.RFC3339, end)
+		return &data.Task{ID: id, Datetime: &s, EndDatetime: &e}
+	}
+
+	tasks := []*data.Task{
+		task(1, "2030-01-01T22:00:00Z", "2030-01-02T02:00:00Z"),
+		task(2, "2030-01-01T23:00:00Z", "2030-01-01T23:30:00Z"),
+		task(3, "2030-01-02T01:00:00Z", "2030-01-02T03:00:00Z"),
+		task(4, "2030-01-02T03:00:00Z", "2030-01-02T04:00:00Z"),
+	}
+
+	from, _ := time.Parse(time.RFC3339, "2030-01-02T00:00:00Z")
+	to := from.AddDate(0, 0, 1)
+
+	// 1 and 2 overlap before from, and 3 ends as 4 starts.
+	conflicts, truncated := findConflicts(tasks, from, to)
+	if truncated {
+		t.Error("want conflicts not to be truncated")
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("want 1 conflict; got %d", len(conflicts))
+	}
+
+	c := conflicts[0]
+	if c.Tasks[0].ID != 1 || c.Tasks[1].ID != 3 {
+		t.Errorf("want tasks 1 and 3; got %d and %d", c.Tasks[0].ID, c.Tasks[1].ID)
+	}
+	if !c.Start.Equal(*tasks[2].Datetime) || !c.End.Equal(*tasks[0].EndDatetime) {
+		t.Errorf("want overlap from %s to %s; got %s to %s", tasks[2].Datetime, tasks[0].EndDatetime, c.Start, c.End)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) getTaskDependencies(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.ownedTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	blockers, err := app.models.Dependencies.GetBlockers(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	dependents, err := app.models.Dependencies.GetDependents(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"dependencies": blockers, "dependents": dependents})
+}
+
+func (app *application) addTaskDependency(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.writableTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	dto := &data.CreateDependencyDTO{}
+	err = app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	blocker, ok := app.ownedTask(w, claims.UserID, dto.DependsOnID)
+	if !ok {
+		return
+	}
+
+	err = app.models.Dependencies.Insert(claims.UserID, t.ID, blocker.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDependencyCycle):
+			app.conflict(w, "this dependency would create a cycle")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditDependencyCreate, EntityType: "task", EntityID: t.ID}, nil, dto)
+
+	blockers, err := app.models.Dependencies.GetBlockers(t.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, responsePayload{"dependencies": blockers})
+}
+
+func (app *application) removeTaskDependency(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	dependsOnID, err := strconv.Atoi(vars["depends_on_id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.writableTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	err = app.models.Dependencies.Delete(t.ID, dependsOnID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	dto := &data.CreateDependencyDTO{DependsOnID: dependsOnID}
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditDependencyDelete, EntityType: "task", EntityID: t.ID}, dto, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/pafirmin/go-todo/internal/events"
+)
+
+func TestGetTaskDependencies(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid request", "/tasks/1/dependencies", http.StatusOK, []byte("Release"), "123"},
+		{"Non-existent task", "/tasks/2/dependencies", http.StatusNotFound, nil, "123"},
+		{"Forbidden user", "/tasks/1/dependencies", http.StatusForbidden, nil, "456"},
+		{"Unauthorised user", "/tasks/1/dependencies", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAddTaskDependency(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		token    string
+	}{
+		{"Valid request", "/tasks/3/dependencies", `{"depends_on_id": 1}`, http.StatusCreated, "123"},
+		{"Depends on itself", "/tasks/1/dependencies", `{"depends_on_id": 1}`, http.StatusConflict, "123"},
+		{"Non-existent blocker", "/tasks/1/dependencies", `{"depends_on_id": 2}`, http.StatusNotFound, "123"},
+		{"Invalid ID", "/tasks/1/dependencies", `{"depends_on_id": 0}`, http.StatusUnprocessableEntity, "123"},
+		{"Malformed JSON", "/tasks/1/dependencies", `{"depends_on_id": "1"}`, http.StatusBadRequest, "123"},
+		{"Forbidden user", "/tasks/3/dependencies", `{"depends_on_id": 1}`, http.StatusForbidden, "456"},
+		{"Unauthorised user", "/tasks/3/dependencies", `{"depends_on_id": 1}`, http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, tt.body, tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestRemoveTaskDependency(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		token    string
+	}{
+		{"Valid request", "/tasks/1/dependencies/3", http.StatusNoContent, "123"},
+		{"Non-existent dependency", "/tasks/1/dependencies/4", http.StatusNotFound, "123"},
+		{"Forbidden user", "/tasks/1/dependencies/3", http.StatusForbidden, "456"},
+		{"Unauthorised user", "/tasks/1/dependencies/3", http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "DELETE", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestCompletingTaskUnblocksDependents(t *testing.T) {
+	app := newTestApplication(t)
+
+	var mu sync.Mutex
+	var completed, unblocked []int
+
+	app.events.Subscribe(events.TaskCompleted, func(e events.Event) {
+		mu.Lock()
+		completed = append(completed, e.TaskID)
+		mu.Unlock()
+	})
+	app.events.Subscribe(events.TaskUnblocked, func(e events.Event) {
+		mu.Lock()
+		unblocked = append(unblocked, e.TaskID)
+		mu.Unlock()
+	})
+
+	rm := getRequestMaker(app.routes(), "PATCH", t)
+
+	r := rm("/api/v1/tasks/1", `{"title": "Renamed"}`, "123")
+	if r.Code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, r.Code)
+	}
+
+	r = rm("/api/v1/tasks/1", `{"status": "completed"}`, "123")
+	if r.Code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, r.Code)
+	}
+
+	app.wg.Wait()
+
+	if len(completed) != 1 || completed[0] != 1 {
+		t.Errorf("want task 1 completed once; got %v", completed)
+	}
+
+	if len(unblocked) != 1 || unblocked[0] != 3 {
+		t.Errorf("want task 3 unblocked; got %v", unblocked)
+	}
+}
+
+func TestCancellingTaskUnblocksDependents(t *testing.T) {
+	app := newTestApplication(t)
+
+	var mu sync.Mutex
+	var cancelled, unblocked []int
+
+	app.events.Subscribe(events.TaskCancelled, func(e events.Event) {
+		mu.Lock()
+		cancelled = append(cancelled, e.TaskID)
+		mu.Unlock()
+	})
+	app.events.Subscribe(events.TaskUnblocked, func(e events.Event) {
+		mu.Lock()
+		unblocked = append(unblocked, e.TaskID)
+		mu.Unlock()
+	})
+
+	rm := getRequestMaker(app.routes(), "PATCH", t)
+
+	r := rm("/api/v1/tasks/1", `{"status": "cancelled"}`, "123")
+	if r.Code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, r.Code)
+	}
+
+	app.wg.Wait()
+
+	if len(cancelled) != 1 || cancelled[0] != 1 {
+		t.Errorf("want task 1 cancelled once; got %v", cancelled)
+	}
+
+	if len(unblocked) != 1 || unblocked[0] != 3 {
+		t.Errorf("want task 3 unblocked; got %v", unblocked)
+	}
+}
+
+func TestGetTasksBlockedFilter(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Blocked tasks", "/tasks?blocked=true", http.StatusOK},
+		{"Unblocked tasks", "/tasks?blocked=false", http.StatusOK},
+		{"Invalid value", "/tasks?blocked=maybe", http.StatusUnprocessableEntity},
+		{"Folder tasks", "/folders/1/tasks?blocked=true", http.StatusOK},
+		{"Invalid folder value", "/folders/1/tasks?blocked=maybe", http.StatusUnprocessableEntity},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", "123")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
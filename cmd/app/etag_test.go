@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		header         string
+		value          string
+		requireIfMatch bool
+		wantCode       int
+		wantETag       string
+	}{
+		{"Get task", http.MethodGet, "/api/v1/tasks/1", "", "", "", false, http.StatusOK, `"2"`},
+		{"Get task not modified", http.MethodGet, "/api/v1/tasks/1", "", "If-None-Match", `"2"`, false, http.StatusNotModified, `"2"`},
+		{"Get task modified", http.MethodGet, "/api/v1/tasks/1", "", "If-None-Match", `"1"`, false, http.StatusOK, `"2"`},
+		{"Get folder not modified", http.MethodGet, "/api/v1/folders/1", "", "If-None-Match", `W/"2"`, false, http.StatusNotModified, `"2"`},
+		{"Update task", http.MethodPatch, "/api/v1/tasks/1", `{"title": "New"}`, "If-Match", `"2"`, false, http.StatusOK, ""},
+		{"Update stale task", http.MethodPatch, "/api/v1/tasks/1", `{"title": "New"}`, "If-Match", `"1"`, false, http.StatusPreconditionFailed, ""},
+		{"Update any task", http.MethodPatch, "/api/v1/tasks/1", `{"title": "New"}`, "If-Match", "*", false, http.StatusOK, ""},
+		{"Update without If-Match", http.MethodPatch, "/api/v1/tasks/1", `{"title": "New"}`, "", "", false, http.StatusOK, ""},
+		{"Update without required If-Match", http.MethodPatch, "/api/v1/tasks/1", `{"title": "New"}`, "", "", true, http.StatusPreconditionRequired, ""},
+		{"Delete stale task", http.MethodDelete, "/api/v1/tasks/1", "", "If-Match", `"1", "3"`, false, http.StatusPreconditionFailed, ""},
+		{"Delete task", http.MethodDelete, "/api/v1/tasks/1", "", "If-Match", `"1", "2"`, false, http.StatusNoContent, ""},
+		{"Update stale folder", http.MethodPatch, "/api/v1/folders/1", `{"name": "New"}`, "If-Match", `"1"`, false, http.StatusPreconditionFailed, ""},
+		{"Update folder", http.MethodPatch, "/api/v1/folders/1", `{"name": "New"}`, "If-Match", `"2"`, false, http.StatusOK, ""},
+		{"Delete stale folder", http.MethodDelete, "/api/v1/folders/1", "", "If-Match", `"1"`, false, http.StatusPreconditionFailed, ""},
+		{"Delete folder without required If-Match", http.MethodDelete, "/api/v1/folders/1", "", "", "", true, http.StatusPreconditionRequired, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.requireIfMatch = tt.requireIfMatch
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer 123")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			app.routes().ServeHTTP(w, req)
+
+			if code := w.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantETag != "" && w.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("want ETag %s; got %q", tt.wantETag, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
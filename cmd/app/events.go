@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/events"
+)
+
+func (app *application) registerEventHandlers() {
+	app.events.Subscribe(events.TaskCompleted, app.unblockDependents)
+	app.events.Subscribe(events.TaskCancelled, app.unblockDependents)
+	app.events.Subscribe(events.TaskUnblocked, func(e events.Event) {
+		app.infoLog.Printf("task %d unblocked", e.TaskID)
+	})
+}
+
+func (app *application) publishTaskUpdate(userID int, before, after *data.Task) {
+	switch {
+	case before.Status != data.TaskStatusCompleted && after.Status == data.TaskStatusCompleted:
+		app.events.Publish(events.Event{Type: events.TaskCompleted, UserID: userID, TaskID: after.ID})
+	case !before.Finished() && after.Status == data.TaskStatusCancelled:
+		app.events.Publish(events.Event{Type: events.TaskCancelled, UserID: userID, TaskID: after.ID})
+	}
+}
+
+func (app *application) unblockDependents(e events.Event) {
+	app.background(func() {
+		tasks, err := app.models.Dependencies.Unblocked(e.TaskID)
+		if err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+
+		for _, t := range tasks {
+			app.events.Publish(events.Event{Type: events.TaskUnblocked, UserID: e.UserID, TaskID: t.ID})
+		}
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -30,12 +31,24 @@ func (app *application) createFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if dto.ParentID != nil && !app.ownedParentFolder(w, claims.UserID, *dto.ParentID) {
+		return
+	}
+
 	f, err := app.models.Folders.Insert(claims.UserID, dto)
 	if err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, data.ErrFolderDepth):
+			v.AddError("parent_id", fmt.Sprintf("folders cannot be nested more than %d levels deep", data.MaxFolderDepth))
+			app.validationFailed(w, v)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditFolderCreate, EntityType: "folder", EntityID: f.ID}, nil, f)
+
 	app.writeJSON(w, http.StatusCreated, responsePayload{"folder": f})
 }
 
@@ -55,20 +68,39 @@ func (app *application) getFoldersByUser(w http.ResponseWriter, r *http.Request)
 	input.Filters.Page = app.intFromQuery(qs, "page", 1)
 	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
 	input.Filters.Sort = app.stringFromQuery(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"id", "name", "-id", "-name"}
+	input.Filters.Cursor = app.stringFromQuery(qs, "cursor", "")
+	input.Filters.SortSafeList = []string{"id", "name", "position", "-id", "-name", "-position"}
 
 	v := validator.New()
+
+	input.Filters.SkipCount = app.skipCountFromQuery(qs, v)
+
+	tree := app.optionalBoolFromQuery(qs, "tree", v)
+	archived := app.archivedFromQuery(qs, v)
+
 	if v.Exec(&input.Filters); !v.Valid() {
 		app.validationFailed(w, v)
 		return
 	}
 
-	folders, metadata, err := app.models.Folders.GetByUser(claims.UserID, input.Filters)
+	if tree != nil && *tree {
+		folders, err := app.models.Folders.GetTree(claims.UserID, archived)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.writeJSON(w, http.StatusOK, responsePayload{"folders": folders})
+		return
+	}
+
+	folders, metadata, err := app.models.Folders.GetByUser(claims.UserID, archived, input.Filters)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.setPageLinks(w, r, metadata)
 	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "folders": folders})
 }
 
@@ -102,6 +134,10 @@ func (app *application) getFolderByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if app.notModified(w, r, f.Version) {
+		return
+	}
+
 	app.writeJSON(w, http.StatusOK, responsePayload{"folder": f})
 }
 
@@ -135,6 +171,16 @@ func (app *application) updateFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return
+	}
+
+	version, ok := app.ifMatch(w, r, f.Version)
+	if !ok {
+		return
+	}
+
 	dto := &data.UpdateFolderDTO{}
 	err = app.readJSON(w, r, dto)
 	if err != nil {
@@ -142,18 +188,175 @@ func (app *application) updateFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dto.Version = version
+
 	v := validator.New()
 	if v.Exec(dto); !v.Valid() {
 		app.validationFailed(w, v)
 		return
 	}
 
+	if dto.ParentID != nil && *dto.ParentID != 0 && !app.ownedParentFolder(w, claims.UserID, *dto.ParentID) {
+		return
+	}
+
+	before := f
+
 	f, err = app.models.Folders.Update(id, dto)
 	if err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailed(w)
+		case errors.Is(err, data.ErrFolderCycle):
+			v.AddError("parent_id", "must not be the folder itself or one of its subfolders")
+			app.validationFailed(w, v)
+		case errors.Is(err, data.ErrFolderDepth):
+			v.AddError("parent_id", fmt.Sprintf("folders cannot be nested more than %d levels deep", data.MaxFolderDepth))
+			app.validationFailed(w, v)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditFolderUpdate, EntityType: "folder", EntityID: f.ID}, before, f)
+
+	w.Header().Set("ETag", etag(f.Version))
+	app.writeJSON(w, http.StatusOK, responsePayload{"folder": f})
+}
+
+func (app *application) moveFolder(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	f, err := app.models.Folders.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if f.UserID != claims.UserID {
+		app.forbidden(w)
+		return
+	}
+
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return
+	}
+
+	dto := &data.MoveFolderDTO{}
+	err = app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	before := f
+
+	f, err = app.models.Folders.Move(id, claims.UserID, dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, data.ErrInvalidNeighbour):
+			v.AddError("position", "after_id and before_id must be adjacent folders")
+			app.validationFailed(w, v)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditFolderUpdate, EntityType: "folder", EntityID: f.ID}, before, f)
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"folder": f})
+}
+
+func (app *application) archiveFolder(w http.ResponseWriter, r *http.Request) {
+	app.setFolderArchived(w, r, true)
+}
+
+func (app *application) unarchiveFolder(w http.ResponseWriter, r *http.Request) {
+	app.setFolderArchived(w, r, false)
+}
+
+func (app *application) setFolderArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	f, err := app.models.Folders.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if f.UserID != claims.UserID {
+		app.forbidden(w)
+		return
+	}
+
+	before := f
+	action := data.AuditFolderArchive
+
+	if archived {
+		f, err = app.models.Folders.Archive(id)
+	} else {
+		action = data.AuditFolderUnarchive
+		f, err = app.models.Folders.Unarchive(id)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, data.ErrParentArchived):
+			app.conflict(w, "the parent folder is archived; unarchive it first")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: action, EntityType: "folder", EntityID: f.ID}, before, f)
+
 	app.writeJSON(w, http.StatusOK, responsePayload{"folder": f})
 }
 
@@ -187,10 +390,52 @@ func (app *application) removeFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err = app.models.Folders.Delete(id); err != nil {
-		app.serverError(w, err)
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return
+	}
+
+	version, ok := app.ifMatch(w, r, f.Version)
+	if !ok {
+		return
+	}
+
+	if _, err = app.models.Folders.Delete(id, version); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailed(w)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditFolderDelete, EntityType: "folder", EntityID: f.ID}, f, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (app *application) ownedParentFolder(w http.ResponseWriter, userID, id int) bool {
+	f, err := app.models.Folders.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return false
+	}
+
+	if f.UserID != userID {
+		app.forbidden(w)
+		return false
+	}
+
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return false
+	}
+
+	return true
+}
@@ -59,6 +59,15 @@ func TestGetFoldersByUser(t *testing.T) {
 		{"Valid ID", "/users/me/folders", http.StatusOK, []byte("Test"), "123"},
 		{"Invalid user", "/users/me/folders", http.StatusUnauthorized, nil, "invalid"},
 		{"Trailing slash", "/users/me/folders/", http.StatusNotFound, nil, "123"},
+		{"Tree", "/users/me/folders?tree=true", http.StatusOK, []byte("Child"), "123"},
+		{"Invalid tree", "/users/me/folders?tree=foo", http.StatusUnprocessableEntity, []byte("tree"), "123"},
+		{"Archived only", "/users/me/folders?archived=only", http.StatusOK, []byte("Test"), "123"},
+		{"Invalid archived", "/users/me/folders?archived=foo", http.StatusUnprocessableEntity, []byte("archived"), "123"},
+		{"Cursor", "/users/me/folders?cursor=" + data.PageCursor{Sort: "id", Value: "1", ID: 1}.String(), http.StatusOK, []byte("Test"), "123"},
+		{"Cursor for other sort", "/users/me/folders?sort=name&cursor=" + data.PageCursor{Sort: "id", Value: "1", ID: 1}.String(), http.StatusUnprocessableEntity, []byte("cursor"), "123"},
+		{"Invalid cursor", "/users/me/folders?cursor=foo", http.StatusUnprocessableEntity, []byte("cursor"), "123"},
+		{"Skip count", "/users/me/folders?count=false", http.StatusOK, []byte("Test"), "123"},
+		{"Invalid count", "/users/me/folders?count=foo", http.StatusUnprocessableEntity, []byte("count"), "123"},
 	}
 	rm := getRequestMaker(app.routes(), "GET", t)
 
@@ -80,6 +89,8 @@ func TestGetFoldersByUser(t *testing.T) {
 func TestCreateFolder(t *testing.T) {
 	app := newTestApplication(t)
 
+	parent, missing := 1, 2
+
 	tests := []struct {
 		name     string
 		urlPath  string
@@ -96,6 +107,10 @@ func TestCreateFolder(t *testing.T) {
 			&data.CreateFolderDTO{Name: "Test"}},
 		{"Invalid body", "/users/me/folders", http.StatusUnprocessableEntity, nil, "123",
 			&data.CreateFolderDTO{Name: ""}},
+		{"Valid parent", "/users/me/folders", http.StatusCreated, []byte("Test"), "123",
+			&data.CreateFolderDTO{Name: "Test", ParentID: &parent}},
+		{"Missing parent", "/users/me/folders", http.StatusNotFound, nil, "123",
+			&data.CreateFolderDTO{Name: "Test", ParentID: &missing}},
 	}
 	rm := getRequestMaker(app.routes(), "POST", t)
 
@@ -115,6 +130,44 @@ func TestCreateFolder(t *testing.T) {
 	}
 }
 
+func TestUpdateFolder(t *testing.T) {
+	app := newTestApplication(t)
+
+	name, root, child, missing := "Renamed", 0, 4, 2
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+		dto      *data.UpdateFolderDTO
+	}{
+		{"Valid request", "/folders/1", http.StatusOK, []byte("Test"), "123", &data.UpdateFolderDTO{Name: &name}},
+		{"Move to top level", "/folders/4", http.StatusOK, []byte("Test"), "123", &data.UpdateFolderDTO{ParentID: &root}},
+		{"Move under child", "/folders/1", http.StatusUnprocessableEntity, []byte("parent_id"), "123", &data.UpdateFolderDTO{ParentID: &child}},
+		{"Missing parent", "/folders/1", http.StatusNotFound, nil, "123", &data.UpdateFolderDTO{ParentID: &missing}},
+		{"Forbidden user", "/folders/1", http.StatusForbidden, nil, "456", &data.UpdateFolderDTO{Name: &name}},
+		{"Invalid user", "/folders/1", http.StatusUnauthorized, nil, "invalid", &data.UpdateFolderDTO{Name: &name}},
+	}
+	rm := getRequestMaker(app.routes(), "PATCH", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.dto)
+			r := rm("/api/v1"+tt.urlPath, string(body), tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
 func TestDeleteFolder(t *testing.T) {
 	app := newTestApplication(t)
 
@@ -142,3 +195,111 @@ func TestDeleteFolder(t *testing.T) {
 		})
 	}
 }
+
+func TestMoveFolder(t *testing.T) {
+	app := newTestApplication(t)
+
+	one, two := 1, 2
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+		dto      *data.MoveFolderDTO
+	}{
+		{"Move to end", "/folders/1/move", http.StatusOK, []byte("Test"), "123", &data.MoveFolderDTO{}},
+		{"Valid neighbour", "/folders/1/move", http.StatusOK, []byte("Test"), "123", &data.MoveFolderDTO{BeforeID: &one}},
+		{"Unknown neighbour", "/folders/1/move", http.StatusUnprocessableEntity, []byte("position"), "123", &data.MoveFolderDTO{AfterID: &two}},
+		{"Forbidden user", "/folders/1/move", http.StatusForbidden, nil, "456", &data.MoveFolderDTO{}},
+		{"Invalid user", "/folders/1/move", http.StatusUnauthorized, nil, "invalid", &data.MoveFolderDTO{}},
+		{"Non-existent ID", "/folders/2/move", http.StatusNotFound, nil, "123", &data.MoveFolderDTO{}},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.dto)
+			r := rm("/api/v1"+tt.urlPath, string(body), tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestArchiveFolder(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Archive", "/folders/1/archive", http.StatusOK, []byte("archived_at"), "123"},
+		{"Unarchive", "/folders/5/unarchive", http.StatusOK, []byte("Archived"), "123"},
+		{"Forbidden user", "/folders/1/archive", http.StatusForbidden, nil, "456"},
+		{"Invalid user", "/folders/1/archive", http.StatusUnauthorized, nil, "invalid"},
+		{"Non-existent ID", "/folders/2/unarchive", http.StatusNotFound, nil, "123"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestArchivedFolderIsReadOnly(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		body     string
+		wantCode int
+	}{
+		{"Read task", "GET", "/tasks/5", "", http.StatusOK},
+		{"Read folder tasks", "GET", "/folders/5/tasks", "", http.StatusOK},
+		{"Rename folder", "PATCH", "/folders/5", `{"name": "Renamed"}`, http.StatusConflict},
+		{"Move folder", "POST", "/folders/5/move", `{}`, http.StatusConflict},
+		{"Delete folder", "DELETE", "/folders/5", "", http.StatusConflict},
+		{"Create subfolder", "POST", "/users/me/folders", `{"name": "Sub", "parent_id": 5}`, http.StatusConflict},
+		{"Create task", "POST", "/folders/5/tasks", `{"title": "Test", "datetime": "2022-01-01T00:00:00Z"}`, http.StatusConflict},
+		{"Update task", "PATCH", "/tasks/5", `{"title": "Renamed"}`, http.StatusConflict},
+		{"Move task out", "POST", "/tasks/5/move", `{"folder_id": 1}`, http.StatusConflict},
+		{"Move task in", "POST", "/tasks/1/move", `{"folder_id": 5}`, http.StatusConflict},
+		{"Delete task", "DELETE", "/tasks/5", "", http.StatusConflict},
+		{"Comment on task", "POST", "/tasks/5/comments", `{"body": "Test"}`, http.StatusConflict},
+		{"Add dependency", "POST", "/tasks/5/dependencies", `{"depends_on_id": 1}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := getRequestMaker(app.routes(), tt.method, t)
+			r := rm("/api/v1"+tt.urlPath, tt.body, "123")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/filter"
 	"github.com/pafirmin/go-todo/internal/jwt"
 	"github.com/pafirmin/go-todo/internal/validator"
 )
@@ -52,10 +57,49 @@ func (app *application) forbidden(w http.ResponseWriter) {
 	app.errorResponse(w, http.StatusForbidden, msg)
 }
 
+func (app *application) conflict(w http.ResponseWriter, msg string) {
+	app.errorResponse(w, http.StatusConflict, msg)
+}
+
+func (app *application) folderArchived(w http.ResponseWriter) {
+	app.conflict(w, "archived folders and their tasks cannot be modified")
+}
+
+func (app *application) preconditionFailed(w http.ResponseWriter) {
+	msg := "the resource has been modified since it was last fetched"
+	app.errorResponse(w, http.StatusPreconditionFailed, msg)
+}
+
+func (app *application) preconditionRequired(w http.ResponseWriter) {
+	msg := "this request must include an If-Match header"
+	app.errorResponse(w, http.StatusPreconditionRequired, msg)
+}
+
+func (app *application) payloadTooLarge(w http.ResponseWriter, msg string) {
+	app.errorResponse(w, http.StatusRequestEntityTooLarge, msg)
+}
+
 func (app *application) rateLimitExceeded(w http.ResponseWriter) {
 	app.errorResponse(w, http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
 }
 
+func (app *application) tooManyLoginAttempts(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	msg := "too many failed login attempts, please try again later"
+	app.errorResponse(w, http.StatusTooManyRequests, msg)
+}
+
+func (app *application) accountLocked(w http.ResponseWriter) {
+	msg := "this account has been temporarily locked"
+	app.errorResponse(w, http.StatusLocked, msg)
+}
+
+func (app *application) accountDisabled(w http.ResponseWriter) {
+	msg := "this account has been disabled"
+	app.errorResponse(w, http.StatusForbidden, msg)
+}
+
 func (app *application) claimsFromContext(ctx context.Context) (*jwt.UserClaims, bool) {
 	claims, ok := ctx.Value(ctxKeyUserClaims).(*jwt.UserClaims)
 
@@ -155,8 +199,137 @@ func (app *application) intFromQuery(qs url.Values, key string, defaultValue int
 	return i
 }
 
-func (app *application) dateFromQuery(qs url.Values, key string, defaultValue time.Time) time.Time {
-	t, err := time.Parse("2006-01-02", qs.Get(key))
+func (app *application) optionalBoolFromQuery(qs url.Values, key string, v *validator.Validator) *bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return nil
+	}
+
+	return &b
+}
+
+func (app *application) archivedFromQuery(qs url.Values, v *validator.Validator) string {
+	s := app.stringFromQuery(qs, "archived", data.ArchivedExclude)
+	v.PermittedValue("archived", s, data.ArchivedExclude, data.ArchivedInclude, data.ArchivedOnly)
+
+	return s
+}
+
+// skipCountFromQuery reports whether count=false was given, to leave the
+// total number of records out of a page.
+func (app *application) skipCountFromQuery(qs url.Values, v *validator.Validator) bool {
+	count := app.optionalBoolFromQuery(qs, "count", v)
+
+	return count != nil && !*count
+}
+
+// filterFromQuery parses the filter expression given as filter, if any.
+// Whether its fields and values make sense is checked by the model.
+func (app *application) filterFromQuery(qs url.Values, v *validator.Validator) filter.Expr {
+	s := qs.Get("filter")
+	if s == "" {
+		return nil
+	}
+
+	e, err := filter.Parse(s)
+	if err != nil {
+		v.AddError("filter", err.Error())
+		return nil
+	}
+
+	return e
+}
+
+// setPageLinks sets a Link header pointing at the pages before and after
+// the current one, if there are any.
+func (app *application) setPageLinks(w http.ResponseWriter, r *http.Request, metadata data.MetaData) {
+	var links []string
+
+	for _, l := range []struct{ rel, cursor string }{{"next", metadata.NextCursor}, {"prev", metadata.PrevCursor}} {
+		if l.cursor == "" {
+			continue
+		}
+
+		u := *r.URL
+		qs := u.Query()
+		qs.Del("page")
+		qs.Set("cursor", l.cursor)
+		u.RawQuery = qs.Encode()
+
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), l.rel))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// locationFromQuery loads the IANA time zone named by key.
+func (app *application) locationFromQuery(qs url.Values, key string, defaultValue *time.Location, v *validator.Validator) *time.Location {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	loc, err := time.LoadLocation(s)
+	if err != nil || s == "Local" {
+		v.AddError(key, "must be an IANA time zone, such as Europe/London")
+		return defaultValue
+	}
+
+	return loc
+}
+
+// localDateFromQuery returns the start of the day given as key in loc.
+func (app *application) localDateFromQuery(qs url.Values, key string, defaultValue time.Time, loc *time.Location, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		v.AddError(key, "must be a date in the format yyyy-MM-dd")
+		return defaultValue
+	}
+
+	return t
+}
+
+const (
+	defaultRangeDays = 7
+	maxRangeDays     = 62
+)
+
+// dayRangeFromQuery returns the start of the first and last days of the range
+// given by from and to in loc. The range defaults to the week starting today,
+// and can cover up to maxRangeDays.
+func (app *application) dayRangeFromQuery(qs url.Values, loc *time.Location, v *validator.Validator) (from, to time.Time) {
+	y, m, d := time.Now().In(loc).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+
+	from = app.localDateFromQuery(qs, "from", today, loc, v)
+	to = app.localDateFromQuery(qs, "to", from.AddDate(0, 0, defaultRangeDays-1), loc, v)
+
+	if v.Valid() {
+		v.Check(!to.Before(from), "to", "must not be before from")
+		v.Check(to.Before(from.AddDate(0, 0, maxRangeDays)), "to", fmt.Sprintf("must be within %d days of from", maxRangeDays))
+	}
+
+	return from, to
+}
+
+// dateFromQuery returns the start of the day given as key in loc, ignoring
+// invalid dates.
+func (app *application) dateFromQuery(qs url.Values, key string, defaultValue time.Time, loc *time.Location) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", qs.Get(key), loc)
 	if err != nil {
 		return defaultValue
 	}
@@ -164,6 +337,123 @@ func (app *application) dateFromQuery(qs url.Values, key string, defaultValue ti
 	return t
 }
 
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+func etagMatches(header []string, tag string) bool {
+	for _, t := range strings.Split(strings.Join(header, ","), ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified sets the ETag of the resource being read and responds with 304
+// Not Modified if it matches If-None-Match.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	tag := etag(version)
+	w.Header().Set("ETag", tag)
+
+	if etagMatches(r.Header.Values("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// ifMatch checks If-Match against the current version of the resource being
+// changed. It returns the version the change must be conditional on, which
+// is 0 if there is no If-Match header.
+func (app *application) ifMatch(w http.ResponseWriter, r *http.Request, version int) (int, bool) {
+	header := r.Header.Values("If-Match")
+
+	if len(header) == 0 {
+		if app.config.requireIfMatch {
+			app.preconditionRequired(w)
+			return 0, false
+		}
+		return 0, true
+	}
+
+	if !etagMatches(header, etag(version)) {
+		app.preconditionFailed(w)
+		return 0, false
+	}
+
+	return version, true
+}
+
+// responseRecorder captures a response so that it can be inspected before
+// being passed on.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header)}
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	return rec.body.Write(b)
+}
+
+// Status returns the status code written, defaulting to 200 OK as
+// http.ResponseWriter does.
+func (rec *responseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
+
+// writeTo passes the captured response on to w.
+func (rec *responseRecorder) writeTo(w http.ResponseWriter) {
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+
+	w.WriteHeader(rec.Status())
+	w.Write(rec.body.Bytes())
+}
+
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Print(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
 func Version() string {
 	var revision string
 	var modified bool
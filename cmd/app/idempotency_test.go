@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	app := newTestApplication(t)
+
+	// The mock store outlives the test, so keys must be unique to each run.
+	prefix := fmt.Sprint(time.Now().UnixNano())
+	signup := `{"email": "mock@example.com", "first_name": "Test", "last_name": "McTest", "password": "Test1234"}`
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		key          string
+		wantCode     int
+		wantReplayed bool
+	}{
+		{"First request", http.MethodPost, "/api/v1/users/me/folders", `{"name": "Work"}`, prefix + "-a", http.StatusCreated, false},
+		{"Retry", http.MethodPost, "/api/v1/users/me/folders", `{"name": "Work"}`, prefix + "-a", http.StatusCreated, true},
+		{"Different body", http.MethodPost, "/api/v1/users/me/folders", `{"name": "Home"}`, prefix + "-a", http.StatusUnprocessableEntity, false},
+		{"Different path", http.MethodPost, "/api/v1/folders/1/tasks", `{"name": "Work"}`, prefix + "-a", http.StatusUnprocessableEntity, false},
+		{"Failed request", http.MethodPost, "/api/v1/users/me/folders", `{"name": ""}`, prefix + "-b", http.StatusUnprocessableEntity, false},
+		{"Retry failed request", http.MethodPost, "/api/v1/users/me/folders", `{"name": ""}`, prefix + "-b", http.StatusUnprocessableEntity, true},
+		{"In flight", http.MethodPost, "/api/v1/users/me/folders", `{"name": "Work"}`, "in-flight", http.StatusConflict, false},
+		{"Key too long", http.MethodPost, "/api/v1/users/me/folders", `{"name": "Work"}`, strings.Repeat("a", maxIdempotencyKeyLength+1), http.StatusBadRequest, false},
+		{"Signup", http.MethodPost, "/api/v1/users", signup, prefix + "-c", http.StatusCreated, false},
+		{"Retry signup", http.MethodPost, "/api/v1/users", signup, prefix + "-c", http.StatusCreated, true},
+		{"Ignored on login", http.MethodPost, "/api/v1/auth/login", `{"email": "mock@example.com", "password": "Test1234"}`, "in-flight", http.StatusOK, false},
+		{"Ignored on GET", http.MethodGet, "/api/v1/folders/1", "", "in-flight", http.StatusOK, false},
+	}
+
+	var first []byte
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer 123")
+			req.Header.Set("Idempotency-Key", tt.key)
+
+			app.routes().ServeHTTP(w, req)
+
+			if code := w.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("want replayed %v; got %v", tt.wantReplayed, replayed)
+			}
+
+			switch tt.name {
+			case "First request":
+				first = w.Body.Bytes()
+			case "Retry":
+				if !bytes.Equal(w.Body.Bytes(), first) {
+					t.Errorf("want replayed body %s; got %s", first, w.Body.Bytes())
+				}
+			}
+		})
+	}
+}
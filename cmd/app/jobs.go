@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (app *application) startJobs() {
+	app.runPeriodically("guest cleanup", time.Hour, app.removeInactiveGuests)
+	app.runPeriodically("trash purge", time.Hour, app.purgeTrash)
+	app.runPeriodically("attachment cleanup", time.Hour, app.removeOrphanedAttachments)
+	app.runPeriodically("idempotency key cleanup", time.Hour, app.removeExpiredIdempotencyKeys)
+}
+
+func (app *application) runPeriodically(name string, interval time.Duration, fn func() error) {
+	run := func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Print(fmt.Errorf("%s: %s", name, err))
+			}
+		}()
+
+		if err := fn(); err != nil {
+			app.errorLog.Printf("%s: %s", name, err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			run()
+		}
+	}()
+}
+
+func (app *application) removeInactiveGuests() error {
+	n, err := app.models.Users.DeleteInactiveGuests(time.Now().Add(-app.config.guestTTL))
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		app.infoLog.Printf("removed %d inactive guest accounts", n)
+	}
+
+	return nil
+}
+
+func (app *application) purgeTrash() error {
+	n, err := app.models.Trash.Purge(time.Now().Add(-app.config.trashRetention))
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		app.infoLog.Printf("purged %d items from the trash", n)
+	}
+
+	return nil
+}
+
+func (app *application) removeOrphanedAttachments() error {
+	attachments, err := app.models.Attachments.Orphans(100)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		err = app.blobs.Delete(context.Background(), a.StorageKey)
+		if err != nil {
+			return err
+		}
+
+		err = app.models.Attachments.Delete(a.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(attachments) > 0 {
+		app.infoLog.Printf("removed %d orphaned attachments", len(attachments))
+	}
+
+	return nil
+}
+
+func (app *application) removeExpiredIdempotencyKeys() error {
+	n, err := app.models.Idempotency.DeleteExpired()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		app.infoLog.Printf("removed %d expired idempotency keys", n)
+	}
+
+	return nil
+}
@@ -1,15 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
+	// Time zones are looked up for users, so don't depend on the host
+	// having a zone database.
+	_ "time/tzdata"
 
 	_ "github.com/lib/pq"
+	"github.com/pafirmin/go-todo/internal/blob"
 	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/events"
 	"github.com/pafirmin/go-todo/internal/jwt"
+	"github.com/pafirmin/go-todo/internal/mailer"
+	"github.com/pafirmin/go-todo/internal/oidc"
 )
 
 var (
@@ -17,10 +28,25 @@ var (
 )
 
 type jwtService interface {
-	Sign(int, time.Time) (string, error)
+	Sign(jwt.UserClaims, time.Time) (string, error)
 	Parse(string) (*jwt.UserClaims, error)
 }
 
+type mailService interface {
+	Send(string, string, interface{}) error
+}
+
+type blobStore interface {
+	Put(context.Context, string, io.Reader, int64, string) error
+	Get(context.Context, string) (io.ReadCloser, error)
+	Delete(context.Context, string) error
+}
+
+type modelsTx interface {
+	Commit() error
+	Rollback() error
+}
+
 type config struct {
 	port    int
 	dbAddr  string
@@ -30,14 +56,56 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+	lockout struct {
+		maxAttempts   int
+		maxIPAttempts int
+		window        time.Duration
+		duration      time.Duration
+	}
+	guestTTL       time.Duration
+	requireIfMatch bool
+	idempotencyTTL time.Duration
+	trashRetention time.Duration
+	oidcConfig     string
+	smtp           struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
+	storage struct {
+		backend string
+		dir     string
+		s3      struct {
+			endpoint  string
+			region    string
+			bucket    string
+			accessKey string
+			secretKey string
+		}
+	}
+	attachments struct {
+		maxSize int64
+		quota   int64
+		secret  string
+		urlTTL  time.Duration
+	}
 }
 
 type application struct {
-	config     config
-	errorLog   *log.Logger
-	infoLog    *log.Logger
-	jwtService jwtService
-	models     data.Models
+	config        config
+	errorLog      *log.Logger
+	infoLog       *log.Logger
+	jwtService    jwtService
+	mailer        mailService
+	models        data.Models
+	beginModels   func(context.Context) (data.Models, modelsTx, error)
+	tx            *requestTx
+	blobs         blobStore
+	events        *events.Bus
+	oidcProviders map[string]*oidc.Provider
+	wg            *sync.WaitGroup
 }
 
 func main() {
@@ -51,12 +119,51 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 40, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 	flag.StringVar(&secret, "jwt-secret", "", "JWT Secret key")
+	flag.IntVar(&cfg.lockout.maxAttempts, "lockout-max-attempts", 5, "Failed logins before an account is locked")
+	flag.IntVar(&cfg.lockout.maxIPAttempts, "lockout-max-ip-attempts", 50, "Failed logins from a single IP before it is blocked")
+	flag.DurationVar(&cfg.lockout.window, "lockout-window", 15*time.Minute, "Window in which failed logins are counted")
+	flag.DurationVar(&cfg.lockout.duration, "lockout-duration", 30*time.Minute, "How long an account stays locked")
+	flag.DurationVar(&cfg.guestTTL, "guest-ttl", 24*time.Hour, "Inactivity period after which guest accounts are removed")
+	flag.DurationVar(&cfg.trashRetention, "trash-retention", 30*24*time.Hour, "How long deleted folders and tasks are kept before being purged")
+	flag.BoolVar(&cfg.requireIfMatch, "require-if-match", false, "Reject updates and deletes of tasks and folders without an If-Match header")
+	flag.DurationVar(&cfg.idempotencyTTL, "idempotency-ttl", 24*time.Hour, "How long responses to requests with an Idempotency-Key are kept for replay")
+	flag.StringVar(&cfg.oidcConfig, "oidc-config", "", "Path to a JSON file of OpenID Connect providers")
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "localhost", "SMTP host")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "go-todo <no-reply@go-todo.local>", "SMTP sender")
+	flag.StringVar(&cfg.storage.backend, "storage", "local", "Attachment storage backend (local|s3)")
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory for the local storage backend")
+	flag.StringVar(&cfg.storage.s3.endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	flag.StringVar(&cfg.storage.s3.region, "s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.storage.s3.bucket, "s3-bucket", "", "S3 bucket")
+	flag.StringVar(&cfg.storage.s3.accessKey, "s3-access-key", "", "S3 access key")
+	flag.StringVar(&cfg.storage.s3.secretKey, "s3-secret-key", "", "S3 secret key")
+	flag.Int64Var(&cfg.attachments.maxSize, "attachment-max-size", 10<<20, "Maximum size of a single attachment in bytes")
+	flag.Int64Var(&cfg.attachments.quota, "attachment-quota", 100<<20, "Total attachment storage allowed per user in bytes")
+	flag.StringVar(&cfg.attachments.secret, "attachment-url-secret", "", "Key used to sign attachment download URLs (defaults to the JWT secret)")
+	flag.DurationVar(&cfg.attachments.urlTTL, "attachment-url-ttl", 15*time.Minute, "How long signed attachment download URLs remain valid")
 
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.LstdFlags)
 	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	oidcProviders, err := oidc.LoadProviders(cfg.oidcConfig)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	if cfg.attachments.secret == "" {
+		cfg.attachments.secret = secret
+	}
+
+	blobs, err := openBlobStore(cfg)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	db, err := openDB(cfg.dbAddr)
 	if err != nil {
 		errorLog.Fatal(err)
@@ -67,13 +174,22 @@ func main() {
 	infoLog.Print("database connection pool established")
 
 	app := &application{
-		config:     cfg,
-		errorLog:   errorLog,
-		infoLog:    infoLog,
-		models:     data.NewModels(db),
-		jwtService: jwt.NewService([]byte(secret)),
+		config:        cfg,
+		errorLog:      errorLog,
+		infoLog:       infoLog,
+		models:        data.NewModels(db),
+		beginModels:   txModels(db),
+		blobs:         blobs,
+		events:        events.New(),
+		jwtService:    jwt.NewService([]byte(secret)),
+		mailer:        mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		oidcProviders: oidcProviders,
+		wg:            &sync.WaitGroup{},
 	}
 
+	app.registerEventHandlers()
+	app.startJobs()
+
 	err = app.serve()
 	if err != nil {
 		errorLog.Fatal(err, nil)
@@ -92,3 +208,28 @@ func openDB(dsn string) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// txModels returns a function that starts a transaction and binds a set of
+// models to it.
+func txModels(db *sql.DB) func(context.Context) (data.Models, modelsTx, error) {
+	return func(ctx context.Context) (data.Models, modelsTx, error) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return data.Models{}, nil, err
+		}
+
+		return data.NewModels(tx), tx, nil
+	}
+}
+
+func openBlobStore(cfg config) (blobStore, error) {
+	switch cfg.storage.backend {
+	case "local":
+		return blob.NewLocalStore(cfg.storage.dir)
+	case "s3":
+		s3 := cfg.storage.s3
+		return blob.NewS3Store(s3.endpoint, s3.region, s3.bucket, s3.accessKey, s3.secretKey)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.storage.backend)
+	}
+}
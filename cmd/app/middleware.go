@@ -1,13 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
 	"github.com/tomasen/realip"
 	"golang.org/x/time/rate"
 )
@@ -115,7 +122,148 @@ func (app *application) requireAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		if claims.ImpersonatorID != 0 {
+			app.infoLog.Printf("user %d impersonating user %d - %s %s", claims.ImpersonatorID, claims.UserID, r.Method, r.URL.RequestURI())
+		}
+
 		ctx := context.WithValue(r.Context(), ctxKeyUserClaims, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := app.claimsFromContext(r.Context())
+			if !ok {
+				app.unauthorized(w)
+				return
+			}
+
+			if claims.Role != role || claims.ImpersonatorID != 0 {
+				app.forbidden(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+const maxIdempotencyKeyLength = 255
+
+// idempotent makes a POST request with an Idempotency-Key header safe to
+// retry: the response to the first request is stored, and replayed to any
+// retry with the same key instead of running the handler again. Keys are
+// scoped to the user, so this must come after requireAuth.
+func (app *application) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			app.badRequest(w, fmt.Sprintf("Idempotency-Key must not be longer than %d characters", maxIdempotencyKeyLength))
+			return
+		}
+
+		// Requests made without logging in share a scope, so their keys
+		// are made specific to the client.
+		var userID int
+		if claims, ok := app.claimsFromContext(r.Context()); ok {
+			userID = claims.UserID
+		} else {
+			sum := sha256.Sum256([]byte(realip.FromRequest(r) + "\n" + key))
+			key = hex.EncodeToString(sum[:])
+		}
+
+		// The body is read up front to fingerprint the request. The limit
+		// only needs to accommodate the largest upload; handlers still
+		// apply their own.
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, app.config.attachments.maxSize+1<<20))
+		if err != nil {
+			app.payloadTooLarge(w, "request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+		h.Write(body)
+		fingerprint := hex.EncodeToString(h.Sum(nil))
+
+		// The request is cut off at its deadline, and the key stays claimed
+		// until a database statement started just before then has timed
+		// out too, so that a retry can't run alongside it.
+		deadline := time.Now().Add(data.IdempotencyLockTimeout)
+		if d, ok := r.Context().Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		stored, err := app.models.Idempotency.Begin(userID, key, fingerprint, deadline.Add(5*time.Second), time.Now().Add(app.config.idempotencyTTL))
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrIdempotencyKeyReused):
+				v := validator.New()
+				v.AddError("Idempotency-Key", "has already been used for a different request")
+				app.validationFailed(w, v)
+			case errors.Is(err, data.ErrIdempotencyInFlight):
+				w.Header().Set("Retry-After", "1")
+				app.conflict(w, "a request with this Idempotency-Key is still in progress")
+			default:
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		if stored != nil {
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		completed := false
+
+		// Release the key if the handler fails or panics, so that the
+		// request can be retried.
+		defer func() {
+			if !completed {
+				if err := app.models.Idempotency.Release(userID, key); err != nil {
+					app.errorLog.Print(err)
+				}
+			}
+		}()
+
+		rec := newResponseRecorder()
+		next.ServeHTTP(rec, r)
+
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(rec.Status())
+		w.Write(rec.body.Bytes())
+
+		if rec.Status() >= 500 {
+			return
+		}
+
+		res := &data.IdempotentResponse{Status: rec.Status(), Header: rec.header, Body: rec.body.Bytes()}
+
+		if err := app.models.Idempotency.Complete(userID, key, res); err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+
+		completed = true
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/oidc"
+)
+
+const oidcCookieName = "oidc_flow"
+
+type oidcFlow struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (app *application) oidcStart(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+
+	p, ok := app.oidcProviders[name]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	flow := oidcFlow{Provider: name}
+
+	for _, dst := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		s, err := oidc.RandomString()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		*dst = s
+	}
+
+	authURL, err := p.AuthCodeURL(r.Context(), flow.State, flow.Nonce, oidc.CodeChallenge(flow.Verifier))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	b, err := json.Marshal(flow)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     "/api/v1/auth/oidc/",
+		Expires:  time.Now().Add(10 * time.Minute),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (app *application) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+
+	p, ok := app.oidcProviders[name]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		app.unauthorized(w)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    "",
+		Path:     "/api/v1/auth/oidc/",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+	})
+
+	flow := oidcFlow{}
+
+	b, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || json.Unmarshal(b, &flow) != nil {
+		app.unauthorized(w)
+		return
+	}
+
+	qs := r.URL.Query()
+
+	if flow.Provider != name || flow.State == "" || qs.Get("state") != flow.State {
+		app.unauthorized(w)
+		return
+	}
+
+	if qs.Get("error") != "" {
+		app.errorResponse(w, http.StatusUnauthorized, "identity provider returned an error: "+qs.Get("error"))
+		return
+	}
+
+	token, err := p.Exchange(r.Context(), qs.Get("code"), flow.Verifier, flow.Nonce)
+	if err != nil {
+		app.infoLog.Print(err)
+		app.unauthorized(w)
+		return
+	}
+
+	u, err := app.models.Identities.GetUser(name, token.Subject)
+	if err == nil {
+		app.startSession(w, r, u, "oidc:"+name)
+		return
+	}
+
+	if !errors.Is(err, data.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	if !token.EmailVerified || token.Email == "" {
+		app.errorResponse(w, http.StatusForbidden, "identity provider did not supply a verified email address")
+		return
+	}
+
+	u, err = app.models.Users.GetByEmail(strings.ToLower(token.Email))
+	if err != nil {
+		if !errors.Is(err, data.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		u, err = app.createOIDCUser(token)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	err = app.models.Identities.Insert(name, token.Subject, u.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.startSession(w, r, u, "oidc:"+name)
+}
+
+func (app *application) createOIDCUser(token *oidc.IDToken) (*data.User, error) {
+	password, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+
+	dto := &data.CreateUserDTO{
+		Email:     strings.ToLower(token.Email),
+		Password:  password,
+		FirstName: token.GivenName,
+		LastName:  token.FamilyName,
+	}
+
+	if dto.FirstName == "" {
+		dto.FirstName = strings.Split(token.Email, "@")[0]
+	}
+
+	return app.models.Users.Insert(dto)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	goJwt "github.com/golang-jwt/jwt/v5"
+	"github.com/pafirmin/go-todo/internal/oidc"
+)
+
+type fakeAuthRequest struct {
+	challenge string
+	nonce     string
+}
+
+type fakeIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu       sync.Mutex
+	requests map[string]fakeAuthRequest
+	claims   goJwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &fakeIdP{key: key, requests: make(map[string]fakeAuthRequest)}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+
+		if qs.Get("code_challenge_method") != "S256" {
+			http.Error(w, "PKCE required", http.StatusBadRequest)
+			return
+		}
+
+		idp.mu.Lock()
+		code := fmt.Sprintf("code-%d", len(idp.requests))
+		idp.requests[code] = fakeAuthRequest{challenge: qs.Get("code_challenge"), nonce: qs.Get("nonce")}
+		idp.mu.Unlock()
+
+		redirect, _ := url.Parse(qs.Get("redirect_uri"))
+		rqs := redirect.Query()
+		rqs.Set("code", code)
+		rqs.Set("state", qs.Get("state"))
+		redirect.RawQuery = rqs.Encode()
+
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		idp.mu.Lock()
+		req, ok := idp.requests[r.PostForm.Get("code")]
+		delete(idp.requests, r.PostForm.Get("code"))
+		claims := goJwt.MapClaims{}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		idp.mu.Unlock()
+
+		if !ok || oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != req.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims["iss"] = idp.URL
+		claims["aud"] = r.PostForm.Get("client_id")
+		claims["nonce"] = req.nonce
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+
+		token := goJwt.NewWithClaims(goJwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+
+		signed, err := token.SignedString(idp.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+			}},
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newFakeIdP(t)
+
+	app := newTestApplication(t)
+	app.oidcProviders = map[string]*oidc.Provider{
+		"test": oidc.NewProvider(oidc.Config{
+			Name:        "test",
+			Issuer:      idp.URL,
+			ClientID:    "go-todo",
+			RedirectURL: "http://localhost/api/v1/auth/oidc/test/callback",
+		}),
+	}
+
+	routes := app.routes()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	tests := []struct {
+		name     string
+		claims   goJwt.MapClaims
+		badState bool
+		wantCode int
+		wantBody []byte
+	}{
+		{"Linked identity", goJwt.MapClaims{"sub": "linked-subject"}, false, http.StatusOK, []byte("mock@example.com")},
+		{"Link by verified email", goJwt.MapClaims{"sub": "other-subject", "email": "mock@example.com", "email_verified": true},
+			false, http.StatusOK, []byte("access_token")},
+		{"Link by email in another case", goJwt.MapClaims{"sub": "other-subject", "email": "Mock@Example.com", "email_verified": true},
+			false, http.StatusOK, []byte("access_token")},
+		{"New account", goJwt.MapClaims{"sub": "new-subject", "email": "new@example.com", "email_verified": true, "given_name": "New"},
+			false, http.StatusOK, []byte("access_token")},
+		{"Unverified email", goJwt.MapClaims{"sub": "other-subject", "email": "mock@example.com", "email_verified": false},
+			false, http.StatusForbidden, nil},
+		{"Tampered state", goJwt.MapClaims{"sub": "linked-subject"}, true, http.StatusUnauthorized, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.mu.Lock()
+			idp.claims = tt.claims
+			idp.mu.Unlock()
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/auth/oidc/test/start", nil)
+			routes.ServeHTTP(w, req)
+
+			if w.Code != http.StatusFound {
+				t.Fatalf("start: want %d; got %d", http.StatusFound, w.Code)
+			}
+
+			cookies := w.Result().Cookies()
+
+			rsp, err := client.Get(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rsp.Body.Close()
+
+			callback, err := url.Parse(rsp.Header.Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.badState {
+				qs := callback.Query()
+				qs.Set("state", "forged")
+				callback.RawQuery = qs.Encode()
+			}
+
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+			for _, c := range cookies {
+				req.AddCookie(c)
+			}
+			routes.ServeHTTP(w, req)
+
+			if code := w.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d (%s)", tt.wantCode, code, w.Body)
+			}
+
+			if body := w.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestOIDCStartUnknownProvider(t *testing.T) {
+	app := newTestApplication(t)
+
+	rm := getRequestMaker(app.routes(), "GET", t)
+	r := rm("/api/v1/auth/oidc/unknown/start", "", "")
+
+	if code := r.Code; code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestOIDCCallbackWithoutFlow(t *testing.T) {
+	app := newTestApplication(t)
+	app.oidcProviders = map[string]*oidc.Provider{"test": oidc.NewProvider(oidc.Config{Name: "test"})}
+
+	rm := getRequestMaker(app.routes(), "GET", t)
+	r := rm("/api/v1/auth/oidc/test/callback?code=abc&state=def", "", "")
+
+	if code := r.Code; code != http.StatusUnauthorized {
+		t.Errorf("want %d; got %d", http.StatusUnauthorized, code)
+	}
+}
@@ -5,42 +5,115 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
+	"github.com/pafirmin/go-todo/internal/data"
 	"github.com/rs/cors"
 )
 
 func (app *application) routes() http.Handler {
+	standardMiddleware := alice.New(app.recoverPanic, defaultHeaders, cors.Default().Handler, app.logRequest, app.rateLimit)
+
+	return standardMiddleware.Then(app.router())
+}
+
+// router serves the API without the standard middleware, so that batch
+// operations can be dispatched through it.
+func (app *application) router() *mux.Router {
 	r := mux.NewRouter()
 	s := r.PathPrefix("/api/v1/").Subrouter()
-	standardMiddleware := alice.New(app.recoverPanic, defaultHeaders, cors.Default().Handler, app.logRequest, app.rateLimit)
-	authMiddleware := alice.New(app.requireAuth)
+	authMiddleware := alice.New(app.requireAuth, app.idempotent)
+	// Login and unlock responses hold credentials, which must not be stored
+	// for replay, so only signups are idempotent without logging in.
+	publicMiddleware := alice.New(app.idempotent)
+	adminMiddleware := alice.New(app.requireAuth, app.requireRole(data.RoleAdmin), app.idempotent)
 
 	s.HandleFunc("/status", app.showStatus).Methods(http.MethodGet)
+	s.Handle("/batch", authMiddleware.ThenFunc(app.batch)).Methods(http.MethodPost)
+	s.Handle("/sync", authMiddleware.ThenFunc(app.pullSync)).Methods(http.MethodGet)
+	s.Handle("/sync", authMiddleware.ThenFunc(app.pushSync)).Methods(http.MethodPost)
 
 	// Auth handlers
-	s.HandleFunc("/auth/login", app.login).Methods(http.MethodPost)
-	s.HandleFunc("/auth/guest", app.guestLogin).Methods(http.MethodGet)
+	s.Handle("/auth/login", app.transact((*application).login)).Methods(http.MethodPost)
+	s.Handle("/auth/unlock", app.transact((*application).unlockAccount)).Methods(http.MethodPost)
+	s.Handle("/auth/guest", app.transact((*application).guestLogin)).Methods(http.MethodPost)
+	s.HandleFunc("/auth/oidc/{provider}/start", app.oidcStart).Methods(http.MethodGet)
+	s.Handle("/auth/oidc/{provider}/callback", app.transact((*application).oidcCallback)).Methods(http.MethodGet)
 	s.HandleFunc("/auth/refresh-token", app.refreshToken).Methods(http.MethodGet)
-	s.HandleFunc("/auth/logout", app.logout).Methods(http.MethodGet)
-	s.Handle("/auth/logout-global", authMiddleware.ThenFunc(app.logoutEverywhere)).Methods(http.MethodGet)
+	s.Handle("/auth/logout", app.transact((*application).logout)).Methods(http.MethodGet)
+	s.Handle("/auth/logout-global", authMiddleware.Then(app.transact((*application).logoutEverywhere))).Methods(http.MethodGet)
 
 	// User handlers
-	s.HandleFunc("/users", app.createUser).Methods(http.MethodPost)
+	s.Handle("/users", publicMiddleware.ThenFunc(app.createUser)).Methods(http.MethodPost)
 	s.Handle("/users/me", authMiddleware.ThenFunc(app.getUserByID)).Methods(http.MethodGet)
+	s.Handle("/users/me/upgrade", authMiddleware.ThenFunc(app.upgradeGuest)).Methods(http.MethodPost)
+	s.Handle("/users/me/audit", authMiddleware.ThenFunc(app.getAuditByUser)).Methods(http.MethodGet)
+	s.Handle("/users/me/agenda", authMiddleware.ThenFunc(app.getAgenda)).Methods(http.MethodGet)
+	s.Handle("/users/me/conflicts", authMiddleware.ThenFunc(app.getConflicts)).Methods(http.MethodGet)
+	s.Handle("/users/me/settings", authMiddleware.ThenFunc(app.getSettings)).Methods(http.MethodGet)
+	s.Handle("/users/me/settings", authMiddleware.ThenFunc(app.updateSettings)).Methods(http.MethodPatch)
+
+	// Saved view handlers
+	s.Handle("/users/me/views", authMiddleware.ThenFunc(app.getViewsByUser)).Methods(http.MethodGet)
+	s.Handle("/users/me/views", authMiddleware.ThenFunc(app.createView)).Methods(http.MethodPost)
+	s.Handle("/views/{id:[0-9]+}", authMiddleware.ThenFunc(app.getViewByID)).Methods(http.MethodGet)
+	s.Handle("/views/{id:[0-9]+}", authMiddleware.ThenFunc(app.updateView)).Methods(http.MethodPatch)
+	s.Handle("/views/{id:[0-9]+}", authMiddleware.ThenFunc(app.removeView)).Methods(http.MethodDelete)
+	s.Handle("/views/{id:[0-9]+}/tasks", authMiddleware.ThenFunc(app.getViewTasks)).Methods(http.MethodGet)
+
+	// Trash handlers
+	s.Handle("/users/me/trash", authMiddleware.ThenFunc(app.getTrashByUser)).Methods(http.MethodGet)
+	s.Handle("/trash/{type:folder|task}/{id:[0-9]+}/restore", authMiddleware.Then(app.transact((*application).restoreFromTrash))).Methods(http.MethodPost)
 
 	// Folder handlers
-	s.Handle("/users/me/folders", authMiddleware.ThenFunc(app.createFolder)).Methods(http.MethodPost)
+	s.Handle("/users/me/folders", authMiddleware.Then(app.transact((*application).createFolder))).Methods(http.MethodPost)
 	s.Handle("/users/me/folders", authMiddleware.ThenFunc(app.getFoldersByUser)).Methods(http.MethodGet)
 	s.Handle("/folders/{id:[0-9]+}", authMiddleware.ThenFunc(app.getFolderByID)).Methods(http.MethodGet)
-	s.Handle("/folders/{id:[0-9]+}", authMiddleware.ThenFunc(app.updateFolder)).Methods(http.MethodPatch)
-	s.Handle("/folders/{id:[0-9]+}", authMiddleware.ThenFunc(app.removeFolder)).Methods(http.MethodDelete)
+	s.Handle("/folders/{id:[0-9]+}", authMiddleware.Then(app.transact((*application).updateFolder))).Methods(http.MethodPatch)
+	s.Handle("/folders/{id:[0-9]+}", authMiddleware.Then(app.transact((*application).removeFolder))).Methods(http.MethodDelete)
+	s.Handle("/folders/{id:[0-9]+}/move", authMiddleware.Then(app.transact((*application).moveFolder))).Methods(http.MethodPost)
+	s.Handle("/folders/{id:[0-9]+}/archive", authMiddleware.Then(app.transact((*application).archiveFolder))).Methods(http.MethodPost)
+	s.Handle("/folders/{id:[0-9]+}/unarchive", authMiddleware.Then(app.transact((*application).unarchiveFolder))).Methods(http.MethodPost)
 
 	// Task handlers
-	s.Handle("/folders/{id:[0-9]+}/tasks", authMiddleware.ThenFunc(app.createTask)).Methods(http.MethodPost)
+	s.Handle("/folders/{id:[0-9]+}/tasks", authMiddleware.Then(app.transact((*application).createTask))).Methods(http.MethodPost)
 	s.Handle("/folders/{id:[0-9]+}/tasks", authMiddleware.ThenFunc(app.getTasksByFolder)).Methods(http.MethodGet)
 	s.Handle("/tasks", authMiddleware.ThenFunc(app.getTasksByUser)).Methods(http.MethodGet)
+	s.Handle("/tasks/bulk", authMiddleware.Then(app.transact((*application).bulkUpdateTasks))).Methods(http.MethodPost)
 	s.Handle("/tasks/{id:[0-9]+}", authMiddleware.ThenFunc(app.getTaskByID)).Methods(http.MethodGet)
-	s.Handle("/tasks/{id:[0-9]+}", authMiddleware.ThenFunc(app.updateTask)).Methods(http.MethodPatch)
-	s.Handle("/tasks/{id:[0-9]+}", authMiddleware.ThenFunc(app.removeTask)).Methods(http.MethodDelete)
+	s.Handle("/tasks/{id:[0-9]+}", authMiddleware.Then(app.transact((*application).updateTask))).Methods(http.MethodPatch)
+	s.Handle("/tasks/{id:[0-9]+}", authMiddleware.Then(app.transact((*application).removeTask))).Methods(http.MethodDelete)
+	s.Handle("/tasks/{id:[0-9]+}/move", authMiddleware.Then(app.transact((*application).moveTask))).Methods(http.MethodPost)
+	s.Handle("/tasks/{id:[0-9]+}/history", authMiddleware.ThenFunc(app.getTaskHistory)).Methods(http.MethodGet)
+	s.Handle("/tasks/{id:[0-9]+}/history/{revision_id:[0-9]+}/revert", authMiddleware.Then(app.transact((*application).revertTask))).Methods(http.MethodPost)
+
+	// Dependency handlers
+	s.Handle("/tasks/{id:[0-9]+}/dependencies", authMiddleware.ThenFunc(app.getTaskDependencies)).Methods(http.MethodGet)
+	s.Handle("/tasks/{id:[0-9]+}/dependencies", authMiddleware.Then(app.transact((*application).addTaskDependency))).Methods(http.MethodPost)
+	s.Handle("/tasks/{id:[0-9]+}/dependencies/{depends_on_id:[0-9]+}", authMiddleware.Then(app.transact((*application).removeTaskDependency))).Methods(http.MethodDelete)
+
+	// Comment handlers
+	s.Handle("/tasks/{id:[0-9]+}/comments", authMiddleware.Then(app.transact((*application).createComment))).Methods(http.MethodPost)
+	s.Handle("/tasks/{id:[0-9]+}/comments", authMiddleware.ThenFunc(app.getCommentsByTask)).Methods(http.MethodGet)
+	s.Handle("/tasks/{id:[0-9]+}/comments/{comment_id:[0-9]+}", authMiddleware.Then(app.transact((*application).updateComment))).Methods(http.MethodPatch)
+	s.Handle("/tasks/{id:[0-9]+}/comments/{comment_id:[0-9]+}", authMiddleware.Then(app.transact((*application).removeComment))).Methods(http.MethodDelete)
+
+	// Attachment handlers
+	s.Handle("/tasks/{id:[0-9]+}/attachments", authMiddleware.Then(app.transact((*application).uploadAttachment))).Methods(http.MethodPost)
+	s.Handle("/tasks/{id:[0-9]+}/attachments", authMiddleware.ThenFunc(app.getAttachmentsByTask)).Methods(http.MethodGet)
+	s.Handle("/tasks/{id:[0-9]+}/attachments/{attachment_id:[0-9]+}", authMiddleware.Then(app.transact((*application).removeAttachment))).Methods(http.MethodDelete)
+	s.HandleFunc("/attachments/{id:[0-9]+}/download", app.downloadAttachment).Methods(http.MethodGet)
+
+	// Admin handlers
+	s.Handle("/admin/usage", adminMiddleware.ThenFunc(app.adminShowUsage)).Methods(http.MethodGet)
+	s.Handle("/admin/users", adminMiddleware.ThenFunc(app.adminListUsers)).Methods(http.MethodGet)
+	s.Handle("/admin/users/{id:[0-9]+}", adminMiddleware.ThenFunc(app.adminGetUser)).Methods(http.MethodGet)
+	s.Handle("/admin/users/{id:[0-9]+}", adminMiddleware.Then(app.transact((*application).adminUpdateUser))).Methods(http.MethodPatch)
+	s.Handle("/admin/users/{id:[0-9]+}/disable", adminMiddleware.Then(app.transact((*application).adminDisableUser))).Methods(http.MethodPost)
+	s.Handle("/admin/users/{id:[0-9]+}/enable", adminMiddleware.Then(app.transact((*application).adminEnableUser))).Methods(http.MethodPost)
+	s.Handle("/admin/users/{id:[0-9]+}/unlock", adminMiddleware.Then(app.transact((*application).adminUnlockUser))).Methods(http.MethodPost)
+	s.Handle("/admin/users/{id:[0-9]+}/logout", adminMiddleware.Then(app.transact((*application).adminLogoutUser))).Methods(http.MethodPost)
+	s.Handle("/admin/users/{id:[0-9]+}/impersonate", adminMiddleware.Then(app.transact((*application).adminImpersonateUser))).Methods(http.MethodPost)
+	s.Handle("/admin/impersonations", adminMiddleware.ThenFunc(app.adminListImpersonations)).Methods(http.MethodGet)
+	s.Handle("/admin/audit", adminMiddleware.ThenFunc(app.adminListAudit)).Methods(http.MethodGet)
 
-	return standardMiddleware.Then(r)
+	return r
 }
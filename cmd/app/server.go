@@ -33,7 +33,16 @@ func (app *application) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
-		shutdownError <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		app.infoLog.Print("completing background tasks")
+
+		app.wg.Wait()
+		shutdownError <- nil
 	}()
 
 	app.infoLog.Printf("staring server on port %d", app.config.port)
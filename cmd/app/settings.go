@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) getSettings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	s, err := app.models.UserSettings.Get(claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"settings": s})
+}
+
+func (app *application) updateSettings(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	dto := &data.UpdateUserSettingsDTO{}
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	if dto.DefaultFolderID != nil && *dto.DefaultFolderID != 0 && !app.ownedParentFolder(w, claims.UserID, *dto.DefaultFolderID) {
+		return
+	}
+
+	s, err := app.models.UserSettings.Update(claims.UserID, dto)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"settings": s})
+}
+
+// userLocation returns the time zone the user has chosen in their settings.
+func (app *application) userLocation(userID int) (*time.Location, error) {
+	s, err := app.models.UserSettings.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Location(), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Get defaults", http.MethodGet, "", http.StatusOK, []byte(`"tz": "UTC"`), "123"},
+		{"Update", http.MethodPatch, `{"tz": "Europe/London", "locale": "en-GB", "week_start": "sunday"}`, http.StatusOK, []byte(`"tz": "Europe/London"`), "123"},
+		{"Set default folder", http.MethodPatch, `{"default_folder_id": 1}`, http.StatusOK, []byte(`"default_folder_id": 1`), "123"},
+		{"Clear default folder", http.MethodPatch, `{"default_folder_id": 0}`, http.StatusOK, []byte(`"default_folder_id": null`), "123"},
+		{"Other user's folder", http.MethodPatch, `{"default_folder_id": 1}`, http.StatusForbidden, nil, "456"},
+		{"Archived folder", http.MethodPatch, `{"default_folder_id": 5}`, http.StatusConflict, nil, "123"},
+		{"Missing folder", http.MethodPatch, `{"default_folder_id": 99}`, http.StatusNotFound, nil, "123"},
+		{"Invalid time zone", http.MethodPatch, `{"tz": "Mars/Olympus_Mons"}`, http.StatusUnprocessableEntity, []byte("tz"), "123"},
+		{"Local time zone", http.MethodPatch, `{"tz": "Local"}`, http.StatusUnprocessableEntity, []byte("tz"), "123"},
+		{"Empty time zone", http.MethodPatch, `{"tz": ""}`, http.StatusUnprocessableEntity, []byte("tz"), "123"},
+		{"Invalid locale", http.MethodPatch, `{"locale": "english please"}`, http.StatusUnprocessableEntity, []byte("locale"), "123"},
+		{"Invalid week start", http.MethodPatch, `{"week_start": "friday"}`, http.StatusUnprocessableEntity, []byte("week_start"), "123"},
+		{"Invalid user", http.MethodGet, "", http.StatusUnauthorized, nil, "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/api/v1/users/me/settings", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			app.routes().ServeHTTP(w, req)
+
+			if code := w.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := w.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
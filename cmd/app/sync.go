@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+const maxSyncPush = 100
+
+const (
+	syncCreate = "create"
+	syncUpdate = "update"
+	syncDelete = "delete"
+)
+
+type syncChange struct {
+	Type     string          `json:"type"`
+	Op       string          `json:"op"`
+	ID       int             `json:"id"`
+	ClientID string          `json:"client_id"`
+	FolderID json.RawMessage `json:"folder_id"`
+	Updated  *time.Time      `json:"updated"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type syncPushDTO struct {
+	Changes []*syncChange `json:"changes"`
+}
+
+func (d *syncPushDTO) Validate(v *validator.Validator) {
+	v.Check(len(d.Changes) > 0, "changes", "must not be empty")
+	v.Check(len(d.Changes) <= maxSyncPush, "changes", fmt.Sprintf("must not contain more than %d changes", maxSyncPush))
+
+	clientIDs := make(map[string]bool, len(d.Changes))
+
+	for i, c := range d.Changes {
+		key := fmt.Sprintf("changes[%d]", i)
+
+		v.PermittedValue(key+".type", c.Type, data.SyncFolder, data.SyncTask)
+		v.PermittedValue(key+".op", c.Op, syncCreate, syncUpdate, syncDelete)
+
+		if c.Op == syncCreate {
+			if c.ClientID != "" {
+				v.Check(batchRefRX.MatchString(c.ClientID), key+".client_id", "must contain only letters, digits, - and _")
+				v.Check(!clientIDs[c.ClientID], key+".client_id", "must be unique within the push")
+				clientIDs[c.ClientID] = true
+			}
+			if c.Type == data.SyncTask {
+				_, ok := syncFolderSegment(c.FolderID)
+				v.Check(ok, key+".folder_id", "must be a folder id or a client_id reference")
+			}
+		} else {
+			v.Check(c.ID > 0, key+".id", "must be a positive integer")
+			v.Check(c.Updated != nil, key+".updated", "must be the updated time last seen by the client")
+		}
+	}
+}
+
+// syncFolderSegment returns the path segment for the folder a new task is
+// created in, which is either an id or a reference to a new folder.
+func syncFolderSegment(raw json.RawMessage) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", false
+	}
+
+	switch v := v.(type) {
+	case float64:
+		return strconv.Itoa(int(v)), v > 0 && v == float64(int(v))
+	case string:
+		return v, len(v) > 1 && v[0] == '$'
+	default:
+		return "", false
+	}
+}
+
+// operation translates a change into the API request that applies it.
+func (c *syncChange) operation() *batchOperation {
+	op := &batchOperation{Ref: c.ClientID, Body: c.Data}
+
+	path := "/tasks/"
+	if c.Type == data.SyncFolder {
+		path = "/folders/"
+	}
+
+	switch c.Op {
+	case syncCreate:
+		op.Method = http.MethodPost
+		if c.Type == data.SyncFolder {
+			op.Path = "/users/me/folders"
+		} else {
+			folder, _ := syncFolderSegment(c.FolderID)
+			op.Path = "/folders/" + folder + "/tasks"
+		}
+	case syncUpdate:
+		op.Method = http.MethodPatch
+		op.Path = path + strconv.Itoa(c.ID)
+	case syncDelete:
+		op.Method = http.MethodDelete
+		op.Path = path + strconv.Itoa(c.ID)
+	}
+
+	return op
+}
+
+type syncResult struct {
+	ClientID string          `json:"client_id,omitempty"`
+	Type     string          `json:"type"`
+	ID       int             `json:"id,omitempty"`
+	Status   int             `json:"status"`
+	Body     json.RawMessage `json:"body,omitempty"`
+}
+
+// syncConflict describes a change that was not applied because the record
+// changed on the server since the client last saw it. Server holds the
+// current record, or is null if it has been deleted.
+type syncConflict struct {
+	Index  int         `json:"index"`
+	Type   string      `json:"type"`
+	ID     int         `json:"id"`
+	Reason string      `json:"reason"`
+	Server interface{} `json:"server"`
+}
+
+func (app *application) pullSync(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	since, err := data.ParseSyncCursor(r.URL.Query().Get("since"))
+	if err != nil {
+		v := validator.New()
+		v.AddError("since", "must be a cursor returned by a previous sync")
+		app.validationFailed(w, v)
+		return
+	}
+
+	cs, err := app.models.Sync.Changes(claims.UserID, since)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrSyncCursorExpired):
+			app.errorResponse(w, http.StatusGone, "the cursor has expired; sync again without one")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"changes": cs})
+}
+
+func (app *application) pushSync(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	dto := &syncPushDTO{}
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	refs := make(batchRefs)
+	for _, c := range dto.Changes {
+		if c.ClientID != "" {
+			refs[c.ClientID] = nil
+		}
+	}
+
+	results := []*syncResult{}
+	conflicts := []*syncConflict{}
+
+	for i, c := range dto.Changes {
+		res, conflict, err := app.applySyncChange(r, claims.UserID, c, refs)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if conflict != nil {
+			conflict.Index = i
+			conflicts = append(conflicts, conflict)
+		}
+		results = append(results, res)
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"results": results, "conflicts": conflicts})
+}
+
+// applySyncChange applies a single change in its own transaction. Updates
+// and deletes are only applied if the record is unchanged since the updated
+// time the client last saw.
+func (app *application) applySyncChange(r *http.Request, userID int, c *syncChange, refs batchRefs) (*syncResult, *syncConflict, error) {
+	res := &syncResult{ClientID: c.ClientID, Type: c.Type, ID: c.ID}
+
+	models, tx, err := app.beginModels(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer tx.Rollback()
+
+	var state *data.SyncState
+
+	if c.Op != syncCreate {
+		state, err = models.Sync.Lock(c.Type, c.ID)
+		if err != nil && !errors.Is(err, data.ErrNoRecord) {
+			return nil, nil, err
+		}
+
+		// Records that are missing or belong to someone else are left for the
+		// handler to reject.
+		if state != nil && state.UserID == userID {
+			switch {
+			case state.Deleted && c.Op == syncDelete:
+				res.Status = http.StatusNoContent
+				return res, nil, nil
+			case state.Deleted:
+				return syncConflictResult(res, &syncConflict{Type: c.Type, ID: c.ID, Reason: "deleted"})
+			case !state.Updated.Equal(*c.Updated):
+				conflict := &syncConflict{Type: c.Type, ID: c.ID, Reason: "modified"}
+				if c.Type == data.SyncFolder {
+					conflict.Server, err = models.Folders.GetByID(c.ID)
+				} else {
+					conflict.Server, err = models.Tasks.GetByID(c.ID)
+				}
+				if err != nil {
+					return nil, nil, err
+				}
+				return syncConflictResult(res, conflict)
+			}
+		}
+	}
+
+	txApp := *app
+	txApp.models = models
+	txApp.events = app.events.Deferred()
+
+	op := c.operation()
+	if state != nil && state.UserID == userID {
+		// The record is already locked and checked, but the handler still
+		// needs a matching If-Match if one is required.
+		op.Headers = map[string]string{"If-Match": etag(state.Version)}
+	}
+
+	br := app.runBatchOperation(r, txApp.router(), op, refs)
+
+	res.Status = br.Status
+	res.Body = br.Body
+
+	if res.Status >= 300 {
+		return res, nil, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	txApp.events.Flush()
+
+	if c.Op == syncCreate {
+		res.ID, _ = batchResponseID(br.Body)
+	}
+
+	return res, nil, nil
+}
+
+func syncConflictResult(res *syncResult, conflict *syncConflict) (*syncResult, *syncConflict, error) {
+	res.Status = http.StatusConflict
+	res.Body = batchMessage(fmt.Sprintf("the %s was %s since it was last synced", conflict.Type, conflict.Reason))
+
+	return res, conflict, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+func TestPullSync(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Initial sync", "", http.StatusOK, []byte(`"cursor"`), "123"},
+		{"Since cursor", "?since=" + data.SyncCursor{XID: 50}.String(), http.StatusOK, []byte(`"tasks": [`), "123"},
+		{"Tombstones", "", http.StatusOK, []byte(`"deleted"`), "123"},
+		{"Expired cursor", "?since=" + data.SyncCursor{XID: 1}.String(), http.StatusGone, nil, "123"},
+		{"Invalid cursor", "?since=!!", http.StatusUnprocessableEntity, []byte("since"), "123"},
+		{"Invalid user", "", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1/sync"+tt.query, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestPushSync(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{
+			"Update unchanged task",
+			`{"changes": [{"type": "task", "op": "update", "id": 1, "updated": "0001-01-01T00:00:00Z", "data": {"status": "important"}}]}`,
+			http.StatusOK, []byte(`"status": 200`), "123",
+		},
+		{
+			"Update modified task",
+			`{"changes": [{"type": "task", "op": "update", "id": 1, "updated": "2020-01-01T00:00:00Z", "data": {"status": "important"}}]}`,
+			http.StatusOK, []byte(`"reason": "modified"`), "123",
+		},
+		{
+			"Update deleted task",
+			`{"changes": [{"type": "task", "op": "update", "id": 2, "updated": "0001-01-01T00:00:00Z", "data": {"status": "important"}}]}`,
+			http.StatusOK, []byte(`"reason": "deleted"`), "123",
+		},
+		{
+			"Delete deleted task",
+			`{"changes": [{"type": "task", "op": "delete", "id": 2, "updated": "0001-01-01T00:00:00Z"}]}`,
+			http.StatusOK, []byte(`"status": 204`), "123",
+		},
+		{
+			"Update modified folder",
+			`{"changes": [{"type": "folder", "op": "update", "id": 1, "updated": "2020-01-01T00:00:00Z", "data": {"name": "New"}}]}`,
+			http.StatusOK, []byte(`"reason": "modified"`), "123",
+		},
+		{
+			"Create with reference",
+			`{"changes": [
+				{"type": "folder", "op": "create", "client_id": "f1", "data": {"name": "New"}},
+				{"type": "task", "op": "create", "client_id": "t1", "folder_id": "$f1", "data": {"title": "Task", "datetime": "2030-01-01T00:00:00Z", "status": "low"}}
+			]}`,
+			http.StatusOK, []byte(`"status": 201`), "123",
+		},
+		{
+			"Other user's task",
+			`{"changes": [{"type": "task", "op": "update", "id": 1, "updated": "2020-01-01T00:00:00Z", "data": {"status": "important"}}]}`,
+			http.StatusOK, []byte(`"status": 403`), "456",
+		},
+		{
+			"Missing updated",
+			`{"changes": [{"type": "task", "op": "update", "id": 1, "data": {"status": "important"}}]}`,
+			http.StatusUnprocessableEntity, []byte("updated"), "123",
+		},
+		{
+			"Task without folder",
+			`{"changes": [{"type": "task", "op": "create", "data": {"title": "Task"}}]}`,
+			http.StatusUnprocessableEntity, []byte("folder_id"), "123",
+		},
+		{"Unknown type", `{"changes": [{"type": "comment", "op": "delete", "id": 1, "updated": "2020-01-01T00:00:00Z"}]}`, http.StatusUnprocessableEntity, []byte("type"), "123"},
+		{"No changes", `{"changes": []}`, http.StatusUnprocessableEntity, []byte("changes"), "123"},
+		{"Invalid user", `{"changes": []}`, http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1/sync", tt.body, tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) bulkUpdateTasks(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	dto := &data.BulkTaskDTO{}
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	if dto.Action == data.BulkMove && !app.ownedParentFolder(w, claims.UserID, *dto.FolderID) {
+		return
+	}
+
+	if dto.Filter != nil {
+		dto.Filter.Location, err = app.userLocation(claims.UserID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	res, err := app.models.Tasks.Bulk(claims.UserID, dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrBulkTooLarge):
+			v.AddError("filter", fmt.Sprintf("matches more than %d tasks; narrow it down", data.MaxBulkTasks))
+			app.validationFailed(w, v)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	for _, c := range res.Changes {
+		if c.After == nil {
+			app.recordAudit(r, &data.AuditEvent{Action: data.AuditTaskDelete, EntityType: "task", EntityID: c.Before.ID}, c.Before, nil)
+			continue
+		}
+
+		app.recordAudit(r, &data.AuditEvent{Action: data.AuditTaskUpdate, EntityType: "task", EntityID: c.After.ID}, c.Before, c.After)
+		app.publishTaskUpdate(claims.UserID, c.Before, c.After)
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"result": res})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) getTaskHistory(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.ownedTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.intFromQuery(qs, "page", 1)
+	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
+	input.Filters.Sort = app.stringFromQuery(qs, "sort", "-id")
+	input.Filters.SortSafeList = []string{"id", "created", "-id", "-created"}
+
+	v := validator.New()
+	if v.Exec(&input.Filters); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	revisions, metadata, err := app.models.Tasks.GetRevisions(t.ID, input.Filters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "revisions": revisions})
+}
+
+func (app *application) revertTask(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	revisionID, err := strconv.Atoi(vars["revision_id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.writableTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	rev, err := app.models.Tasks.GetRevision(t.ID, revisionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	f, err := app.models.Folders.GetByID(rev.Snapshot.FolderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.conflict(w, "the folder this revision belongs to no longer exists")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if f.UserID != claims.UserID {
+		app.forbidden(w)
+		return
+	}
+
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return
+	}
+
+	datetime, endDatetime := "", ""
+	if rev.Snapshot.Datetime != nil {
+		datetime = rev.Snapshot.Datetime.Format(time.RFC3339)
+	}
+	if rev.Snapshot.EndDatetime != nil {
+		endDatetime = rev.Snapshot.EndDatetime.Format(time.RFC3339)
+	}
+
+	dto := &data.UpdateTaskDTO{
+		Title:       &rev.Snapshot.Title,
+		Description: &rev.Snapshot.Description,
+		Datetime:    &datetime,
+		EndDatetime: &endDatetime,
+		AllDay:      &rev.Snapshot.AllDay,
+		Status:      &rev.Snapshot.Status,
+		FolderID:    &rev.Snapshot.FolderID,
+	}
+
+	// Revisions recorded before tasks had tags leave them untouched.
+	if rev.Snapshot.Tags != nil {
+		dto.Tags = &rev.Snapshot.Tags
+	}
+
+	before := t
+
+	t, err = app.models.Tasks.Update(t.ID, claims.UserID, dto)
+	if err != nil {
+		var se *data.ScheduleError
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailed(w)
+		case errors.As(err, &se):
+			v := validator.New()
+			v.AddError(se.Field, se.Msg)
+			app.validationFailed(w, v)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditTaskUpdate, EntityType: "task", EntityID: t.ID}, before, t)
+	app.publishTaskUpdate(claims.UserID, before, t)
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"task": t})
+}
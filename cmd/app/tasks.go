@@ -4,10 +4,12 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/filter"
 	"github.com/pafirmin/go-todo/internal/validator"
 )
 
@@ -41,6 +43,11 @@ func (app *application) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return
+	}
+
 	dto := &data.CreateTaskDTO{}
 	err = app.readJSON(w, r, dto)
 	if err != nil {
@@ -54,12 +61,19 @@ func (app *application) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	start, end, timed := dto.Interval()
+	if !app.conflictsAllowed(w, r, claims.UserID, 0, start, end, timed) {
+		return
+	}
+
 	t, err := app.models.Tasks.Insert(f.ID, dto)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditTaskCreate, EntityType: "task", EntityID: t.ID}, nil, t)
+
 	app.writeJSON(w, http.StatusCreated, responsePayload{"task": t})
 }
 
@@ -70,44 +84,83 @@ func (app *application) getTasksByUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	app.listUserTasks(w, r, claims.UserID, nil)
+}
+
+// listUserTasks serves a page of a user's tasks filtered and sorted by the
+// query string and, if one is given, a saved view. The view's sort is only
+// the default, while its filter applies on top of any in the query string.
+func (app *application) listUserTasks(w http.ResponseWriter, r *http.Request, userID int, view *data.SavedView) {
+	loc, err := app.userLocation(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	var input struct {
-		FolderIDs []string
-		Status    string
-		MinDate   time.Time
-		MaxDate   time.Time
+		data.TaskFilters
 		data.Filters
 	}
 
 	qs := r.URL.Query()
 
+	input.Location = loc
 	input.FolderIDs = app.sliceFromQuery(qs, "folder_id[]", []string{})
 	input.Status = app.stringFromQuery(qs, "status", "")
-	input.MinDate = app.dateFromQuery(qs, "min_date", time.Time{})
-	input.MaxDate = app.dateFromQuery(qs, "max_date", time.Time{})
+	input.MinDate = app.dateFromQuery(qs, "min_date", time.Time{}, loc)
+	input.MaxDate = app.dateFromQuery(qs, "max_date", time.Time{}, loc)
 	input.Filters.Sort = app.stringFromQuery(qs, "sort", "datetime")
 	input.Filters.Page = app.intFromQuery(qs, "page", 1)
 	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
-	input.Filters.SortSafeList = []string{"id", "due", "created", "datetime", "-id", "-due", "-created", "-datetime"}
+	input.Filters.Cursor = app.stringFromQuery(qs, "cursor", "")
+	input.Filters.SortSafeList = data.TaskSortSafeList
+
+	if view != nil {
+		input.Filters.Sort = app.stringFromQuery(qs, "sort", view.Sort)
+	}
 
 	v := validator.New()
 
+	input.Filters.SkipCount = app.skipCountFromQuery(qs, v)
+	input.Filter = app.filterFromQuery(qs, v)
+
+	if view != nil && view.Filter != "" {
+		e, err := filter.Parse(view.Filter)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if input.Filter != nil {
+			e = &filter.And{Left: e, Right: input.Filter}
+		}
+		input.Filter = e
+	}
+
+	input.Blocked = app.optionalBoolFromQuery(qs, "blocked", v)
+	input.Archived = app.archivedFromQuery(qs, v)
+	input.Tag = strings.ToLower(app.stringFromQuery(qs, "tag", ""))
+
 	for _, id := range input.FolderIDs {
 		if _, err := strconv.Atoi(id); err != nil {
 			v.AddError("folder_id", "must be an integer")
 		}
 	}
 
+	v.Exec(&input.TaskFilters)
+
 	if v.Exec(&input.Filters); !v.Valid() {
 		app.validationFailed(w, v)
 		return
 	}
 
-	tasks, metadata, err := app.models.Tasks.GetByUser(claims.UserID, input.FolderIDs, input.Status, input.MinDate, input.MaxDate, input.Filters)
+	tasks, metadata, err := app.models.Tasks.GetByUser(userID, input.TaskFilters, input.Filters)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.setPageLinks(w, r, metadata)
 	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "tasks": tasks})
 }
 
@@ -141,36 +194,54 @@ func (app *application) getTasksByFolder(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	loc, err := app.userLocation(claims.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	var input struct {
-		Status  string
-		MinDate time.Time
-		MaxDate time.Time
+		data.TaskFilters
 		data.Filters
 	}
 
 	qs := r.URL.Query()
 
+	input.Location = loc
 	input.Status = app.stringFromQuery(qs, "status", "")
-	input.MinDate = app.dateFromQuery(qs, "min_date", time.Time{})
-	input.MaxDate = app.dateFromQuery(qs, "max_date", time.Time{})
+	input.MinDate = app.dateFromQuery(qs, "min_date", time.Time{}, loc)
+	input.MaxDate = app.dateFromQuery(qs, "max_date", time.Time{}, loc)
 	input.Filters.Sort = app.stringFromQuery(qs, "sort", "datetime")
 	input.Filters.Page = app.intFromQuery(qs, "page", 1)
 	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
-	input.Filters.SortSafeList = []string{"id", "due", "created", "datetime", "-id", "-due", "-created", "-datetime"}
+	input.Filters.Cursor = app.stringFromQuery(qs, "cursor", "")
+	input.Filters.SortSafeList = data.TaskSortSafeList
 
 	v := validator.New()
 
+	input.Filters.SkipCount = app.skipCountFromQuery(qs, v)
+	input.Filter = app.filterFromQuery(qs, v)
+
+	input.Blocked = app.optionalBoolFromQuery(qs, "blocked", v)
+
+	if recursive := app.optionalBoolFromQuery(qs, "recursive", v); recursive != nil {
+		input.Recursive = *recursive
+	}
+
+	v.Exec(&input.TaskFilters)
+
 	if v.Exec(&input.Filters); !v.Valid() {
 		app.validationFailed(w, v)
 		return
 	}
 
-	tasks, metadata, err := app.models.Tasks.GetByFolder(f.ID, input.Status, input.MinDate, input.MaxDate, input.Filters)
+	tasks, metadata, err := app.models.Tasks.GetByFolder(f.ID, input.TaskFilters, input.Filters)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.setPageLinks(w, r, metadata)
 	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "tasks": tasks})
 }
 
@@ -210,6 +281,10 @@ func (app *application) getTaskByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if app.notModified(w, r, t.Version) {
+		return
+	}
+
 	app.writeJSON(w, http.StatusOK, responsePayload{"task": t})
 }
 
@@ -249,6 +324,16 @@ func (app *application) updateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return
+	}
+
+	version, ok := app.ifMatch(w, r, t.Version)
+	if !ok {
+		return
+	}
+
 	dto := &data.UpdateTaskDTO{}
 	err = app.readJSON(w, r, dto)
 	if err != nil {
@@ -256,6 +341,8 @@ func (app *application) updateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dto.Version = version
+
 	v := validator.New()
 	if v.Exec(dto); !v.Valid() {
 		app.validationFailed(w, v)
@@ -278,14 +365,121 @@ func (app *application) updateTask(w http.ResponseWriter, r *http.Request) {
 			app.forbidden(w)
 			return
 		}
+
+		if f.ArchivedAt != nil {
+			app.folderArchived(w)
+			return
+		}
+	}
+
+	start, end, timed := dto.Interval(t)
+
+	status := t.Status
+	if dto.Status != nil {
+		status = *dto.Status
 	}
+	timed = timed && status != data.TaskStatusCompleted && status != data.TaskStatusCancelled
 
-	t, err = app.models.Tasks.Update(id, dto)
+	if !app.conflictsAllowed(w, r, claims.UserID, t.ID, start, end, timed) {
+		return
+	}
+
+	before := t
+
+	t, err = app.models.Tasks.Update(id, claims.UserID, dto)
 	if err != nil {
-		app.serverError(w, err)
+		var se *data.ScheduleError
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailed(w)
+		case errors.As(err, &se):
+			v.AddError(se.Field, se.Msg)
+			app.validationFailed(w, v)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditTaskUpdate, EntityType: "task", EntityID: t.ID}, before, t)
+	app.publishTaskUpdate(claims.UserID, before, t)
+
+	w.Header().Set("ETag", etag(t.Version))
+	app.writeJSON(w, http.StatusOK, responsePayload{"task": t})
+}
+
+func (app *application) moveTask(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	t, ok := app.writableTask(w, claims.UserID, id)
+	if !ok {
+		return
+	}
+
+	dto := &data.MoveTaskDTO{}
+	err = app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	if dto.FolderID != nil && *dto.FolderID != t.FolderID {
+		f, err := app.models.Folders.GetByID(*dto.FolderID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRecord):
+				app.notFound(w)
+			default:
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		if f.UserID != claims.UserID {
+			app.forbidden(w)
+			return
+		}
+
+		if f.ArchivedAt != nil {
+			app.folderArchived(w)
+			return
+		}
+	}
+
+	before := t
+
+	t, err = app.models.Tasks.Move(id, claims.UserID, dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, data.ErrInvalidNeighbour):
+			v.AddError("position", "after_id and before_id must be adjacent tasks in the target folder")
+			app.validationFailed(w, v)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditTaskUpdate, EntityType: "task", EntityID: t.ID}, before, t)
+
 	app.writeJSON(w, http.StatusOK, responsePayload{"task": t})
 }
 
@@ -320,16 +514,80 @@ func (app *application) removeTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if f.ID != claims.UserID {
+	if f.UserID != claims.UserID {
 		app.forbidden(w)
 		return
 	}
 
-	_, err = app.models.Tasks.Delete(t.ID)
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return
+	}
+
+	version, ok := app.ifMatch(w, r, t.Version)
+	if !ok {
+		return
+	}
+
+	_, err = app.models.Tasks.Delete(t.ID, version)
 	if err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailed(w)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
+	app.recordAudit(r, &data.AuditEvent{Action: data.AuditTaskDelete, EntityType: "task", EntityID: t.ID}, t, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (app *application) ownedTask(w http.ResponseWriter, userID, id int) (*data.Task, bool) {
+	t, _, ok := app.ownedTaskFolder(w, userID, id)
+	return t, ok
+}
+
+// writableTask is ownedTask for handlers that modify the task or anything
+// attached to it, which is not allowed while its folder is archived.
+func (app *application) writableTask(w http.ResponseWriter, userID, id int) (*data.Task, bool) {
+	t, f, ok := app.ownedTaskFolder(w, userID, id)
+	if !ok {
+		return nil, false
+	}
+
+	if f.ArchivedAt != nil {
+		app.folderArchived(w)
+		return nil, false
+	}
+
+	return t, true
+}
+
+func (app *application) ownedTaskFolder(w http.ResponseWriter, userID, id int) (*data.Task, *data.Folder, bool) {
+	t, err := app.models.Tasks.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return nil, nil, false
+	}
+
+	f, err := app.models.Folders.GetByID(t.FolderID)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, nil, false
+	}
+
+	if f.UserID != userID {
+		app.forbidden(w)
+		return nil, nil, false
+	}
+
+	return t, f, true
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
@@ -47,9 +48,54 @@ func TestGetTask(t *testing.T) {
 	}
 }
 
+func TestGetTasksByUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := data.PageCursor{Sort: "datetime", Value: "2030-01-01 00:00:00", ID: 1}.String()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantLink string
+	}{
+		{"Page", "/tasks?page=2", http.StatusOK, ""},
+		{"Next page", "/tasks?page=2&page_size=1", http.StatusOK, `</api/v1/tasks?cursor=` + next + `&page_size=1>; rel="next"`},
+		{"Cursor", "/tasks?cursor=" + next, http.StatusOK, ""},
+		{"Cursor for other sort", "/tasks?sort=-id&cursor=" + next, http.StatusUnprocessableEntity, ""},
+		{"Invalid cursor", "/tasks?cursor=e30", http.StatusUnprocessableEntity, ""},
+		{"Sort by due", "/tasks?sort=due", http.StatusUnprocessableEntity, ""},
+		{"Skip count", "/tasks?count=false", http.StatusOK, ""},
+		{"Filter", "/tasks?filter=" + url.QueryEscape(`status = important AND datetime < today+7d AND NOT folder_id in (2, 3) AND title ~ "invoice"`), http.StatusOK, ""},
+		{"Filter by tag and time", "/tasks?filter=" + url.QueryEscape(`tag != work OR created >= 2030-01-01T09:00:00Z OR blocked = true`), http.StatusOK, ""},
+		{"Filter syntax error", "/tasks?filter=" + url.QueryEscape(`status = `), http.StatusUnprocessableEntity, ""},
+		{"Filter unknown field", "/tasks?filter=" + url.QueryEscape(`owner = me`), http.StatusUnprocessableEntity, ""},
+		{"Filter unsupported operator", "/tasks?filter=" + url.QueryEscape(`title < b`), http.StatusUnprocessableEntity, ""},
+		{"Filter undated", "/tasks?filter=" + url.QueryEscape(`datetime = null`), http.StatusOK, ""},
+		{"Filter invalid value", "/tasks?filter=" + url.QueryEscape(`datetime > tomorrow`), http.StatusUnprocessableEntity, ""},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", "123")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if link := r.Header().Get("Link"); link != tt.wantLink {
+				t.Errorf("want Link %q; got %q", tt.wantLink, link)
+			}
+		})
+	}
+}
+
 func TestCreateTask(t *testing.T) {
 	app := newTestApplication(t)
 
+	duration, negativeDuration := 90, -30
+
 	tests := []struct {
 		name     string
 		urlPath  string
@@ -68,6 +114,30 @@ func TestCreateTask(t *testing.T) {
 			&data.CreateTaskDTO{Title: "Test", Description: "Test", Datetime: time.Now().Format(time.RFC3339)}},
 		{"Invalid body", "/folders/1/tasks", http.StatusUnprocessableEntity, nil, "123",
 			&data.CreateTaskDTO{Title: "", Description: "Test", Datetime: time.Now().Format(time.RFC3339)}},
+		{"No date", "/folders/1/tasks", http.StatusCreated, []byte("Test"), "123",
+			&data.CreateTaskDTO{Title: "Test", Description: "Test"}},
+		{"Invalid date", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("datetime"), "123",
+			&data.CreateTaskDTO{Title: "Test", Description: "Test", Datetime: "tomorrow"}},
+		{"With end", "/folders/1/tasks", http.StatusCreated, []byte("Test"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01T09:00:00Z", EndDatetime: "2030-01-01T11:00:00Z"}},
+		{"With duration", "/folders/1/tasks", http.StatusCreated, []byte("Test"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01T09:00:00Z", Duration: &duration}},
+		{"All day", "/folders/1/tasks", http.StatusCreated, []byte("Test"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01", EndDatetime: "2030-01-03", AllDay: true}},
+		{"Date without all day", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("datetime"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01"}},
+		{"End before start", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("must end after datetime"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01T09:00:00Z", EndDatetime: "2030-01-01T08:00:00Z"}},
+		{"End at start", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("end_datetime"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01T09:00:00Z", EndDatetime: "2030-01-01T09:00:00Z"}},
+		{"End without start", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("end_datetime"), "123",
+			&data.CreateTaskDTO{Title: "Test", EndDatetime: "2030-01-01T09:00:00Z"}},
+		{"End and duration", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("duration"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01T09:00:00Z", EndDatetime: "2030-01-01T11:00:00Z", Duration: &duration}},
+		{"Negative duration", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("duration"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01T09:00:00Z", Duration: &negativeDuration}},
+		{"Partial day", "/folders/1/tasks", http.StatusUnprocessableEntity, []byte("whole number of days"), "123",
+			&data.CreateTaskDTO{Title: "Test", Datetime: "2030-01-01", Duration: &duration, AllDay: true}},
 	}
 	rm := getRequestMaker(app.routes(), "POST", t)
 
@@ -87,6 +157,38 @@ func TestCreateTask(t *testing.T) {
 	}
 }
 
+func TestUpdateTaskSchedule(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody []byte
+	}{
+		{"End", `{"end_datetime": "2100-01-01T09:00:00Z"}`, http.StatusOK, []byte(`"end_datetime": "2100-01-01T09:00:00Z"`)},
+		{"End before start", `{"end_datetime": "2000-01-01T09:00:00Z"}`, http.StatusUnprocessableEntity, []byte("must end after datetime")},
+		{"Invalid end", `{"end_datetime": "soon"}`, http.StatusUnprocessableEntity, []byte("end_datetime")},
+		{"End and duration", `{"end_datetime": "2100-01-01T09:00:00Z", "duration": 60}`, http.StatusUnprocessableEntity, []byte("duration")},
+		{"Zero duration", `{"duration": 0}`, http.StatusUnprocessableEntity, []byte("duration")},
+	}
+	rm := getRequestMaker(app.routes(), "PATCH", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1/tasks/1", tt.body, "123")
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
+
 func GetTasksByFolder(t *testing.T) {
 	app := newTestApplication(t)
 
@@ -119,3 +221,149 @@ func GetTasksByFolder(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTaskHistory(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid ID", "/tasks/1/history", http.StatusOK, []byte("Old title"), "123"},
+		{"Forbidden user", "/tasks/1/history", http.StatusForbidden, nil, "456"},
+		{"Unauthorised user", "/tasks/1/history", http.StatusUnauthorized, nil, "invalid"},
+		{"Non-existent ID", "/tasks/2/history", http.StatusNotFound, nil, "123"},
+		{"Invalid sort", "/tasks/1/history?sort=title", http.StatusUnprocessableEntity, nil, "123"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRevertTask(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		token    string
+	}{
+		{"Valid revision", "/tasks/1/history/1/revert", http.StatusOK, "123"},
+		{"Non-existent revision", "/tasks/1/history/2/revert", http.StatusNotFound, "123"},
+		{"Invalid schedule", "/tasks/1/history/3/revert", http.StatusUnprocessableEntity, "123"},
+		{"Forbidden user", "/tasks/1/history/1/revert", http.StatusForbidden, "456"},
+		{"Unauthorised user", "/tasks/1/history/1/revert", http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestMoveTask(t *testing.T) {
+	app := newTestApplication(t)
+
+	one, three, two, negative := 1, 3, 2, -1
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+		dto      *data.MoveTaskDTO
+	}{
+		{"Valid request", "/tasks/1/move", http.StatusOK, []byte("Test"), "123", &data.MoveTaskDTO{AfterID: &three}},
+		{"Move to end", "/tasks/1/move", http.StatusOK, []byte("Test"), "123", &data.MoveTaskDTO{}},
+		{"Same folder", "/tasks/1/move", http.StatusOK, []byte("Test"), "123", &data.MoveTaskDTO{FolderID: &one}},
+		{"Missing folder", "/tasks/1/move", http.StatusNotFound, nil, "123", &data.MoveTaskDTO{FolderID: &two}},
+		{"Unknown neighbour", "/tasks/1/move", http.StatusUnprocessableEntity, []byte("position"), "123", &data.MoveTaskDTO{BeforeID: &two}},
+		{"Negative neighbour", "/tasks/1/move", http.StatusUnprocessableEntity, []byte("after_id"), "123", &data.MoveTaskDTO{AfterID: &negative}},
+		{"Forbidden user", "/tasks/1/move", http.StatusForbidden, nil, "456", &data.MoveTaskDTO{}},
+		{"Unauthorised user", "/tasks/1/move", http.StatusUnauthorized, nil, "invalid", &data.MoveTaskDTO{}},
+		{"Non-existent ID", "/tasks/2/move", http.StatusNotFound, nil, "123", &data.MoveTaskDTO{}},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.dto)
+			r := rm("/api/v1"+tt.urlPath, string(body), tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestBulkUpdateTasks(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Complete by id", `{"ids": [1], "action": "complete"}`, http.StatusOK, []byte(`"succeeded": [`), "123"},
+		{"Partial failure", `{"ids": [1, 2, 5], "action": "delete"}`, http.StatusOK, []byte("folder is archived"), "123"},
+		{"By filter", `{"filter": {"tag": "sprint-3"}, "action": "add_tag", "tag": "done"}`, http.StatusOK, []byte(`"add_tag"`), "123"},
+		{"Filter too broad", `{"filter": {"status": "default"}, "action": "complete"}`, http.StatusUnprocessableEntity, []byte("narrow it down"), "123"},
+		{"Update fields", `{"ids": [1], "action": "update", "update": {"status": "important"}}`, http.StatusOK, []byte("succeeded"), "123"},
+		{"Update without fields", `{"ids": [1], "action": "update"}`, http.StatusUnprocessableEntity, []byte("update"), "123"},
+		{"Update folder", `{"ids": [1], "action": "update", "update": {"folder_id": 1}}`, http.StatusUnprocessableEntity, []byte("move action"), "123"},
+		{"Move", `{"ids": [1], "action": "move", "folder_id": 1}`, http.StatusOK, []byte("succeeded"), "123"},
+		{"Move to archived folder", `{"ids": [1], "action": "move", "folder_id": 5}`, http.StatusConflict, nil, "123"},
+		{"Move to missing folder", `{"ids": [1], "action": "move", "folder_id": 2}`, http.StatusNotFound, nil, "123"},
+		{"Move to forbidden folder", `{"ids": [1], "action": "move", "folder_id": 1}`, http.StatusForbidden, nil, "456"},
+		{"Missing tag", `{"ids": [1], "action": "remove_tag"}`, http.StatusUnprocessableEntity, []byte("tag"), "123"},
+		{"Ids and filter", `{"ids": [1], "filter": {}, "action": "complete"}`, http.StatusUnprocessableEntity, []byte("ids"), "123"},
+		{"Neither ids nor filter", `{"action": "complete"}`, http.StatusUnprocessableEntity, []byte("ids"), "123"},
+		{"Unknown action", `{"ids": [1], "action": "explode"}`, http.StatusUnprocessableEntity, []byte("action"), "123"},
+		{"Invalid user", `{"ids": [1], "action": "complete"}`, http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1/tasks/bulk", tt.body, tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
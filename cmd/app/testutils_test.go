@@ -1,32 +1,82 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/pafirmin/go-todo/internal/blob"
 	"github.com/pafirmin/go-todo/internal/data"
 	"github.com/pafirmin/go-todo/internal/data/mock"
+	"github.com/pafirmin/go-todo/internal/events"
 	mockJwt "github.com/pafirmin/go-todo/internal/jwt/mock"
+	mockMailer "github.com/pafirmin/go-todo/internal/mailer/mock"
 )
 
 func newTestApplication(t *testing.T) *application {
 	models := data.Models{
-		Folders: mock.FolderModel{},
-		Users:   mock.UserModel{},
-		Tasks:   mock.TaskModel{},
-		Tokens:  mock.TokenModel{},
+		Folders:        mock.FolderModel{},
+		Users:          mock.UserModel{},
+		Tasks:          mock.TaskModel{},
+		Tokens:         mock.TokenModel{},
+		LoginAttempts:  mock.LoginAttemptModel{},
+		Identities:     mock.IdentityModel{},
+		Impersonations: mock.ImpersonationModel{},
+		Usage:          mock.UsageModel{},
+		Audit:          mock.AuditModel{},
+		Trash:          mock.TrashModel{},
+		Comments:       mock.CommentModel{},
+		Attachments:    mock.AttachmentModel{},
+		Dependencies:   mock.DependencyModel{},
+		Sync:           mock.SyncModel{},
+		SavedViews:     mock.SavedViewModel{},
+		UserSettings:   mock.UserSettingsModel{},
+		Idempotency:    mock.IdempotencyModel{},
 	}
-	return &application{
+
+	var cfg config
+	cfg.lockout.maxAttempts = 5
+	cfg.lockout.maxIPAttempts = 50
+	cfg.lockout.window = 15 * time.Minute
+	cfg.lockout.duration = 30 * time.Minute
+	cfg.attachments.maxSize = 1 << 20
+	cfg.attachments.quota = 10 << 20
+	cfg.attachments.secret = "secret"
+	cfg.attachments.urlTTL = 15 * time.Minute
+
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		config:     cfg,
 		errorLog:   log.New(io.Discard, "", 0),
 		infoLog:    log.New(io.Discard, "", 0),
 		jwtService: &mockJwt.JWTService{Secret: "123"},
+		mailer:     mockMailer.Mailer{},
 		models:     models,
+		blobs:      blobs,
+		events:     events.New(),
+		wg:         &sync.WaitGroup{},
+	}
+
+	// Tests swap in their own mocks after this, so the transaction is bound
+	// to whatever models the application has by then.
+	app.beginModels = func(context.Context) (data.Models, modelsTx, error) {
+		return app.models, &testTx{}, nil
 	}
+
+	app.registerEventHandlers()
+
+	return app
 }
 
 func getRequestMaker(r http.Handler, method string, t *testing.T) func(string, string, string) *httptest.ResponseRecorder {
@@ -39,3 +89,10 @@ func getRequestMaker(r http.Handler, method string, t *testing.T) func(string, s
 		return w
 	}
 }
+
+// testTx stands in for a database transaction. The mock models do not
+// persist anything, so there is nothing to commit or roll back.
+type testTx struct{}
+
+func (*testTx) Commit() error   { return nil }
+func (*testTx) Rollback() error { return nil }
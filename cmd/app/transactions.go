@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+// txHandler is a handler method, taken unbound so that it can be run against
+// a copy of the application whose models are in a transaction.
+type txHandler func(*application, http.ResponseWriter, *http.Request)
+
+// requestTx is the transaction a request's changes are made in, along with
+// the work that has to wait until it is committed or rolled back.
+type requestTx struct {
+	modelsTx
+	err        error
+	done       bool
+	onCommit   []func()
+	onRollback []func()
+}
+
+// nopTx stands in for a transaction that another one has joined, leaving
+// the commit to whoever began it.
+type nopTx struct{}
+
+func (nopTx) Commit() error   { return nil }
+func (nopTx) Rollback() error { return nil }
+
+// beginTx returns a copy of app whose models run in a new transaction and
+// whose events are held back until it commits. Handlers run by the copy
+// join the transaction rather than beginning their own.
+func (app *application) beginTx(ctx context.Context) (*application, error) {
+	models, tx, err := app.beginModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txApp := *app
+	txApp.models = models
+	txApp.events = app.events.Deferred()
+	txApp.tx = &requestTx{modelsTx: tx}
+	txApp.beginModels = func(context.Context) (data.Models, modelsTx, error) {
+		return models, nopTx{}, nil
+	}
+
+	return &txApp, nil
+}
+
+// commit commits the transaction, unless something that had to be part of
+// it failed, and then runs the work that was waiting on it.
+func (app *application) commit() error {
+	if app.tx.err != nil {
+		app.rollback()
+		return app.tx.err
+	}
+
+	app.tx.done = true
+
+	if err := app.tx.Commit(); err != nil {
+		app.runAll(app.tx.onRollback)
+		return err
+	}
+
+	app.events.Flush()
+	app.runAll(app.tx.onCommit)
+
+	return nil
+}
+
+// rollback rolls the transaction back if it has not already been committed
+// or rolled back, so that it can be deferred.
+func (app *application) rollback() {
+	if app.tx.done {
+		return
+	}
+
+	app.tx.done = true
+
+	if err := app.tx.Rollback(); err != nil {
+		app.errorLog.Print(err)
+	}
+
+	app.runAll(app.tx.onRollback)
+}
+
+func (app *application) runAll(fns []func()) {
+	for _, fn := range fns {
+		app.background(fn)
+	}
+}
+
+// failTx marks the transaction as failed, so that it is rolled back however
+// the handler responds. Outside a transaction, err is only logged.
+func (app *application) failTx(err error) {
+	if app.tx == nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	if app.tx.err == nil {
+		app.tx.err = err
+	}
+}
+
+// afterCommit runs fn in the background once the request's changes have
+// been committed, or straight away outside a transaction.
+func (app *application) afterCommit(fn func()) {
+	if app.tx == nil {
+		app.background(fn)
+		return
+	}
+
+	app.tx.onCommit = append(app.tx.onCommit, fn)
+}
+
+// afterRollback runs fn in the background if the request's changes are
+// rolled back.
+func (app *application) afterRollback(fn func()) {
+	if app.tx != nil {
+		app.tx.onRollback = append(app.tx.onRollback, fn)
+	}
+}
+
+// transact runs h in a transaction, so that the audit events it records are
+// committed together with the changes they describe. The transaction is
+// rolled back if h responds with a server error or an event can't be
+// recorded. Inside a transactional batch, h joins the batch's transaction.
+func (app *application) transact(h txHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.tx != nil {
+			h(app, w, r)
+			return
+		}
+
+		txApp, err := app.beginTx(r.Context())
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		defer txApp.rollback()
+
+		rec := newResponseRecorder()
+		h(txApp, rec, r)
+
+		if rec.Status() < http.StatusInternalServerError {
+			if err := txApp.commit(); err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
+		rec.writeTo(w)
+	})
+}
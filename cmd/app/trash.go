@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) getTrashByUser(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	var input struct {
+		Type string
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	input.Type = app.stringFromQuery(qs, "type", "")
+	input.Filters.Page = app.intFromQuery(qs, "page", 1)
+	input.Filters.PageSize = app.intFromQuery(qs, "page_size", 20)
+	input.Filters.Sort = app.stringFromQuery(qs, "sort", "-deleted_at")
+	input.Filters.SortSafeList = []string{"deleted_at", "name", "-deleted_at", "-name"}
+
+	if input.Type != "" {
+		v.PermittedValue("type", input.Type, data.TrashFolder, data.TrashTask)
+	}
+
+	if v.Exec(&input.Filters); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	items, metadata, err := app.models.Trash.GetByUser(claims.UserID, input.Type, input.Filters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"metadata": metadata, "items": items})
+}
+
+func (app *application) restoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemType := vars["type"]
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	item, err := app.models.Trash.Get(itemType, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if item.UserID != claims.UserID {
+		app.forbidden(w)
+		return
+	}
+
+	err = app.models.Trash.Restore(itemType, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrParentTrashed):
+			app.conflict(w, "the parent folder is in the trash; restore it first")
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	action := data.AuditFolderRestore
+	if itemType == data.TrashTask {
+		action = data.AuditTaskRestore
+	}
+
+	app.recordAudit(r, &data.AuditEvent{Action: action, EntityType: itemType, EntityID: id}, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
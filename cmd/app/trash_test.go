@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestGetTrashByUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Valid request", "/users/me/trash", http.StatusOK, []byte("Old folder"), "123"},
+		{"Filter by type", "/users/me/trash?type=task", http.StatusOK, []byte("Old task"), "123"},
+		{"Invalid type", "/users/me/trash?type=user", http.StatusUnprocessableEntity, nil, "123"},
+		{"Invalid sort", "/users/me/trash?sort=id", http.StatusUnprocessableEntity, nil, "123"},
+		{"Unauthorised user", "/users/me/trash", http.StatusUnauthorized, nil, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "GET", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRestoreFromTrash(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		token    string
+	}{
+		{"Restore folder", "/trash/folder/2/restore", http.StatusNoContent, "123"},
+		{"Restore task", "/trash/task/2/restore", http.StatusNoContent, "123"},
+		{"Folder still trashed", "/trash/task/3/restore", http.StatusConflict, "123"},
+		{"Not in trash", "/trash/folder/1/restore", http.StatusNotFound, "123"},
+		{"Invalid type", "/trash/user/1/restore", http.StatusNotFound, "123"},
+		{"Forbidden user", "/trash/folder/2/restore", http.StatusForbidden, "456"},
+		{"Unauthorised user", "/trash/folder/2/restore", http.StatusUnauthorized, "invalid"},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rm("/api/v1"+tt.urlPath, "", tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
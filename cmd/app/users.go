@@ -58,3 +58,57 @@ func (app *application) createUser(w http.ResponseWriter, r *http.Request) {
 
 	app.writeJSON(w, http.StatusCreated, responsePayload{"user": u})
 }
+
+func (app *application) upgradeGuest(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok || claims.UserID < 1 {
+		app.unauthorized(w)
+		return
+	}
+
+	u, err := app.models.Users.Get(claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if !u.IsGuest {
+		app.conflict(w, "account is already registered")
+		return
+	}
+
+	dto := &data.CreateUserDTO{}
+
+	err = app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	u, err = app.models.Users.Upgrade(u.ID, dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "email already in use")
+			app.validationFailed(w, v)
+		case errors.Is(err, data.ErrNoRecord):
+			app.conflict(w, "account is already registered")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"user": u})
+}
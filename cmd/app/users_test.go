@@ -46,3 +46,43 @@ func TestCreateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUpgradeGuest(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+		token    string
+		dto      *data.CreateUserDTO
+	}{
+		{"Guest user", "/users/me/upgrade", http.StatusOK, []byte("new@example.com"), "456",
+			&data.CreateUserDTO{Email: "new@example.com", FirstName: "Test", LastName: "McTest", Password: "Test1234"}},
+		{"Registered user", "/users/me/upgrade", http.StatusConflict, nil, "123",
+			&data.CreateUserDTO{Email: "new@example.com", FirstName: "Test", LastName: "McTest", Password: "Test1234"}},
+		{"Invalid user", "/users/me/upgrade", http.StatusUnauthorized, nil, "invalid",
+			&data.CreateUserDTO{Email: "new@example.com", FirstName: "Test", LastName: "McTest", Password: "Test1234"}},
+		{"Invalid body", "/users/me/upgrade", http.StatusUnprocessableEntity, nil, "456",
+			&data.CreateUserDTO{Email: "not_an_email", FirstName: "Test", LastName: "McTest", Password: "Test1234"}},
+		{"Duplicate email", "/users/me/upgrade", http.StatusUnprocessableEntity, []byte("email already in use"), "456",
+			&data.CreateUserDTO{Email: "taken@example.com", FirstName: "Test", LastName: "McTest", Password: "Test1234"}},
+	}
+	rm := getRequestMaker(app.routes(), "POST", t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.dto)
+			r := rm("/api/v1"+tt.urlPath, string(body), tt.token)
+
+			if code := r.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := r.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, r.Body)
+			}
+		})
+	}
+}
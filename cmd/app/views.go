@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pafirmin/go-todo/internal/data"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+func (app *application) createView(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	dto := &data.CreateSavedViewDTO{}
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	sv, err := app.models.SavedViews.Insert(claims.UserID, dto)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, responsePayload{"view": sv})
+}
+
+func (app *application) getViewsByUser(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	views, err := app.models.SavedViews.GetByUser(claims.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"views": views})
+}
+
+func (app *application) getViewByID(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	sv, ok := app.visibleView(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"view": sv})
+}
+
+func (app *application) getViewTasks(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	sv, ok := app.visibleView(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+
+	app.listUserTasks(w, r, claims.UserID, sv)
+}
+
+func (app *application) updateView(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	sv, ok := app.ownedView(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+
+	dto := &data.UpdateSavedViewDTO{}
+	err := app.readJSON(w, r, dto)
+	if err != nil {
+		app.badRequest(w, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if v.Exec(dto); !v.Valid() {
+		app.validationFailed(w, v)
+		return
+	}
+
+	sv, err = app.models.SavedViews.Update(sv.ID, dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, responsePayload{"view": sv})
+}
+
+func (app *application) removeView(w http.ResponseWriter, r *http.Request) {
+	claims, ok := app.claimsFromContext(r.Context())
+	if !ok {
+		app.unauthorized(w)
+		return
+	}
+
+	sv, ok := app.ownedView(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+
+	err := app.models.SavedViews.Delete(sv.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// visibleView looks up the view in the URL, which must be a system view or
+// one of the user's own.
+func (app *application) visibleView(w http.ResponseWriter, r *http.Request, userID int) (*data.SavedView, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		app.notFound(w)
+		return nil, false
+	}
+
+	sv, err := app.models.SavedViews.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if !sv.System && sv.UserID != userID {
+		app.forbidden(w)
+		return nil, false
+	}
+
+	return sv, true
+}
+
+// ownedView looks up the view in the URL for a change, which is only allowed
+// to the user's own views.
+func (app *application) ownedView(w http.ResponseWriter, r *http.Request, userID int) (*data.SavedView, bool) {
+	sv, ok := app.visibleView(w, r, userID)
+	if !ok {
+		return nil, false
+	}
+
+	if sv.System {
+		app.errorResponse(w, http.StatusForbidden, "built-in views can't be changed")
+		return nil, false
+	}
+
+	return sv, true
+}
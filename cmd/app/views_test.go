@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestViews(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		body     string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"List", http.MethodGet, "/users/me/views", "", http.StatusOK, []byte("Waiting on client"), "123"},
+		{"Create", http.MethodPost, "/users/me/views", `{"name": "Invoices", "filter": "title ~ invoice", "sort": "-created"}`, http.StatusCreated, []byte(`"view"`), "123"},
+		{"Create with invalid filter", http.MethodPost, "/users/me/views", `{"name": "Invoices", "filter": "title ~"}`, http.StatusUnprocessableEntity, []byte("filter"), "123"},
+		{"Create with unknown field", http.MethodPost, "/users/me/views", `{"name": "Mine", "filter": "owner = me"}`, http.StatusUnprocessableEntity, []byte("unknown field"), "123"},
+		{"Create with invalid sort", http.MethodPost, "/users/me/views", `{"name": "Invoices", "sort": "title"}`, http.StatusUnprocessableEntity, []byte("sort"), "123"},
+		{"Create with due sort", http.MethodPost, "/users/me/views", `{"name": "Invoices", "sort": "-due"}`, http.StatusUnprocessableEntity, []byte("sort"), "123"},
+		{"Create without name", http.MethodPost, "/users/me/views", `{"filter": "tag = work"}`, http.StatusUnprocessableEntity, []byte("name"), "123"},
+		{"Get", http.MethodGet, "/views/5", "", http.StatusOK, []byte("Waiting on client"), "123"},
+		{"Get system view", http.MethodGet, "/views/1", "", http.StatusOK, []byte(`"system": true`), "456"},
+		{"Get other user's view", http.MethodGet, "/views/5", "", http.StatusForbidden, nil, "456"},
+		{"Get missing view", http.MethodGet, "/views/2", "", http.StatusNotFound, nil, "123"},
+		{"Update", http.MethodPatch, "/views/5", `{"name": "Waiting"}`, http.StatusOK, []byte(`"view"`), "123"},
+		{"Update with due sort", http.MethodPatch, "/views/5", `{"sort": "due"}`, http.StatusUnprocessableEntity, []byte("sort"), "123"},
+		{"Update system view", http.MethodPatch, "/views/1", `{"name": "Now"}`, http.StatusForbidden, []byte("built-in"), "123"},
+		{"Update other user's view", http.MethodPatch, "/views/5", `{"name": "Mine"}`, http.StatusForbidden, nil, "456"},
+		{"Delete system view", http.MethodDelete, "/views/1", "", http.StatusForbidden, nil, "123"},
+		{"Delete", http.MethodDelete, "/views/5", "", http.StatusNoContent, nil, "123"},
+		{"Tasks", http.MethodGet, "/views/5/tasks", "", http.StatusOK, []byte(`"tasks"`), "123"},
+		{"Tasks of system view", http.MethodGet, "/views/1/tasks?filter=status%3Dimportant", "", http.StatusOK, []byte(`"tasks"`), "456"},
+		{"Tasks with invalid filter", http.MethodGet, "/views/1/tasks?filter=foo", "", http.StatusUnprocessableEntity, []byte("filter"), "123"},
+		{"Tasks of other user's view", http.MethodGet, "/views/5/tasks", "", http.StatusForbidden, nil, "456"},
+		{"Invalid user", http.MethodGet, "/users/me/views", "", http.StatusUnauthorized, nil, "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/api/v1"+tt.urlPath, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			app.routes().ServeHTTP(w, req)
+
+			if code := w.Code; code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if body := w.Body.Bytes(); !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
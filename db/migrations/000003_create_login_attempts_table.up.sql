@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+  id bigserial PRIMARY KEY,
+  email text NOT NULL,
+  ip text NOT NULL,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON login_attempts (email, created);
+
+CREATE INDEX ON login_attempts (ip, created);
+
+ALTER TABLE users ADD COLUMN locked_until timestamp(0) with time zone;
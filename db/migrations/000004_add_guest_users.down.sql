@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS last_active;
+ALTER TABLE users DROP COLUMN IF EXISTS is_guest;
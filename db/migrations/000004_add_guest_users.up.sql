@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN is_guest boolean NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN last_active timestamp(0) with time zone NOT NULL DEFAULT (now());
+
+CREATE INDEX ON users (last_active) WHERE is_guest;
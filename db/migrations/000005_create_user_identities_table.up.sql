@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "user_identities" (
+  provider text NOT NULL,
+  subject text NOT NULL,
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now()),
+  PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX ON user_identities (user_id);
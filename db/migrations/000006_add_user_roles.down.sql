@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS impersonations;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT ('user') CHECK (role IN ('user', 'admin'));
+ALTER TABLE users ADD COLUMN disabled boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS "impersonations" (
+  id bigserial PRIMARY KEY,
+  admin_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  reason text NOT NULL,
+  expiry timestamp(0) with time zone NOT NULL,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON impersonations (admin_id);
+
+CREATE INDEX ON impersonations (user_id);
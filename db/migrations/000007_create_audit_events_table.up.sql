@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS "audit_events" (
+  id bigserial PRIMARY KEY,
+  user_id bigint,
+  impersonator_id bigint,
+  action text NOT NULL,
+  entity_type text NOT NULL DEFAULT (''),
+  entity_id bigint,
+  ip text NOT NULL DEFAULT (''),
+  before jsonb,
+  after jsonb,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON audit_events (user_id, created);
+
+CREATE INDEX ON audit_events (action, created);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_or_delete
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE PROCEDURE audit_events_append_only();
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "task_revisions" (
+  id bigserial PRIMARY KEY,
+  task_id bigint NOT NULL REFERENCES tasks ON DELETE CASCADE,
+  user_id bigint,
+  changes jsonb NOT NULL,
+  snapshot jsonb NOT NULL,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON task_revisions (task_id, id);
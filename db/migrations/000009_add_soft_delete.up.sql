@@ -0,0 +1,5 @@
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+CREATE INDEX ON folders (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX ON tasks (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "comments" (
+  id bigserial PRIMARY KEY,
+  task_id bigint NOT NULL REFERENCES tasks ON DELETE CASCADE,
+  user_id bigint REFERENCES users ON DELETE SET NULL,
+  body text NOT NULL,
+  edited boolean NOT NULL DEFAULT false,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now()),
+  updated timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON comments (task_id, id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "attachments" (
+  id bigserial PRIMARY KEY,
+  task_id bigint REFERENCES tasks ON DELETE SET NULL,
+  user_id bigint REFERENCES users ON DELETE SET NULL,
+  filename text NOT NULL,
+  content_type text NOT NULL,
+  size bigint NOT NULL,
+  storage_key text UNIQUE NOT NULL,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON attachments (task_id);
+CREATE INDEX ON attachments (user_id);
+CREATE INDEX ON attachments (id) WHERE task_id IS NULL;
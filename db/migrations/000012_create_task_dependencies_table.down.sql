@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS task_dependencies;
+
+UPDATE tasks SET status = 'default' WHERE status = 'completed';
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check CHECK (status IN ('default', 'cancelled', 'important'));
@@ -0,0 +1,12 @@
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check CHECK (status IN ('default', 'cancelled', 'important', 'completed'));
+
+CREATE TABLE IF NOT EXISTS "task_dependencies" (
+  task_id bigint NOT NULL REFERENCES tasks ON DELETE CASCADE,
+  depends_on_id bigint NOT NULL REFERENCES tasks ON DELETE CASCADE,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now()),
+  PRIMARY KEY (task_id, depends_on_id),
+  CHECK (task_id <> depends_on_id)
+);
+
+CREATE INDEX ON task_dependencies (depends_on_id);
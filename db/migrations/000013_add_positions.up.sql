@@ -0,0 +1,19 @@
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS position text COLLATE "C" NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS position text COLLATE "C" NOT NULL DEFAULT '';
+
+UPDATE folders SET position = p.position
+FROM (
+  SELECT id, lpad(to_hex(row_number() OVER (PARTITION BY user_id ORDER BY id)), 8, '0') || 'i' AS position
+  FROM folders
+) AS p
+WHERE folders.id = p.id;
+
+UPDATE tasks SET position = p.position
+FROM (
+  SELECT id, lpad(to_hex(row_number() OVER (PARTITION BY folder_id ORDER BY id)), 8, '0') || 'i' AS position
+  FROM tasks
+) AS p
+WHERE tasks.id = p.id;
+
+CREATE INDEX IF NOT EXISTS folders_user_id_position_idx ON folders (user_id, position);
+CREATE INDEX IF NOT EXISTS tasks_folder_id_position_idx ON tasks (folder_id, position);
@@ -0,0 +1,4 @@
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS parent_id bigint REFERENCES folders ON DELETE CASCADE;
+ALTER TABLE folders ADD CONSTRAINT folders_parent_id_check CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS folders_parent_id_idx ON folders (parent_id);
@@ -0,0 +1,3 @@
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS archived_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS folders_archived_at_idx ON folders (archived_at) WHERE archived_at IS NOT NULL;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS sync_horizon;
+DROP TRIGGER IF EXISTS folders_touch_tasks ON folders;
+DROP TRIGGER IF EXISTS tasks_set_change_xid ON tasks;
+DROP TRIGGER IF EXISTS folders_set_change_xid ON folders;
+DROP FUNCTION IF EXISTS touch_folder_tasks();
+DROP FUNCTION IF EXISTS set_change_xid();
+ALTER TABLE tasks DROP COLUMN IF EXISTS change_xid;
+ALTER TABLE folders DROP COLUMN IF EXISTS change_xid;
@@ -0,0 +1,47 @@
+-- Every write stamps the row with the id of the writing transaction. Sync
+-- cursors are expressed in transaction ids so that a change is never handed
+-- out before every transaction that started earlier has finished.
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS folders_user_id_change_xid_idx ON folders (user_id, change_xid);
+CREATE INDEX IF NOT EXISTS tasks_change_xid_idx ON tasks (change_xid);
+
+CREATE OR REPLACE FUNCTION set_change_xid() RETURNS trigger AS $$
+BEGIN
+  NEW.change_xid := pg_current_xact_id();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER folders_set_change_xid
+BEFORE UPDATE ON folders
+FOR EACH ROW EXECUTE PROCEDURE set_change_xid();
+
+CREATE TRIGGER tasks_set_change_xid
+BEFORE UPDATE ON tasks
+FOR EACH ROW EXECUTE PROCEDURE set_change_xid();
+
+-- Tasks are hidden along with a trashed folder, so trashing or restoring a
+-- folder counts as a change to its tasks.
+CREATE OR REPLACE FUNCTION touch_folder_tasks() RETURNS trigger AS $$
+BEGIN
+  UPDATE tasks SET change_xid = pg_current_xact_id() WHERE folder_id = NEW.id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER folders_touch_tasks
+AFTER UPDATE OF deleted_at ON folders
+FOR EACH ROW
+WHEN (OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+EXECUTE PROCEDURE touch_folder_tasks();
+
+-- The newest change removed by purging the trash. Clients that have not
+-- synced past it may have missed a deletion.
+CREATE TABLE IF NOT EXISTS sync_horizon (
+  id boolean PRIMARY KEY DEFAULT (true) CHECK (id),
+  change_xid xid8 NOT NULL
+);
+
+INSERT INTO sync_horizon (change_xid) VALUES ('0') ON CONFLICT DO NOTHING;
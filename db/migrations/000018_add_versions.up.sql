@@ -0,0 +1,2 @@
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT (1);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT (1);
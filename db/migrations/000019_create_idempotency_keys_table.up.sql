@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "idempotency_keys" (
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  key text NOT NULL,
+  fingerprint text NOT NULL,
+  status integer,
+  header jsonb,
+  body bytea,
+  created timestamp(0) with time zone NOT NULL DEFAULT (now()),
+  expires_at timestamp(0) with time zone NOT NULL,
+  PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX ON idempotency_keys (expires_at);
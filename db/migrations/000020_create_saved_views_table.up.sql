@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "saved_views" (
+  id bigserial PRIMARY KEY,
+  user_id bigint REFERENCES users ON DELETE CASCADE,
+  name varchar(100) NOT NULL,
+  filter text NOT NULL DEFAULT '',
+  sort varchar(20) NOT NULL DEFAULT 'datetime',
+  created timestamp(0) with time zone NOT NULL DEFAULT (now()),
+  updated timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON saved_views (user_id);
+
+-- Views without a user are built in and shared by everyone.
+INSERT INTO saved_views (user_id, name, filter, sort) VALUES
+  (NULL, 'Today', 'datetime = today', 'datetime'),
+  (NULL, 'Upcoming 7 days', 'datetime >= today AND datetime < today+7d', 'datetime'),
+  (NULL, 'Overdue', 'datetime < today AND NOT status in (completed, cancelled)', 'datetime'),
+  (NULL, 'No date', 'datetime = null', '-created');
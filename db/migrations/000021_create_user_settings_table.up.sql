@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "user_settings" (
+  user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+  tz varchar(64) NOT NULL DEFAULT 'UTC',
+  locale varchar(35) NOT NULL DEFAULT 'en',
+  week_start varchar(10) NOT NULL DEFAULT 'monday',
+  default_folder_id bigint REFERENCES folders ON DELETE SET NULL,
+  updated timestamp(0) with time zone NOT NULL DEFAULT (now())
+);
@@ -0,0 +1,3 @@
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_end_after_start;
+ALTER TABLE tasks DROP COLUMN IF EXISTS all_day;
+ALTER TABLE tasks DROP COLUMN IF EXISTS end_datetime;
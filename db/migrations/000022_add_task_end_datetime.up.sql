@@ -0,0 +1,3 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS end_datetime timestamp;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS all_day boolean NOT NULL DEFAULT (false);
+ALTER TABLE tasks ADD CONSTRAINT tasks_end_after_start CHECK (end_datetime IS NULL OR end_datetime > datetime);
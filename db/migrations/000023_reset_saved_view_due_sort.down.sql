@@ -0,0 +1 @@
+-- The original sort orders are not kept, so there is nothing to restore.
@@ -0,0 +1,3 @@
+-- Tasks have no due column, so views sorted by it could never be listed.
+UPDATE saved_views SET sort = 'datetime' WHERE sort = 'due';
+UPDATE saved_views SET sort = '-datetime' WHERE sort = '-due';
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS users_email_lower_idx ON users (lower(email));
@@ -0,0 +1,6 @@
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+
+DELETE FROM idempotency_keys WHERE user_id IS NULL;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (user_id, key);
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS scope;
@@ -0,0 +1,10 @@
+-- Keys sent without a user are scoped to the client instead, so they have
+-- no user_id.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS scope text;
+UPDATE idempotency_keys SET scope = 'user:' || user_id;
+ALTER TABLE idempotency_keys ALTER COLUMN scope SET NOT NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (scope, key);
+
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until timestamp(0) with time zone NOT NULL DEFAULT (now());
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MaxAgendaTasks caps the number of tasks in each part of an agenda.
+const MaxAgendaTasks = 1000
+
+// AgendaTasks are the tasks making up an agenda. Each part holds up to
+// MaxAgendaTasks+1 tasks, so that callers can tell whether any were cut off.
+type AgendaTasks struct {
+	// Scheduled take place in the agenda's range, ordered by date.
+	Scheduled []*Task
+	// Overdue are unfinished and ended before the range. The most recent
+	// are kept if there are too many, but they are still ordered by date.
+	Overdue []*Task
+	// Undated are unfinished and have no date.
+	Undated []*Task
+}
+
+// GetAgenda returns a user's tasks taking place in [start, end), along with
+// the unfinished tasks that ended before start and those without a date.
+// Start and end are midnight in the user's time zone. Tasks in archived
+// folders are left out.
+func (m TaskModel) GetAgenda(userID int, start, end time.Time) (*AgendaTasks, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := queryArgs{userID, MaxAgendaTasks + 1}
+	conds := overlapConds(start, end, &args)
+
+	var a AgendaTasks
+	var err error
+
+	a.Scheduled, err = m.agendaTasks(ctx, fmt.Sprintf("%s AND %s", conds[0], conds[1]),
+		"tasks.datetime ASC, tasks.position ASC, tasks.id ASC", args)
+	if err != nil {
+		return nil, err
+	}
+
+	args = queryArgs{userID, MaxAgendaTasks + 1}
+	conds = overlapConds(start, time.Time{}, &args)
+
+	a.Overdue, err = m.agendaTasks(ctx, fmt.Sprintf("tasks.datetime IS NOT NULL AND NOT (%s) AND %s", conds[0], unfinishedCond),
+		"tasks.datetime DESC, tasks.position DESC, tasks.id DESC", args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(a.Overdue)-1; i < j; i, j = i+1, j-1 {
+		a.Overdue[i], a.Overdue[j] = a.Overdue[j], a.Overdue[i]
+	}
+
+	a.Undated, err = m.agendaTasks(ctx, "tasks.datetime IS NULL AND "+unfinishedCond,
+		"tasks.position ASC, tasks.id ASC", queryArgs{userID, MaxAgendaTasks + 1})
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// agendaTasks returns up to $2 of the tasks of user $1 that match cond, in
+// the given order.
+func (m TaskModel) agendaTasks(ctx context.Context, cond, order string, args queryArgs) ([]*Task, error) {
+	stmt := fmt.Sprintf(`SELECT `+taskReturning+`
+	FROM tasks
+	WHERE tasks.folder_id IN (
+		SELECT folders.id FROM folders
+		WHERE folders.user_id = $1
+		AND folders.deleted_at IS NULL
+		AND folders.archived_at IS NULL
+	)
+	AND tasks.deleted_at IS NULL
+	AND %s
+	ORDER BY %s
+	LIMIT $2`, cond, order)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []*Task{}
+
+	for rows.Next() {
+		t := &Task{}
+		if err := scanReturnedTask(rows, t); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, rows.Err()
+}
@@ -0,0 +1,157 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("models: storage quota exceeded")
+
+type AttachmentModel struct {
+	DB DBTX
+}
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	UserID      int       `json:"-"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	URL         string    `json:"url,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// Insert records an uploaded attachment, failing with ErrQuotaExceeded if it
+// would take the uploader past quota bytes. Uploads by the same user are
+// serialised so concurrent requests can't both slip under the limit.
+func (m AttachmentModel) Insert(a *Attachment, quota int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('attachments'), $1)`, a.UserID)
+		if err != nil {
+			return err
+		}
+
+		var used int64
+
+		stmt := `SELECT COALESCE(sum(size), 0) FROM attachments WHERE user_id = $1`
+
+		err = tx.QueryRowContext(ctx, stmt, a.UserID).Scan(&used)
+		if err != nil {
+			return err
+		}
+
+		if used+a.Size > quota {
+			return ErrQuotaExceeded
+		}
+
+		stmt = `INSERT INTO attachments (task_id, user_id, filename, content_type, size, storage_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created`
+
+		args := []interface{}{a.TaskID, a.UserID, a.Filename, a.ContentType, a.Size, a.StorageKey}
+
+		return tx.QueryRowContext(ctx, stmt, args...).Scan(&a.ID, &a.Created)
+	})
+}
+
+func (m AttachmentModel) Get(id int) (*Attachment, error) {
+	stmt := `SELECT id, COALESCE(task_id, 0), COALESCE(user_id, 0), filename, content_type, size, storage_key, created
+	FROM attachments
+	WHERE attachments.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a := &Attachment{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(
+		&a.ID, &a.TaskID, &a.UserID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey, &a.Created,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (m AttachmentModel) GetByTask(taskID int) ([]*Attachment, error) {
+	stmt := `SELECT id, task_id, COALESCE(user_id, 0), filename, content_type, size, storage_key, created
+	FROM attachments
+	WHERE attachments.task_id = $1
+	ORDER BY id ASC`
+
+	return m.query(stmt, taskID)
+}
+
+func (m AttachmentModel) Used(userID int) (int64, error) {
+	stmt := `SELECT COALESCE(sum(size), 0) FROM attachments WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var used int64
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(&used)
+	if err != nil {
+		return 0, err
+	}
+
+	return used, nil
+}
+
+// Orphans returns attachments whose task has been permanently deleted, so
+// their blobs can be removed from storage.
+func (m AttachmentModel) Orphans(limit int) ([]*Attachment, error) {
+	stmt := `SELECT id, 0, COALESCE(user_id, 0), filename, content_type, size, storage_key, created
+	FROM attachments
+	WHERE attachments.task_id IS NULL
+	ORDER BY id ASC
+	LIMIT $1`
+
+	return m.query(stmt, limit)
+}
+
+func (m AttachmentModel) Delete(id int) error {
+	stmt := `DELETE FROM attachments WHERE attachments.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+func (m AttachmentModel) query(stmt string, args ...interface{}) ([]*Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+
+	for rows.Next() {
+		a := &Attachment{}
+		err := rows.Scan(&a.ID, &a.TaskID, &a.UserID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey, &a.Created)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	AuditLoginSuccess     = "login.success"
+	AuditLoginFailure     = "login.failure"
+	AuditLogout           = "logout"
+	AuditTokenCreate      = "token.create"
+	AuditTokenRevoke      = "token.revoke"
+	AuditFolderCreate     = "folder.create"
+	AuditFolderUpdate     = "folder.update"
+	AuditFolderDelete     = "folder.delete"
+	AuditFolderRestore    = "folder.restore"
+	AuditFolderArchive    = "folder.archive"
+	AuditFolderUnarchive  = "folder.unarchive"
+	AuditTaskCreate       = "task.create"
+	AuditTaskUpdate       = "task.update"
+	AuditTaskDelete       = "task.delete"
+	AuditTaskRestore      = "task.restore"
+	AuditCommentCreate    = "comment.create"
+	AuditCommentUpdate    = "comment.update"
+	AuditCommentDelete    = "comment.delete"
+	AuditAttachmentCreate = "attachment.create"
+	AuditAttachmentDelete = "attachment.delete"
+	AuditDependencyCreate = "dependency.create"
+	AuditDependencyDelete = "dependency.delete"
+	AuditUserUpdate       = "user.update"
+	AuditUserImpersonate  = "user.impersonate"
+)
+
+type AuditModel struct {
+	DB DBTX
+}
+
+type AuditEvent struct {
+	ID             int             `json:"id"`
+	UserID         int             `json:"user_id,omitempty"`
+	ImpersonatorID int             `json:"impersonator_id,omitempty"`
+	Action         string          `json:"action"`
+	EntityType     string          `json:"entity_type,omitempty"`
+	EntityID       int             `json:"entity_id,omitempty"`
+	IP             string          `json:"ip,omitempty"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+	Created        time.Time       `json:"created"`
+}
+
+type AuditFilter struct {
+	UserID     int
+	Action     string
+	EntityType string
+	From       time.Time
+	To         time.Time
+}
+
+func AuditDiff(before, after interface{}) (json.RawMessage, json.RawMessage, error) {
+	b, err := toJSONMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a, err := toJSONMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b != nil && a != nil {
+		delete(b, "updated")
+		delete(a, "updated")
+
+		for k, v := range b {
+			if reflect.DeepEqual(v, a[k]) {
+				delete(b, k)
+				delete(a, k)
+			}
+		}
+	}
+
+	bj, err := fromJSONMap(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aj, err := fromJSONMap(a)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bj, aj, nil
+}
+
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func fromJSONMap(m map[string]interface{}) (json.RawMessage, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(m)
+}
+
+func (m AuditModel) Insert(e *AuditEvent) error {
+	stmt := `INSERT INTO audit_events (user_id, impersonator_id, action, entity_type, entity_id, ip, before, after)
+	VALUES (NULLIF($1, 0), NULLIF($2, 0), $3, $4, NULLIF($5, 0), $6, $7, $8)
+	RETURNING id, created`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := []interface{}{
+		e.UserID,
+		e.ImpersonatorID,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		e.IP,
+		nullableJSON(e.Before),
+		nullableJSON(e.After),
+	}
+
+	return m.DB.QueryRowContext(ctx, stmt, args...).Scan(&e.ID, &e.Created)
+}
+
+func (m AuditModel) GetAll(filter AuditFilter, filters Filters) ([]*AuditEvent, MetaData, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(),
+	id, COALESCE(user_id, 0), COALESCE(impersonator_id, 0), action, entity_type, COALESCE(entity_id, 0), ip, before, after, created
+	FROM audit_events
+	WHERE (user_id = $1 OR $1 = 0)
+	AND (action = $2 OR $2 = '')
+	AND (entity_type = $3 OR $3 = '')
+	AND (created >= $4 OR $4::timestamptz IS NULL)
+	AND (created < $5 OR $5::timestamptz IS NULL)
+	ORDER BY %s %s, id DESC
+	LIMIT $6 OFFSET $7`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := []interface{}{
+		filter.UserID,
+		filter.Action,
+		filter.EntityType,
+		nullableTime(filter.From),
+		nullableTime(filter.To),
+		filters.Limit(),
+		filters.Offset(),
+	}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		e := &AuditEvent{}
+		var before, after []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&e.ID,
+			&e.UserID,
+			&e.ImpersonatorID,
+			&e.Action,
+			&e.EntityType,
+			&e.EntityID,
+			&e.IP,
+			&before,
+			&after,
+			&e.Created,
+		)
+		if err != nil {
+			return nil, MetaData{}, err
+		}
+
+		e.Before = before
+		e.After = after
+		events = append(events, e)
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return events, metadata, nil
+}
+
+func nullableJSON(b json.RawMessage) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+
+	return []byte(b)
+}
+
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
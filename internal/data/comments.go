@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+type CommentModel struct {
+	DB DBTX
+}
+
+type CommentAuthor struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+type Comment struct {
+	ID     int `json:"id"`
+	TaskID int `json:"task_id"`
+	// Body is stored as the Markdown source; rendering is left to clients.
+	Body    string         `json:"body"`
+	Edited  bool           `json:"edited"`
+	Author  *CommentAuthor `json:"author"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+}
+
+type CreateCommentDTO struct {
+	Body string `json:"body"`
+}
+
+func (d *CreateCommentDTO) Validate(v *validator.Validator) {
+	v.ValidLength("body", d.Body, 1, 10000)
+}
+
+type UpdateCommentDTO struct {
+	Body *string `json:"body"`
+}
+
+func (d *UpdateCommentDTO) Validate(v *validator.Validator) {
+	if d.Body != nil {
+		v.ValidLength("body", *d.Body, 1, 10000)
+	}
+}
+
+const commentColumns = `c.id, c.task_id, c.body, c.edited, c.created, c.updated,
+	COALESCE(c.user_id, 0), COALESCE(users.first_name, ''), COALESCE(users.last_name, '')`
+
+func (m CommentModel) Insert(taskID, userID int, dto *CreateCommentDTO) (*Comment, error) {
+	stmt := `WITH c AS (
+		INSERT INTO comments (task_id, user_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING *
+	)
+	SELECT ` + commentColumns + `
+	FROM c
+	LEFT JOIN users ON users.id = c.user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return scanComment(m.DB.QueryRowContext(ctx, stmt, taskID, userID, dto.Body).Scan, nil)
+}
+
+func (m CommentModel) Get(taskID, id int) (*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	FROM comments c
+	LEFT JOIN users ON users.id = c.user_id
+	WHERE c.task_id = $1
+	AND c.id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := scanComment(m.DB.QueryRowContext(ctx, stmt, taskID, id).Scan, nil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (m CommentModel) GetByTask(taskID int, filters Filters) ([]*Comment, MetaData, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), `+commentColumns+`
+	FROM comments c
+	LEFT JOIN users ON users.id = c.user_id
+	WHERE c.task_id = $1
+	ORDER BY c.%s %s, c.id ASC
+	LIMIT $2 OFFSET $3`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, taskID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows.Scan, &totalRecords)
+		if err != nil {
+			return nil, MetaData{}, err
+		}
+		comments = append(comments, c)
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return comments, metadata, nil
+}
+
+func (m CommentModel) Update(id int, dto *UpdateCommentDTO) (*Comment, error) {
+	stmt := `WITH c AS (
+		UPDATE comments
+		SET body = COALESCE($1, body),
+			edited = edited OR body IS DISTINCT FROM COALESCE($1, body),
+			updated = now()
+		WHERE comments.id = $2
+		RETURNING *
+	)
+	SELECT ` + commentColumns + `
+	FROM c
+	LEFT JOIN users ON users.id = c.user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := scanComment(m.DB.QueryRowContext(ctx, stmt, dto.Body, id).Scan, nil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (m CommentModel) Delete(id int) error {
+	stmt := `DELETE FROM comments WHERE comments.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+func scanComment(scan func(...interface{}) error, totalRecords *int) (*Comment, error) {
+	c := &Comment{}
+	a := &CommentAuthor{}
+
+	dest := []interface{}{&c.ID, &c.TaskID, &c.Body, &c.Edited, &c.Created, &c.Updated, &a.ID, &a.FirstName, &a.LastName}
+	if totalRecords != nil {
+		dest = append([]interface{}{totalRecords}, dest...)
+	}
+
+	err := scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.ID != 0 {
+		c.Author = a
+	}
+
+	return c, nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// MaxConflictTasks caps the number of tasks checked for conflicts at once.
+const MaxConflictTasks = 1000
+
+// GetOverlapping returns a user's unfinished timed tasks with an end that
+// overlap [start, end), other than excludeID, ordered by when they start.
+// All-day tasks and tasks without an end never conflict. Tasks in archived
+// folders are left out. Up to MaxConflictTasks+1 tasks are returned, so that
+// callers can tell whether any were cut off.
+func (m TaskModel) GetOverlapping(userID int, start, end time.Time, excludeID int) ([]*Task, error) {
+	stmt := `SELECT ` + taskReturning + `
+	FROM tasks
+	WHERE tasks.folder_id IN (
+		SELECT folders.id FROM folders
+		WHERE folders.user_id = $1
+		AND folders.deleted_at IS NULL
+		AND folders.archived_at IS NULL
+	)
+	AND tasks.deleted_at IS NULL
+	AND tasks.id <> $4
+	AND NOT tasks.all_day
+	AND tasks.status NOT IN ('completed', 'cancelled')
+	AND tasks.datetime < $3
+	AND tasks.end_datetime > $2
+	ORDER BY tasks.datetime ASC, tasks.id ASC
+	LIMIT $5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, start.UTC(), end.UTC(), excludeID, MaxConflictTasks+1)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []*Task{}
+
+	for rows.Next() {
+		t := &Task{}
+		if err := scanReturnedTask(rows, t); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, rows.Err()
+}
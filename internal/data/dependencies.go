@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+var ErrDependencyCycle = errors.New("models: dependency would create a cycle")
+
+type DependencyModel struct {
+	DB DBTX
+}
+
+type CreateDependencyDTO struct {
+	DependsOnID int `json:"depends_on_id"`
+}
+
+func (d *CreateDependencyDTO) Validate(v *validator.Validator) {
+	v.Check(d.DependsOnID > 0, "depends_on_id", "must be a positive integer")
+}
+
+// Insert records for userID, who owns dependsOnID, that taskID cannot start
+// until dependsOnID is completed. Dependencies added by the same user are
+// serialised for the duration of the check so that two opposing edges can't
+// both be accepted.
+func (m DependencyModel) Insert(userID, taskID, dependsOnID int) error {
+	if taskID == dependsOnID {
+		return ErrDependencyCycle
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('task_dependencies'), $1)`, userID)
+		if err != nil {
+			return err
+		}
+
+		stmt := `WITH RECURSIVE upstream(id) AS (
+			SELECT depends_on_id FROM task_dependencies WHERE task_id = $1
+			UNION
+			SELECT task_dependencies.depends_on_id
+			FROM task_dependencies
+			INNER JOIN upstream ON task_dependencies.task_id = upstream.id
+		)
+		SELECT EXISTS (SELECT 1 FROM upstream WHERE id = $2)`
+
+		var cycle bool
+
+		err = tx.QueryRowContext(ctx, stmt, dependsOnID, taskID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+
+		if cycle {
+			return ErrDependencyCycle
+		}
+
+		stmt = `INSERT INTO task_dependencies (task_id, depends_on_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+		_, err = tx.ExecContext(ctx, stmt, taskID, dependsOnID)
+		return err
+	})
+}
+
+func (m DependencyModel) Delete(taskID, dependsOnID int) error {
+	stmt := `DELETE FROM task_dependencies WHERE task_id = $1 AND depends_on_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, stmt, taskID, dependsOnID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m DependencyModel) GetBlockers(taskID int) ([]*Task, error) {
+	stmt := `SELECT tasks.id, tasks.title, tasks.description, tasks.datetime, tasks.end_datetime, tasks.all_day, tasks.status, tasks.created, tasks.updated, tasks.folder_id, tasks.position, tasks.tags, tasks.version,
+	(SELECT count(*) FROM comments WHERE comments.task_id = tasks.id), ` + taskBlocked + `
+	FROM task_dependencies
+	INNER JOIN tasks ON tasks.id = task_dependencies.depends_on_id
+	WHERE task_dependencies.task_id = $1
+	AND tasks.deleted_at IS NULL
+	ORDER BY tasks.id ASC`
+
+	return m.queryTasks(stmt, taskID)
+}
+
+func (m DependencyModel) GetDependents(taskID int) ([]*Task, error) {
+	stmt := `SELECT tasks.id, tasks.title, tasks.description, tasks.datetime, tasks.end_datetime, tasks.all_day, tasks.status, tasks.created, tasks.updated, tasks.folder_id, tasks.position, tasks.tags, tasks.version,
+	(SELECT count(*) FROM comments WHERE comments.task_id = tasks.id), ` + taskBlocked + `
+	FROM task_dependencies
+	INNER JOIN tasks ON tasks.id = task_dependencies.task_id
+	WHERE task_dependencies.depends_on_id = $1
+	AND tasks.deleted_at IS NULL
+	ORDER BY tasks.id ASC`
+
+	return m.queryTasks(stmt, taskID)
+}
+
+// Unblocked returns the open dependents of taskID that no longer have any
+// unfinished blockers.
+func (m DependencyModel) Unblocked(taskID int) ([]*Task, error) {
+	tasks, err := m.GetDependents(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	unblocked := []*Task{}
+	for _, t := range tasks {
+		if !t.Blocked && !t.Finished() {
+			unblocked = append(unblocked, t)
+		}
+	}
+
+	return unblocked, nil
+}
+
+func (m DependencyModel) queryTasks(stmt string, args ...interface{}) ([]*Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []*Task{}
+
+	for rows.Next() {
+		t := &Task{}
+		err := rows.Scan(
+			&t.ID,
+			&t.Title,
+			&t.Description,
+			&t.Datetime,
+			&t.EndDatetime,
+			&t.AllDay,
+			&t.Status,
+			&t.Created,
+			&t.Updated,
+			&t.FolderID,
+			&t.Position,
+			pq.Array(&t.Tags),
+			&t.Version,
+			&t.CommentCount,
+			&t.Blocked,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, rows.Err()
+}
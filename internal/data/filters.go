@@ -1,6 +1,10 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"strings"
 
@@ -12,14 +16,55 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafeList []string
+	Cursor       string
+	SkipCount    bool
 }
 
 type MetaData struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+var ErrInvalidPageCursor = errors.New("models: invalid page cursor")
+
+// PageCursor marks a position in a list sorted by Sort: the sort key and id
+// of the last record read, or the first when reading backwards.
+type PageCursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int    `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func (c PageCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func ParsePageCursor(s string) (PageCursor, error) {
+	var c PageCursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidPageCursor
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort == "" || c.ID <= 0 {
+		return c, ErrInvalidPageCursor
+	}
+
+	return c, nil
+}
+
+// pageKey is the sort key of a record read with keyset pagination.
+type pageKey struct {
+	Value string
+	ID    int
 }
 
 func (f *Filters) Validate(v *validator.Validator) {
@@ -28,6 +73,12 @@ func (f *Filters) Validate(v *validator.Validator) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than 0")
 	v.Check(f.PageSize <= 1000, "page_size", "must be 1000 or lower")
 	v.PermittedValue("sort", f.Sort, f.SortSafeList...)
+
+	if f.Cursor != "" {
+		c, err := ParsePageCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "must be a cursor returned by a previous request")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "must be used with the sort it was returned for")
+	}
 }
 
 func CalculateMetadata(totalRecords, page, pageSize int) MetaData {
@@ -67,5 +118,110 @@ func (f Filters) Limit() int {
 }
 
 func (f Filters) Offset() int {
+	if f.Cursor != "" {
+		return 0
+	}
+
 	return (f.Page - 1) * f.PageSize
 }
+
+func (f Filters) cursor() (PageCursor, bool) {
+	if f.Cursor == "" {
+		return PageCursor{}, false
+	}
+
+	c, err := ParsePageCursor(f.Cursor)
+	return c, err == nil
+}
+
+// countColumn selects the total number of records, unless the count was
+// skipped. Counting a keyset page would only count the records after the
+// cursor, so it is always skipped.
+func (f Filters) countColumn() string {
+	if f.SkipCount || f.Cursor != "" {
+		return "0"
+	}
+
+	return "count(*) OVER()"
+}
+
+// keyset returns the condition that selects the records after the cursor,
+// and the ORDER BY clause to read them in. col and id are the qualified sort
+// and id columns.
+func (f Filters) keyset(col, id string, args *queryArgs) (cond, order string) {
+	dir := f.SortDirection()
+
+	c, ok := f.cursor()
+	if !ok {
+		return "TRUE", fmt.Sprintf("%s %s, %s ASC", col, dir, id)
+	}
+
+	// Records come after the cursor in sort order, with ties broken by id.
+	// Reading backwards flips the order, and finishPage puts the records
+	// back in order.
+	after, idAfter, idDir := ">", ">", "ASC"
+	if dir == "DESC" {
+		after = "<"
+	}
+
+	if c.Backward {
+		after, idAfter, idDir = "<", "<", "DESC"
+		if dir == "DESC" {
+			after, dir = ">", "ASC"
+		} else {
+			dir = "DESC"
+		}
+	}
+
+	value, idValue := args.add(c.Value), args.add(c.ID)
+	cond = fmt.Sprintf("(%[1]s %[3]s %[5]s OR (%[1]s = %[5]s AND %[2]s %[4]s %[6]s))", col, id, after, idAfter, value, idValue)
+
+	return cond, fmt.Sprintf("%s %s, %s %s", col, dir, id, idDir)
+}
+
+// finishPage takes the records read for a page, which should be queried with
+// a limit of one more than the page size to tell whether there is another
+// page, and keys holding their sort keys. It returns the page in order along
+// with its metadata.
+func finishPage[T any](f Filters, rows []T, keys []pageKey, totalRecords int) ([]T, MetaData) {
+	c, hasCursor := f.cursor()
+
+	more := len(rows) > f.Limit()
+	if more {
+		rows, keys = rows[:f.Limit()], keys[:f.Limit()]
+	}
+
+	if c.Backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	var metadata MetaData
+	switch {
+	case hasCursor:
+		metadata = MetaData{PageSize: f.PageSize}
+	case f.SkipCount:
+		metadata = MetaData{CurrentPage: f.Page, PageSize: f.PageSize, FirstPage: 1}
+	default:
+		metadata = CalculateMetadata(totalRecords, f.Page, f.PageSize)
+	}
+
+	if len(keys) == 0 {
+		return rows, metadata
+	}
+
+	first, last := keys[0], keys[len(keys)-1]
+
+	// A backward read came from the page after it, and a forward read with a
+	// cursor from the page before it, so there is always a page that way.
+	if (more && !c.Backward) || (hasCursor && c.Backward) {
+		metadata.NextCursor = PageCursor{Sort: f.Sort, Value: last.Value, ID: last.ID}.String()
+	}
+	if (more && c.Backward) || (hasCursor && !c.Backward) || (!hasCursor && f.Page > 1) {
+		metadata.PrevCursor = PageCursor{Sort: f.Sort, Value: first.Value, ID: first.ID, Backward: true}.String()
+	}
+
+	return rows, metadata
+}
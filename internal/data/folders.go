@@ -10,49 +10,118 @@ import (
 	"github.com/pafirmin/go-todo/internal/validator"
 )
 
+// MaxFolderDepth is the number of levels a folder hierarchy may have,
+// counting top-level folders as the first.
+const MaxFolderDepth = 5
+
+var (
+	ErrFolderCycle    = errors.New("models: folder cannot be moved inside itself")
+	ErrFolderDepth    = errors.New("models: folder hierarchy is too deep")
+	ErrParentArchived = errors.New("models: parent folder is archived")
+)
+
+// Values for the archived filter on folder and task listings.
+const (
+	ArchivedExclude = "exclude"
+	ArchivedInclude = "include"
+	ArchivedOnly    = "only"
+)
+
+// folderArchived matches folders according to an archived filter passed as $2.
+const folderArchived = `($2 = 'include' OR (folders.archived_at IS NOT NULL) = ($2 = 'only'))`
+
 type FolderModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 type Folder struct {
-	ID      int       `json:"id"`
-	Name    string    `json:"name"`
-	UserID  int       `json:"user_id"`
-	Created time.Time `json:"created"`
-	Updated time.Time `json:"updated"`
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	UserID     int        `json:"user_id"`
+	ParentID   *int       `json:"parent_id"`
+	Position   string     `json:"position"`
+	ArchivedAt *time.Time `json:"archived_at"`
+	Version    int        `json:"version"`
+	Created    time.Time  `json:"created"`
+	Updated    time.Time  `json:"updated"`
+	Children   []*Folder  `json:"children,omitempty"`
 }
 
 type CreateFolderDTO struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
 }
 
 func (d *CreateFolderDTO) Validate(v *validator.Validator) {
 	v.ValidLength("name", d.Name, 1, 30)
+	if d.ParentID != nil {
+		v.Check(*d.ParentID > 0, "parent_id", "must be a positive integer")
+	}
 }
 
+// UpdateFolderDTO moves a folder to the top level when ParentID is 0.
 type UpdateFolderDTO struct {
-	Name *string `json:"name"`
+	Name     *string `json:"name"`
+	ParentID *int    `json:"parent_id"`
+
+	// Version, if set, must match the folder's current version.
+	Version int `json:"-"`
 }
 
 func (d *UpdateFolderDTO) Validate(v *validator.Validator) {
 	if d.Name != nil {
 		v.ValidLength("name", *d.Name, 1, 30)
 	}
+	if d.ParentID != nil {
+		v.Check(*d.ParentID >= 0, "parent_id", "must not be negative")
+	}
 }
 
-func (m FolderModel) Insert(userID int, dto *CreateFolderDTO) (*Folder, error) {
-	stmt := `INSERT INTO folders (name, user_id, created, updated)
-	VALUES($1, $2, DEFAULT, DEFAULT)
-	RETURNING *`
+type MoveFolderDTO struct {
+	AfterID  *int `json:"after_id"`
+	BeforeID *int `json:"before_id"`
+}
 
+func (d *MoveFolderDTO) Validate(v *validator.Validator) {
+	if d.AfterID != nil {
+		v.Check(*d.AfterID > 0, "after_id", "must be a positive integer")
+	}
+	if d.BeforeID != nil {
+		v.Check(*d.BeforeID > 0, "before_id", "must be a positive integer")
+	}
+}
+
+func (m FolderModel) Insert(userID int, dto *CreateFolderDTO) (*Folder, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	f := &Folder{}
-	args := []interface{}{dto.Name, userID}
 
-	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		pos, err := folderList(userID).next(ctx, tx)
+		if err != nil {
+			return err
+		}
 
+		if dto.ParentID != nil {
+			depth, err := folderDepth(ctx, tx, *dto.ParentID)
+			if err != nil {
+				return err
+			}
+
+			if depth+1 > MaxFolderDepth {
+				return ErrFolderDepth
+			}
+		}
+
+		stmt := `INSERT INTO folders (name, user_id, parent_id, position, created, updated)
+		VALUES($1, $2, $3, $4, DEFAULT, DEFAULT)
+		RETURNING id, name, created, updated, user_id, parent_id, position, archived_at, version`
+
+		args := []interface{}{dto.Name, userID, dto.ParentID, pos}
+
+		return tx.QueryRowContext(ctx, stmt, args...).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -61,16 +130,17 @@ func (m FolderModel) Insert(userID int, dto *CreateFolderDTO) (*Folder, error) {
 }
 
 func (m FolderModel) GetByID(id int) (*Folder, error) {
-	stmt := `SELECT id, name, created, updated, user_id
+	stmt := `SELECT id, name, created, updated, user_id, parent_id, position, archived_at, version
 	FROM folders
-	WHERE folders.id = $1`
+	WHERE folders.id = $1
+	AND folders.deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	f := &Folder{}
 
-	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID)
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -81,19 +151,23 @@ func (m FolderModel) GetByID(id int) (*Folder, error) {
 	return f, nil
 }
 
-func (m FolderModel) GetByUser(userID int, filters Filters) ([]*Folder, MetaData, error) {
-	stmt := fmt.Sprintf(`SELECT count(*) OVER(), id, name, created, updated, user_id
+func (m FolderModel) GetByUser(userID int, archived string, filters Filters) ([]*Folder, MetaData, error) {
+	args := queryArgs{userID, archived, filters.Limit() + 1, filters.Offset()}
+	keyset, order := filters.keyset("folders."+filters.SortColumn(), "folders.id", &args)
+
+	stmt := fmt.Sprintf(`SELECT %[1]s, id, name, created, updated, user_id, parent_id, position, archived_at, version, folders.%[2]s::text
 	FROM folders
 	WHERE folders.user_id = $1
-	ORDER BY %s %s, id ASC
-	LIMIT $2 OFFSET $3
-	`, filters.SortColumn(), filters.SortDirection())
+	AND folders.deleted_at IS NULL
+	AND %[3]s
+	AND %[4]s
+	ORDER BY %[5]s
+	LIMIT $3 OFFSET $4
+	`, filters.countColumn(), filters.SortColumn(), folderArchived, keyset, order)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	args := []interface{}{userID, filters.Limit(), filters.Offset()}
-
 	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, MetaData{}, err
@@ -102,53 +176,363 @@ func (m FolderModel) GetByUser(userID int, filters Filters) ([]*Folder, MetaData
 	defer rows.Close()
 
 	folders := []*Folder{}
+	keys := []pageKey{}
 	totalRecords := 0
 
 	for rows.Next() {
 		f := Folder{}
-		err := rows.Scan(&totalRecords, &f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID)
+		k := pageKey{}
+		err := rows.Scan(&totalRecords, &f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version, &k.Value)
 		if err != nil {
 			return nil, MetaData{}, err
 		}
+		k.ID = f.ID
 		folders = append(folders, &f)
+		keys = append(keys, k)
 	}
 
-	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, err
+	}
+
+	folders, metadata := finishPage(filters, folders, keys, totalRecords)
 
 	return folders, metadata, nil
 }
 
+func (m FolderModel) GetTree(userID int, archived string) ([]*Folder, error) {
+	stmt := `SELECT id, name, created, updated, user_id, parent_id, position, archived_at, version
+	FROM folders
+	WHERE folders.user_id = $1
+	AND folders.deleted_at IS NULL
+	AND ` + folderArchived + `
+	ORDER BY position ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, archived)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	folders := []*Folder{}
+
+	for rows.Next() {
+		f := &Folder{}
+		err := rows.Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return BuildFolderTree(folders), nil
+}
+
+// BuildFolderTree nests folders under their parents, keeping their relative
+// order. Folders whose parent is not in the list are treated as roots.
+func BuildFolderTree(folders []*Folder) []*Folder {
+	byID := make(map[int]*Folder, len(folders))
+	for _, f := range folders {
+		byID[f.ID] = f
+	}
+
+	roots := []*Folder{}
+
+	for _, f := range folders {
+		if f.ParentID != nil {
+			if parent, ok := byID[*f.ParentID]; ok {
+				parent.Children = append(parent.Children, f)
+				continue
+			}
+		}
+		roots = append(roots, f)
+	}
+
+	return roots
+}
+
 func (m FolderModel) Update(id int, dto *UpdateFolderDTO) (*Folder, error) {
-	stmt := `UPDATE folders
-	SET name = COALESCE($1, name), updated = now()
-	WHERE folders.id = $2
-	RETURNING *
-	`
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := &Folder{}
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		if dto.Version != 0 {
+			var version int
 
+			stmt := `SELECT version FROM folders WHERE folders.id = $1 AND folders.deleted_at IS NULL FOR UPDATE`
+
+			err := tx.QueryRowContext(ctx, stmt, id).Scan(&version)
+			if err != nil {
+				return err
+			}
+
+			if version != dto.Version {
+				return ErrEditConflict
+			}
+		}
+
+		if dto.ParentID != nil {
+			err := checkFolderParent(ctx, tx, id, *dto.ParentID)
+			if err != nil {
+				return err
+			}
+		}
+
+		stmt := `UPDATE folders
+		SET name = COALESCE($1, name),
+			parent_id = CASE WHEN $2 THEN NULLIF($3::bigint, 0) ELSE parent_id END,
+			updated = now(),
+			version = version + 1
+		WHERE folders.id = $4
+		AND folders.deleted_at IS NULL
+		RETURNING id, name, created, updated, user_id, parent_id, position, archived_at, version`
+
+		args := []interface{}{dto.Name, dto.ParentID != nil, dto.ParentID, id}
+
+		return tx.QueryRowContext(ctx, stmt, args...).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// checkFolderParent makes sure that moving the folder id, along with its
+// subtree, under parentID would neither create a cycle nor exceed
+// MaxFolderDepth. Hierarchy changes are serialised per user so that two
+// concurrent moves cannot combine into a cycle.
+func checkFolderParent(ctx context.Context, tx *sql.Tx, id, parentID int) error {
+	var userID int
+
+	err := tx.QueryRowContext(ctx, `SELECT user_id FROM folders WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&userID)
+	if err != nil {
+		return err
+	}
+
+	err = folderList(userID).lock(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if parentID == 0 {
+		return nil
+	}
+
+	stmt := `WITH RECURSIVE subtree AS (
+		SELECT id, 1 AS depth FROM folders WHERE id = $1
+		UNION ALL
+		SELECT folders.id, subtree.depth + 1
+		FROM folders
+		INNER JOIN subtree ON folders.parent_id = subtree.id
+	)
+	SELECT max(depth), bool_or(id = $2) FROM subtree`
+
+	var height int
+	var cycle bool
+
+	err = tx.QueryRowContext(ctx, stmt, id, parentID).Scan(&height, &cycle)
+	if err != nil {
+		return err
+	}
+
+	if cycle {
+		return ErrFolderCycle
+	}
+
+	depth, err := folderDepth(ctx, tx, parentID)
+	if err != nil {
+		return err
+	}
+
+	if depth+height > MaxFolderDepth {
+		return ErrFolderDepth
+	}
+
+	return nil
+}
+
+// folderDepth returns the level of the folder id, where top-level folders are
+// at depth 1.
+func folderDepth(ctx context.Context, tx *sql.Tx, id int) (int, error) {
+	stmt := `WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM folders WHERE id = $1
+		UNION ALL
+		SELECT folders.id, folders.parent_id
+		FROM folders
+		INNER JOIN ancestors ON folders.id = ancestors.parent_id
+	)
+	SELECT count(*) FROM ancestors`
+
+	var depth int
+	err := tx.QueryRowContext(ctx, stmt, id).Scan(&depth)
+
+	return depth, err
+}
+
+func (m FolderModel) Move(id, userID int, dto *MoveFolderDTO) (*Folder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := &Folder{}
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		pos, err := folderList(userID).move(ctx, tx, id, dto.AfterID, dto.BeforeID)
+		if err != nil {
+			return err
+		}
+
+		stmt := `UPDATE folders
+		SET position = $1, updated = now(), version = version + 1
+		WHERE folders.id = $2
+		AND folders.user_id = $3
+		AND folders.deleted_at IS NULL
+		RETURNING id, name, created, updated, user_id, parent_id, position, archived_at, version`
+
+		return tx.QueryRowContext(ctx, stmt, pos, id, userID).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Archive archives the folder along with any of its subfolders that are not
+// already archived.
+func (m FolderModel) Archive(id int) (*Folder, error) {
+	stmt := `WITH RECURSIVE subtree AS (
+		SELECT id FROM folders WHERE folders.id = $1 AND folders.deleted_at IS NULL
+		UNION ALL
+		SELECT folders.id
+		FROM folders
+		INNER JOIN subtree ON folders.parent_id = subtree.id
+		WHERE folders.deleted_at IS NULL
+	)
+	UPDATE folders SET archived_at = COALESCE(archived_at, now()), updated = now(), version = version + 1
+	WHERE folders.id IN (SELECT id FROM subtree)
+	AND (folders.archived_at IS NULL OR folders.id = $1)`
+
+	return m.setArchived(id, stmt, false)
+}
+
+// Unarchive reverses Archive, restoring subfolders that were archived at the
+// same time as the folder. Subfolders of an archived folder cannot be
+// unarchived on their own.
+func (m FolderModel) Unarchive(id int) (*Folder, error) {
+	stmt := `WITH RECURSIVE subtree AS (
+		SELECT id, archived_at FROM folders
+		WHERE folders.id = $1
+		AND folders.deleted_at IS NULL
+		AND folders.archived_at IS NOT NULL
+		UNION ALL
+		SELECT folders.id, subtree.archived_at
+		FROM folders
+		INNER JOIN subtree ON folders.parent_id = subtree.id
+		WHERE folders.deleted_at IS NULL
+		AND folders.archived_at = subtree.archived_at
+	)
+	UPDATE folders SET archived_at = NULL, updated = now(), version = version + 1
+	WHERE folders.id IN (SELECT id FROM subtree)`
+
+	return m.setArchived(id, stmt, true)
+}
+
+func (m FolderModel) setArchived(id int, stmt string, checkParent bool) (*Folder, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	f := &Folder{}
 
-	err := m.DB.QueryRowContext(ctx, stmt, dto.Name, id).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		if checkParent {
+			var parentArchived bool
+
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (
+				SELECT 1 FROM folders AS parent
+				INNER JOIN folders ON folders.parent_id = parent.id
+				WHERE folders.id = $1
+				AND parent.archived_at IS NOT NULL
+			)`, id).Scan(&parentArchived)
+			if err != nil {
+				return err
+			}
+
+			if parentArchived {
+				return ErrParentArchived
+			}
+		}
 
+		_, err := tx.ExecContext(ctx, stmt, id)
+		if err != nil {
+			return err
+		}
+
+		stmt := `SELECT id, name, created, updated, user_id, parent_id, position, archived_at, version
+		FROM folders
+		WHERE folders.id = $1
+		AND folders.deleted_at IS NULL`
+
+		return tx.QueryRowContext(ctx, stmt, id).Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version)
+	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
 		return nil, err
 	}
 
-	return f, err
+	return f, nil
 }
 
-func (m FolderModel) Delete(id int) (int, error) {
-	stmt := `DELETE FROM folders WHERE folders.id = $1`
+// Delete moves a folder and its subfolders to the trash. If version is not
+// 0, it must match the folder's current version.
+func (m FolderModel) Delete(id, version int) (int, error) {
+	stmt := `WITH RECURSIVE subtree AS (
+		SELECT id FROM folders
+		WHERE folders.id = $1
+		AND folders.deleted_at IS NULL
+		AND ($2 = 0 OR folders.version = $2)
+		UNION ALL
+		SELECT folders.id
+		FROM folders
+		INNER JOIN subtree ON folders.parent_id = subtree.id
+		WHERE folders.deleted_at IS NULL
+	)
+	UPDATE folders SET deleted_at = now() WHERE folders.id IN (SELECT id FROM subtree)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, stmt, id)
+	res, err := m.DB.ExecContext(ctx, stmt, id, version)
 	if err != nil {
 		return 0, err
 	}
 
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 0 && version != 0 {
+		return 0, ErrEditConflict
+	}
+
 	return id, nil
 }
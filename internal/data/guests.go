@@ -0,0 +1,32 @@
+package data
+
+import (
+	"time"
+)
+
+type guestTask struct {
+	title       string
+	description string
+	status      string
+	offset      time.Duration
+}
+
+var guestFolders = []struct {
+	name  string
+	tasks []guestTask
+}{
+	{"Personal", []guestTask{
+		{"Dentist appointment", "Remember to bring the insurance card", "important", 26 * time.Hour},
+		{"Call Mum", "", "default", 50 * time.Hour},
+		{"Renew passport", "Forms are in the top drawer", "default", 7 * 24 * time.Hour},
+	}},
+	{"Work", []guestTask{
+		{"Team stand-up", "", "default", 2 * time.Hour},
+		{"Send quarterly invoice", "Client A, net 30", "important", 3 * 24 * time.Hour},
+		{"Prepare sprint demo", "Screenshots of the new calendar view", "default", 4 * 24 * time.Hour},
+	}},
+	{"Shopping", []guestTask{
+		{"Groceries", "Milk, eggs, bread, coffee", "default", 5 * time.Hour},
+		{"Birthday present for Sam", "", "cancelled", 10 * 24 * time.Hour},
+	}},
+}
@@ -0,0 +1,158 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyLockTimeout is the longest a request may run while holding an
+// idempotency key.
+const IdempotencyLockTimeout = time.Minute
+
+var (
+	ErrIdempotencyKeyReused = errors.New("models: idempotency key reused for a different request")
+	ErrIdempotencyInFlight  = errors.New("models: request with idempotency key still in progress")
+)
+
+type IdempotencyModel struct {
+	DB DBTX
+}
+
+// IdempotentResponse is the stored response to a request made with an
+// idempotency key.
+type IdempotentResponse struct {
+	Status int
+	Header map[string][]string
+	Body   []byte
+}
+
+// idempotencyScope returns the scope of keys sent by userID. Keys sent
+// without a user share a scope, so they should be made specific to the client
+// by the caller.
+func idempotencyScope(userID int) string {
+	if userID == 0 {
+		return "anonymous"
+	}
+
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// Begin claims key for a request with the given fingerprint. It returns nil
+// if the request should go ahead, or the stored response if an identical
+// request has already completed. The claim lasts until lockedUntil, after
+// which the request is assumed to have been abandoned and a retry may take
+// over, and the response is kept until expiry. A userID of 0 is for requests
+// made without logging in.
+func (m IdempotencyModel) Begin(userID int, key, fingerprint string, lockedUntil, expiry time.Time) (*IdempotentResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	scope := idempotencyScope(userID)
+
+	stmt := `INSERT INTO idempotency_keys (scope, user_id, key, fingerprint, locked_until, expires_at)
+	VALUES ($1, NULLIF($2::bigint, 0), $3, $4, $5, $6)
+	ON CONFLICT (scope, key) DO UPDATE
+	SET fingerprint = EXCLUDED.fingerprint,
+		status = NULL,
+		header = NULL,
+		body = NULL,
+		created = now(),
+		locked_until = EXCLUDED.locked_until,
+		expires_at = EXCLUDED.expires_at
+	WHERE idempotency_keys.expires_at < now()
+	OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until < now())
+	RETURNING true`
+
+	args := []interface{}{scope, userID, key, fingerprint, lockedUntil, expiry}
+
+	var claimed bool
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	stmt = `SELECT fingerprint, status, header, body
+	FROM idempotency_keys
+	WHERE scope = $1 AND key = $2`
+
+	var stored string
+	var status sql.NullInt64
+	var header []byte
+	res := &IdempotentResponse{}
+
+	err = m.DB.QueryRowContext(ctx, stmt, scope, key).Scan(&stored, &status, &header, &res.Body)
+	if err != nil {
+		// The key was released between the two statements.
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrIdempotencyInFlight
+		}
+		return nil, err
+	}
+
+	switch {
+	case stored != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case !status.Valid:
+		return nil, ErrIdempotencyInFlight
+	}
+
+	res.Status = int(status.Int64)
+
+	if err := json.Unmarshal(header, &res.Header); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Complete stores the response to the request holding key.
+func (m IdempotencyModel) Complete(userID int, key string, res *IdempotentResponse) error {
+	header, err := json.Marshal(res.Header)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE idempotency_keys
+	SET status = $1, header = $2, body = $3
+	WHERE scope = $4 AND key = $5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, stmt, res.Status, header, res.Body, idempotencyScope(userID), key)
+	return err
+}
+
+// Release gives up key without storing a response, so that the request can
+// be retried.
+func (m IdempotencyModel) Release(userID int, key string) error {
+	stmt := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, idempotencyScope(userID), key)
+	return err
+}
+
+func (m IdempotencyModel) DeleteExpired() (int64, error) {
+	stmt := `DELETE FROM idempotency_keys WHERE expires_at < now()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type IdentityModel struct {
+	DB DBTX
+}
+
+func (m IdentityModel) GetUser(provider, subject string) (*User, error) {
+	stmt := `SELECT users.id, users.email, users.first_name, users.last_name, users.hashed_password, users.locked_until, users.is_guest, users.role, users.disabled, users.created, users.updated
+	FROM users
+	INNER JOIN user_identities
+	ON users.id = user_identities.user_id
+	WHERE user_identities.provider = $1
+	AND user_identities.subject = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	u := &User{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, provider, subject).Scan(
+		&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (m IdentityModel) Insert(provider, subject string, userID int) error {
+	stmt := `INSERT INTO user_identities (provider, subject, user_id)
+	VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, provider, subject, userID)
+	return err
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+type ImpersonationModel struct {
+	DB DBTX
+}
+
+type Impersonation struct {
+	ID      int       `json:"id"`
+	AdminID int       `json:"admin_id"`
+	UserID  int       `json:"user_id"`
+	Reason  string    `json:"reason"`
+	Expiry  time.Time `json:"expiry"`
+	Created time.Time `json:"created"`
+}
+
+type CreateImpersonationDTO struct {
+	Reason string `json:"reason"`
+}
+
+func (d *CreateImpersonationDTO) Validate(v *validator.Validator) {
+	v.ValidLength("reason", d.Reason, 1, 500)
+}
+
+func (m ImpersonationModel) Insert(adminID, userID int, reason string, expiry time.Time) (*Impersonation, error) {
+	stmt := `INSERT INTO impersonations (admin_id, user_id, reason, expiry)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, admin_id, user_id, reason, expiry, created`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	i := &Impersonation{}
+	args := []interface{}{adminID, userID, reason, expiry}
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&i.ID, &i.AdminID, &i.UserID, &i.Reason, &i.Expiry, &i.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (m ImpersonationModel) GetAll(userID int, filters Filters) ([]*Impersonation, MetaData, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), id, admin_id, user_id, reason, expiry, created
+	FROM impersonations
+	WHERE (user_id = $1 OR admin_id = $1 OR $1 = 0)
+	ORDER BY %s %s, id DESC
+	LIMIT $2 OFFSET $3`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := []interface{}{userID, filters.Limit(), filters.Offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	impersonations := []*Impersonation{}
+
+	for rows.Next() {
+		i := &Impersonation{}
+		err := rows.Scan(&totalRecords, &i.ID, &i.AdminID, &i.UserID, &i.Reason, &i.Expiry, &i.Created)
+		if err != nil {
+			return nil, MetaData{}, err
+		}
+		impersonations = append(impersonations, i)
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return impersonations, metadata, nil
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptModel struct {
+	DB DBTX
+}
+
+// LoginFailures describes a recorded login attempt. Email and IP count the
+// attempts in the window including this one, and LastEmail and LastIP are
+// when the latest earlier ones were made.
+type LoginFailures struct {
+	ID        int
+	Email     int
+	IP        int
+	LastEmail time.Time
+	LastIP    time.Time
+}
+
+// Record records a login attempt before its password is checked and counts
+// the attempts made since. Attempts for the same email are serialised, so
+// concurrent guesses can't all be let through on the same count.
+func (m LoginAttemptModel) Record(email, ip string, since time.Time) (*LoginFailures, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := &LoginFailures{}
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('login_attempts'), hashtext($1))`, email)
+		if err != nil {
+			return err
+		}
+
+		stmt := `SELECT count(*) FILTER (WHERE email = $1),
+			count(*) FILTER (WHERE ip = $2),
+			COALESCE(max(created) FILTER (WHERE email = $1), 'epoch'),
+			COALESCE(max(created) FILTER (WHERE ip = $2), 'epoch')
+		FROM login_attempts
+		WHERE (email = $1 OR ip = $2)
+		AND created > $3`
+
+		err = tx.QueryRowContext(ctx, stmt, email, ip, since).Scan(&f.Email, &f.IP, &f.LastEmail, &f.LastIP)
+		if err != nil {
+			return err
+		}
+
+		stmt = `INSERT INTO login_attempts (email, ip, created)
+		VALUES ($1, $2, DEFAULT)
+		RETURNING id`
+
+		return tx.QueryRowContext(ctx, stmt, email, ip).Scan(&f.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	f.Email++
+	f.IP++
+
+	return f, nil
+}
+
+func (m LoginAttemptModel) Delete(id int) error {
+	stmt := `DELETE FROM login_attempts WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+func (m LoginAttemptModel) DeleteForEmail(email string) error {
+	stmt := `DELETE FROM login_attempts WHERE email = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, email)
+	return err
+}
@@ -0,0 +1,58 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+var mockAttachment = data.Attachment{
+	ID:          1,
+	TaskID:      1,
+	UserID:      1,
+	Filename:    "notes.txt",
+	ContentType: "text/plain; charset=utf-8",
+	Size:        11,
+	StorageKey:  "tasks/1/notes",
+	Created:     time.Now(),
+}
+
+type AttachmentModel struct{}
+
+func (m AttachmentModel) Insert(a *data.Attachment, quota int64) error {
+	if a.Size > quota {
+		return data.ErrQuotaExceeded
+	}
+
+	a.ID = 2
+	a.Created = time.Now()
+
+	return nil
+}
+
+func (m AttachmentModel) Get(id int) (*data.Attachment, error) {
+	switch id {
+	case 1:
+		a := mockAttachment
+		return &a, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
+}
+
+func (m AttachmentModel) GetByTask(taskID int) ([]*data.Attachment, error) {
+	a := mockAttachment
+	return []*data.Attachment{&a}, nil
+}
+
+func (m AttachmentModel) Used(userID int) (int64, error) {
+	return 0, nil
+}
+
+func (m AttachmentModel) Orphans(limit int) ([]*data.Attachment, error) {
+	return []*data.Attachment{}, nil
+}
+
+func (m AttachmentModel) Delete(id int) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+var mockAuditEvent = &data.AuditEvent{
+	ID:         1,
+	UserID:     1,
+	Action:     data.AuditFolderCreate,
+	EntityType: "folder",
+	EntityID:   1,
+	After:      []byte(`{"name": "Test"}`),
+	Created:    time.Now(),
+}
+
+type AuditModel struct{}
+
+func (m AuditModel) Insert(e *data.AuditEvent) error {
+	return nil
+}
+
+func (m AuditModel) GetAll(filter data.AuditFilter, filters data.Filters) ([]*data.AuditEvent, data.MetaData, error) {
+	return []*data.AuditEvent{mockAuditEvent}, data.MetaData{}, nil
+}
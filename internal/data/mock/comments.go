@@ -0,0 +1,58 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+var mockComment = &data.Comment{
+	ID:      1,
+	TaskID:  1,
+	Body:    "Looks **good**",
+	Author:  &data.CommentAuthor{ID: 1, FirstName: "Test", LastName: "User"},
+	Created: time.Now(),
+	Updated: time.Now(),
+}
+
+var mockOtherComment = &data.Comment{
+	ID:      2,
+	TaskID:  1,
+	Body:    "Someone else's comment",
+	Author:  &data.CommentAuthor{ID: 3, FirstName: "Admin", LastName: "User"},
+	Created: time.Now(),
+	Updated: time.Now(),
+}
+
+type CommentModel struct{}
+
+func (m CommentModel) Insert(taskID, userID int, dto *data.CreateCommentDTO) (*data.Comment, error) {
+	return mockComment, nil
+}
+
+func (m CommentModel) Get(taskID, id int) (*data.Comment, error) {
+	if taskID != 1 {
+		return nil, data.ErrNoRecord
+	}
+
+	switch id {
+	case 1:
+		return mockComment, nil
+	case 2:
+		return mockOtherComment, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
+}
+
+func (m CommentModel) GetByTask(taskID int, filters data.Filters) ([]*data.Comment, data.MetaData, error) {
+	return []*data.Comment{mockComment, mockOtherComment}, data.MetaData{}, nil
+}
+
+func (m CommentModel) Update(id int, dto *data.UpdateCommentDTO) (*data.Comment, error) {
+	return mockComment, nil
+}
+
+func (m CommentModel) Delete(id int) error {
+	return nil
+}
@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+// mockMeetings overlap from 09:30 to 10:00 on 1 January 2030.
+var mockMeetings = []*data.Task{
+	mockMeeting(8, "Standup", "2030-01-01T09:00:00Z", "2030-01-01T10:00:00Z"),
+	mockMeeting(9, "Planning", "2030-01-01T09:30:00Z", "2030-01-01T11:00:00Z"),
+}
+
+func mockMeeting(id int, title, start, end string) *data.Task {
+	s, _ := time.Parse(time.RFC3339, start)
+	e, _ := time.Parse(time.RFC3339, end)
+
+	return &data.Task{ID: id, Title: title, Datetime: &s, EndDatetime: &e, Status: "default", FolderID: 1, Created: time.Now()}
+}
+
+func (t TaskModel) GetOverlapping(userID int, start, end time.Time, excludeID int) ([]*data.Task, error) {
+	tasks := []*data.Task{}
+
+	for _, m := range mockMeetings {
+		if userID == 1 && m.ID != excludeID && m.Datetime.Before(end) && m.EndDatetime.After(start) {
+			tasks = append(tasks, m)
+		}
+	}
+
+	return tasks, nil
+}
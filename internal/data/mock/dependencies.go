@@ -0,0 +1,46 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+var mockDependent = &data.Task{
+	ID:       3,
+	Title:    "Release",
+	Datetime: &mockDatetime,
+	Status:   "default",
+	FolderID: 1,
+	Created:  time.Now(),
+}
+
+type DependencyModel struct{}
+
+func (m DependencyModel) Insert(userID, taskID, dependsOnID int) error {
+	if taskID == dependsOnID {
+		return data.ErrDependencyCycle
+	}
+
+	return nil
+}
+
+func (m DependencyModel) Delete(taskID, dependsOnID int) error {
+	if dependsOnID != 3 {
+		return data.ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m DependencyModel) GetBlockers(taskID int) ([]*data.Task, error) {
+	return []*data.Task{}, nil
+}
+
+func (m DependencyModel) GetDependents(taskID int) ([]*data.Task, error) {
+	return []*data.Task{mockDependent}, nil
+}
+
+func (m DependencyModel) Unblocked(taskID int) ([]*data.Task, error) {
+	return []*data.Task{mockDependent}, nil
+}
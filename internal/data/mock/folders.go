@@ -11,6 +11,25 @@ var mockFolder = &data.Folder{
 	Name:    "Test",
 	UserID:  1,
 	Created: time.Now(),
+	Version: 2,
+}
+
+var mockChildFolder = &data.Folder{
+	ID:       4,
+	Name:     "Child",
+	UserID:   1,
+	ParentID: &mockFolder.ID,
+	Created:  time.Now(),
+}
+
+var archivedAt = time.Now()
+
+var mockArchivedFolder = &data.Folder{
+	ID:         5,
+	Name:       "Archived",
+	UserID:     1,
+	ArchivedAt: &archivedAt,
+	Created:    time.Now(),
 }
 
 type FolderModel struct{}
@@ -23,19 +42,57 @@ func (f FolderModel) GetByID(id int) (*data.Folder, error) {
 	switch id {
 	case 1:
 		return mockFolder, nil
+	case 4:
+		return mockChildFolder, nil
+	case 5:
+		return mockArchivedFolder, nil
 	default:
 		return nil, data.ErrNoRecord
 	}
 }
 
-func (f FolderModel) GetByUser(id int, filters data.Filters) ([]*data.Folder, data.MetaData, error) {
+func (f FolderModel) GetByUser(id int, archived string, filters data.Filters) ([]*data.Folder, data.MetaData, error) {
 	return []*data.Folder{mockFolder}, data.MetaData{}, nil
 }
 
+func (f FolderModel) GetTree(userID int, archived string) ([]*data.Folder, error) {
+	child := *mockChildFolder
+	root := *mockFolder
+	root.Children = []*data.Folder{&child}
+
+	return []*data.Folder{&root}, nil
+}
+
 func (f FolderModel) Update(id int, dto *data.UpdateFolderDTO) (*data.Folder, error) {
+	if dto.ParentID != nil && *dto.ParentID == mockChildFolder.ID {
+		return nil, data.ErrFolderCycle
+	}
+
 	return mockFolder, nil
 }
 
-func (f FolderModel) Delete(id int) (int, error) {
+func (f FolderModel) Delete(id, version int) (int, error) {
 	return 1, nil
 }
+
+func (f FolderModel) Move(id, userID int, dto *data.MoveFolderDTO) (*data.Folder, error) {
+	if (dto.AfterID != nil && *dto.AfterID != 1) || (dto.BeforeID != nil && *dto.BeforeID != 1) {
+		return nil, data.ErrInvalidNeighbour
+	}
+
+	return mockFolder, nil
+}
+
+func (f FolderModel) Archive(id int) (*data.Folder, error) {
+	a := *mockFolder
+	a.ArchivedAt = &archivedAt
+
+	return &a, nil
+}
+
+func (f FolderModel) Unarchive(id int) (*data.Folder, error) {
+	a := *mockArchivedFolder
+	a.ArchivedAt = nil
+
+	return &a, nil
+}
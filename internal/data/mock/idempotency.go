@@ -0,0 +1,72 @@
+package mock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+type idempotencyRecord struct {
+	fingerprint string
+	res         *data.IdempotentResponse
+}
+
+var (
+	idempotencyMu   sync.Mutex
+	idempotencyKeys = map[string]*idempotencyRecord{}
+)
+
+type IdempotencyModel struct{}
+
+func (m IdempotencyModel) Begin(userID int, key, fingerprint string, lockedUntil, expiry time.Time) (*data.IdempotentResponse, error) {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+
+	if key == "in-flight" {
+		return nil, data.ErrIdempotencyInFlight
+	}
+
+	k := fmt.Sprintf("%d/%s", userID, key)
+
+	r, ok := idempotencyKeys[k]
+	switch {
+	case !ok:
+		idempotencyKeys[k] = &idempotencyRecord{fingerprint: fingerprint}
+		return nil, nil
+	case r.fingerprint != fingerprint:
+		return nil, data.ErrIdempotencyKeyReused
+	case r.res == nil:
+		return nil, data.ErrIdempotencyInFlight
+	default:
+		return r.res, nil
+	}
+}
+
+func (m IdempotencyModel) Complete(userID int, key string, res *data.IdempotentResponse) error {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+
+	if r, ok := idempotencyKeys[fmt.Sprintf("%d/%s", userID, key)]; ok {
+		r.res = res
+	}
+
+	return nil
+}
+
+func (m IdempotencyModel) Release(userID int, key string) error {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+
+	k := fmt.Sprintf("%d/%s", userID, key)
+	if r, ok := idempotencyKeys[k]; ok && r.res == nil {
+		delete(idempotencyKeys, k)
+	}
+
+	return nil
+}
+
+func (m IdempotencyModel) DeleteExpired() (int64, error) {
+	return 0, nil
+}
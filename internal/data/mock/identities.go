@@ -0,0 +1,20 @@
+package mock
+
+import (
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+type IdentityModel struct{}
+
+func (m IdentityModel) GetUser(provider, subject string) (*data.User, error) {
+	switch subject {
+	case "linked-subject":
+		return mockUser, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
+}
+
+func (m IdentityModel) Insert(provider, subject string, userID int) error {
+	return nil
+}
@@ -0,0 +1,17 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+type ImpersonationModel struct{}
+
+func (m ImpersonationModel) Insert(adminID, userID int, reason string, expiry time.Time) (*data.Impersonation, error) {
+	return &data.Impersonation{ID: 1, AdminID: adminID, UserID: userID, Reason: reason, Expiry: expiry, Created: time.Now()}, nil
+}
+
+func (m ImpersonationModel) GetAll(userID int, filters data.Filters) ([]*data.Impersonation, data.MetaData, error) {
+	return []*data.Impersonation{}, data.MetaData{}, nil
+}
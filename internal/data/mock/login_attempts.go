@@ -0,0 +1,26 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+type LoginAttemptModel struct{}
+
+func (m LoginAttemptModel) Record(email, ip string, since time.Time) (*data.LoginFailures, error) {
+	switch email {
+	case "throttled@example.com":
+		return &data.LoginFailures{ID: 1, Email: 5, IP: 5, LastEmail: time.Now()}, nil
+	default:
+		return &data.LoginFailures{ID: 1, Email: 1, IP: 1}, nil
+	}
+}
+
+func (m LoginAttemptModel) Delete(id int) error {
+	return nil
+}
+
+func (m LoginAttemptModel) DeleteForEmail(email string) error {
+	return nil
+}
@@ -0,0 +1,56 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+var mockSystemView = &data.SavedView{
+	ID:      1,
+	Name:    "Today",
+	Filter:  "datetime = today",
+	Sort:    "datetime",
+	System:  true,
+	Created: time.Now(),
+	Updated: time.Now(),
+}
+
+var mockView = &data.SavedView{
+	ID:      5,
+	UserID:  1,
+	Name:    "Waiting on client",
+	Filter:  "tag = waiting",
+	Sort:    "-created",
+	Created: time.Now(),
+	Updated: time.Now(),
+}
+
+type SavedViewModel struct{}
+
+func (m SavedViewModel) Insert(userID int, dto *data.CreateSavedViewDTO) (*data.SavedView, error) {
+	return mockView, nil
+}
+
+func (m SavedViewModel) GetByID(id int) (*data.SavedView, error) {
+	switch id {
+	case 1:
+		return mockSystemView, nil
+	case 5:
+		return mockView, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
+}
+
+func (m SavedViewModel) GetByUser(userID int) ([]*data.SavedView, error) {
+	return []*data.SavedView{mockSystemView, mockView}, nil
+}
+
+func (m SavedViewModel) Update(id int, dto *data.UpdateSavedViewDTO) (*data.SavedView, error) {
+	return mockView, nil
+}
+
+func (m SavedViewModel) Delete(id int) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package mock
+
+import (
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+type SyncModel struct{}
+
+func (m SyncModel) Changes(userID int, since data.SyncCursor) (*data.ChangeSet, error) {
+	if since.XID == 1 {
+		return nil, data.ErrSyncCursorExpired
+	}
+
+	cs := &data.ChangeSet{
+		Folders: []*data.Folder{},
+		Tasks:   []*data.Task{},
+		Deleted: data.SyncDeleted{Folders: []int{}, Tasks: []int{}},
+		Cursor:  data.SyncCursor{XID: 100}.String(),
+	}
+
+	if userID == mockFolder.UserID {
+		cs.Folders = append(cs.Folders, mockFolder)
+		cs.Tasks = append(cs.Tasks, mockTask)
+		cs.Deleted.Tasks = append(cs.Deleted.Tasks, 2)
+	}
+
+	return cs, nil
+}
+
+func (m SyncModel) Lock(recordType string, id int) (*data.SyncState, error) {
+	switch {
+	case recordType == data.SyncFolder && id == 1:
+		return &data.SyncState{UserID: mockFolder.UserID, Updated: mockFolder.Updated, Version: mockFolder.Version}, nil
+	case recordType == data.SyncTask && id == 1:
+		return &data.SyncState{UserID: 1, Updated: mockTask.Updated, Version: mockTask.Version}, nil
+	case recordType == data.SyncTask && id == 2:
+		return &data.SyncState{UserID: 1, Updated: mockTask.Updated, Deleted: true}, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
+}
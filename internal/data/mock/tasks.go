@@ -6,14 +6,60 @@ import (
 	"github.com/pafirmin/go-todo/internal/data"
 )
 
+var mockDatetime = time.Now()
+
 var mockTask = &data.Task{
 	ID:          1,
 	Title:       "Test",
 	Description: "Test",
-	Datetime:    time.Now(),
+	Datetime:    &mockDatetime,
 	Status:      "low",
 	FolderID:    1,
 	Created:     time.Now(),
+	Version:     2,
+}
+
+var mockRevision = &data.TaskRevision{
+	ID:     1,
+	TaskID: 1,
+	UserID: 1,
+	Changes: map[string]data.FieldChange{
+		"title": {Old: "Old title", New: "Test"},
+	},
+	Snapshot: data.TaskSnapshot{
+		Title:    "Test",
+		Datetime: &mockDatetime,
+		Status:   "default",
+		FolderID: 1,
+	},
+	Created: time.Now(),
+}
+
+var mockEndDatetime = mockDatetime.Add(-time.Hour)
+
+// mockStaleRevision ends before the mock task's datetime.
+var mockStaleRevision = &data.TaskRevision{
+	ID:     3,
+	TaskID: 1,
+	UserID: 1,
+	Snapshot: data.TaskSnapshot{
+		Title:       "Test",
+		Datetime:    &mockDatetime,
+		EndDatetime: &mockEndDatetime,
+		Status:      "default",
+		FolderID:    1,
+	},
+	Created: time.Now(),
+}
+
+var mockArchivedTask = &data.Task{
+	ID:          5,
+	Title:       "Archived",
+	Description: "Test",
+	Datetime:    &mockDatetime,
+	Status:      "default",
+	FolderID:    5,
+	Created:     time.Now(),
 }
 
 type TaskModel struct{}
@@ -22,34 +68,130 @@ func (t TaskModel) Insert(id int, dto *data.CreateTaskDTO) (*data.Task, error) {
 	return mockTask, nil
 }
 
-func (t TaskModel) GetByFolder(id int, status string, minDate, maxDate time.Time, filters data.Filters) ([]*data.Task, data.MetaData, error) {
+func (t TaskModel) GetByFolder(id int, tf data.TaskFilters, filters data.Filters) ([]*data.Task, data.MetaData, error) {
 	return []*data.Task{mockTask}, data.MetaData{}, nil
 }
 
+func (t TaskModel) GetAgenda(userID int, start, end time.Time) (*data.AgendaTasks, error) {
+	undated := *mockTask
+	undated.ID = 6
+	undated.Datetime = nil
+
+	// An all-day task over today and tomorrow.
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	dayAfter := today.AddDate(0, 0, 2)
+	allDay := *mockTask
+	allDay.ID = 7
+	allDay.Datetime = &today
+	allDay.EndDatetime = &dayAfter
+	allDay.AllDay = true
+
+	return &data.AgendaTasks{
+		Scheduled: []*data.Task{mockTask, &allDay},
+		Overdue:   []*data.Task{},
+		Undated:   []*data.Task{&undated},
+	}, nil
+}
+
 func (t TaskModel) GetByID(id int) (*data.Task, error) {
 	switch id {
 	case 1:
 		return mockTask, nil
+	case 3:
+		return mockDependent, nil
+	case 5:
+		return mockArchivedTask, nil
 	default:
 		return nil, data.ErrNoRecord
 	}
 }
 
-func (t TaskModel) GetByUser(
-	userID int,
-	folderIDs []string,
-	status string,
-	minDate,
-	maxDate time.Time,
-	filters data.Filters,
-) ([]*data.Task, data.MetaData, error) {
-	return []*data.Task{mockTask}, data.MetaData{}, nil
+func (t TaskModel) GetByUser(userID int, tf data.TaskFilters, filters data.Filters) ([]*data.Task, data.MetaData, error) {
+	metadata := data.MetaData{}
+	if filters.PageSize == 1 {
+		metadata.NextCursor = data.PageCursor{Sort: filters.Sort, Value: "2030-01-01 00:00:00", ID: mockTask.ID}.String()
+	}
+
+	return []*data.Task{mockTask}, metadata, nil
 }
 
-func (t TaskModel) Update(id int, dto *data.UpdateTaskDTO) (*data.Task, error) {
-	return mockTask, nil
+func (t TaskModel) Update(id, actorID int, dto *data.UpdateTaskDTO) (*data.Task, error) {
+	u := *mockTask
+	if dto.Status != nil {
+		u.Status = *dto.Status
+	}
+
+	if dto.EndDatetime != nil && *dto.EndDatetime != "" {
+		end, err := time.Parse(time.RFC3339, *dto.EndDatetime)
+		if err == nil && !end.After(*u.Datetime) {
+			return nil, &data.ScheduleError{Field: "end_datetime", Msg: "must end after datetime"}
+		}
+		u.EndDatetime = &end
+	}
+
+	return &u, nil
 }
 
-func (t TaskModel) Delete(id int) (int, error) {
+func (t TaskModel) Move(id, actorID int, dto *data.MoveTaskDTO) (*data.Task, error) {
+	for _, n := range []*int{dto.AfterID, dto.BeforeID} {
+		if n != nil && *n != 1 && *n != 3 {
+			return nil, data.ErrInvalidNeighbour
+		}
+	}
+
+	u := *mockTask
+	if dto.FolderID != nil {
+		u.FolderID = *dto.FolderID
+	}
+
+	return &u, nil
+}
+
+func (t TaskModel) Bulk(userID int, dto *data.BulkTaskDTO) (*data.BulkResult, error) {
+	res := &data.BulkResult{Action: dto.Action, Succeeded: []int{}, Failed: []*data.BulkFailure{}}
+
+	ids := dto.IDs
+	if dto.Filter != nil {
+		if dto.Filter.Status == "default" {
+			return nil, data.ErrBulkTooLarge
+		}
+		ids = []int{mockTask.ID}
+	}
+
+	for _, id := range ids {
+		switch id {
+		case mockTask.ID:
+			after := *mockTask
+			if dto.Action == data.BulkComplete {
+				after.Status = data.TaskStatusCompleted
+			}
+			res.Succeeded = append(res.Succeeded, id)
+			res.Changes = append(res.Changes, data.TaskChange{Before: mockTask, After: &after})
+		case mockArchivedTask.ID:
+			res.Failed = append(res.Failed, &data.BulkFailure{ID: id, Error: "folder is archived"})
+		default:
+			res.Failed = append(res.Failed, &data.BulkFailure{ID: id, Error: "not found"})
+		}
+	}
+
+	return res, nil
+}
+
+func (t TaskModel) Delete(id, version int) (int, error) {
 	return 1, nil
 }
+
+func (t TaskModel) GetRevisions(taskID int, filters data.Filters) ([]*data.TaskRevision, data.MetaData, error) {
+	return []*data.TaskRevision{mockRevision}, data.MetaData{}, nil
+}
+
+func (t TaskModel) GetRevision(taskID, revisionID int) (*data.TaskRevision, error) {
+	switch revisionID {
+	case 1:
+		return mockRevision, nil
+	case 3:
+		return mockStaleRevision, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
+}
@@ -0,0 +1,48 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+var mockTrashItems = []*data.TrashItem{
+	{Type: data.TrashFolder, ID: 2, Name: "Old folder", UserID: 1, DeletedAt: time.Now()},
+	{Type: data.TrashTask, ID: 2, Name: "Old task", FolderID: 1, UserID: 1, DeletedAt: time.Now()},
+	{Type: data.TrashTask, ID: 3, Name: "Orphaned task", FolderID: 2, UserID: 1, DeletedAt: time.Now()},
+}
+
+type TrashModel struct{}
+
+func (m TrashModel) GetByUser(userID int, itemType string, filters data.Filters) ([]*data.TrashItem, data.MetaData, error) {
+	items := []*data.TrashItem{}
+	for _, i := range mockTrashItems {
+		if i.UserID == userID && (itemType == "" || i.Type == itemType) {
+			items = append(items, i)
+		}
+	}
+
+	return items, data.MetaData{}, nil
+}
+
+func (m TrashModel) Get(itemType string, id int) (*data.TrashItem, error) {
+	for _, i := range mockTrashItems {
+		if i.Type == itemType && i.ID == id {
+			return i, nil
+		}
+	}
+
+	return nil, data.ErrNoRecord
+}
+
+func (m TrashModel) Restore(itemType string, id int) error {
+	if itemType == data.TrashTask && id == 3 {
+		return data.ErrParentTrashed
+	}
+
+	return nil
+}
+
+func (m TrashModel) Purge(before time.Time) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,15 @@
+package mock
+
+import (
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+type UsageModel struct{}
+
+func (m UsageModel) Global() (*data.Usage, error) {
+	return &data.Usage{Users: 2, Guests: 1, Folders: 1, Tasks: 1, ActiveSessions: 1}, nil
+}
+
+func (m UsageModel) ForUser(userID int) (*data.Usage, error) {
+	return &data.Usage{Folders: 1, Tasks: 1, ActiveSessions: 1}, nil
+}
@@ -0,0 +1,38 @@
+package mock
+
+import (
+	"github.com/pafirmin/go-todo/internal/data"
+)
+
+type UserSettingsModel struct{}
+
+func (m UserSettingsModel) Get(userID int) (*data.UserSettings, error) {
+	switch userID {
+	case 1, 2, 3:
+		return &data.UserSettings{TZ: data.DefaultTZ, Locale: data.DefaultLocale, WeekStart: data.DefaultWeekStart}, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
+}
+
+func (m UserSettingsModel) Update(userID int, dto *data.UpdateUserSettingsDTO) (*data.UserSettings, error) {
+	s, err := m.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dto.TZ != nil {
+		s.TZ = *dto.TZ
+	}
+	if dto.Locale != nil {
+		s.Locale = *dto.Locale
+	}
+	if dto.WeekStart != nil {
+		s.WeekStart = *dto.WeekStart
+	}
+	if dto.DefaultFolderID != nil && *dto.DefaultFolderID != 0 {
+		s.DefaultFolderID = dto.DefaultFolderID
+	}
+
+	return s, nil
+}
@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"strings"
 	"time"
 
 	"github.com/pafirmin/go-todo/internal/data"
@@ -10,37 +11,133 @@ var mockUser = &data.User{
 	ID:             1,
 	Email:          "mock@example.com",
 	HashedPassword: "1234",
+	Role:           data.RoleUser,
 	Created:        time.Now(),
 }
 
+var mockAdmin = &data.User{
+	ID:        3,
+	Email:     "admin@example.com",
+	FirstName: "Admin",
+	Role:      data.RoleAdmin,
+	Created:   time.Now(),
+}
+
+var mockGuest = &data.User{
+	ID:        2,
+	Email:     "guest-1234@guest.go-todo.local",
+	FirstName: "Guest",
+	LastName:  "User",
+	IsGuest:   true,
+	Role:      data.RoleUser,
+	Created:   time.Now(),
+}
+
 type UserModel struct{}
 
 func (m UserModel) Insert(dto *data.CreateUserDTO) (*data.User, error) {
+	// The mock user's email is taken in any case, but creating it as is
+	// stands in for a successful signup.
+	if strings.EqualFold(dto.Email, mockUser.Email) && dto.Email != mockUser.Email {
+		return nil, data.ErrDuplicateEmail
+	}
+
 	return mockUser, nil
 }
 
 func (m UserModel) Get(id int) (*data.User, error) {
 	switch id {
 	case 1:
-		return mockUser, nil
+		u := *mockUser
+		return &u, nil
+	case 2:
+		u := *mockGuest
+		return &u, nil
+	case 3:
+		u := *mockAdmin
+		return &u, nil
 	default:
 		return nil, data.ErrNoRecord
 	}
 }
 
 func (m UserModel) GetByEmail(email string) (*data.User, error) {
-	return mockUser, nil
+	switch email {
+	case mockUser.Email:
+		return mockUser, nil
+	default:
+		return nil, data.ErrNoRecord
+	}
 }
 
 func (m UserModel) Authenticate(cred *data.Credentials) (*data.User, error) {
 	switch cred.Email {
 	case "mock@example.com":
 		return mockUser, nil
+	case "locked@example.com":
+		return nil, data.ErrAccountLocked
+	case "disabled@example.com":
+		return nil, data.ErrAccountDisabled
 	default:
 		return nil, data.ErrInvalidCredentials
 	}
 }
 
 func (m UserModel) GetByToken(scope string, tokenText string) (*data.User, error) {
-	return mockUser, nil
+	switch tokenText {
+	case "invalid":
+		return nil, data.ErrNoRecord
+	case "disabled":
+		u := *mockUser
+		u.Disabled = true
+		return &u, nil
+	case "locked":
+		u := *mockUser
+		until := time.Now().Add(time.Hour)
+		u.LockedUntil = &until
+		return &u, nil
+	default:
+		return mockUser, nil
+	}
+}
+
+func (m UserModel) Lock(id int, until time.Time) error {
+	return nil
+}
+
+func (m UserModel) Unlock(id int) error {
+	return nil
+}
+
+func (m UserModel) InsertGuest() (*data.User, error) {
+	return mockGuest, nil
+}
+
+func (m UserModel) Upgrade(id int, dto *data.CreateUserDTO) (*data.User, error) {
+	switch dto.Email {
+	case "taken@example.com":
+		return nil, data.ErrDuplicateEmail
+	default:
+		return &data.User{ID: id, Email: dto.Email, FirstName: dto.FirstName, LastName: dto.LastName}, nil
+	}
+}
+
+func (m UserModel) Touch(id int) error {
+	return nil
+}
+
+func (m UserModel) DeleteInactiveGuests(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m UserModel) Search(query, role string, filters data.Filters) ([]*data.User, data.MetaData, error) {
+	return []*data.User{mockUser, mockAdmin}, data.MetaData{}, nil
+}
+
+func (m UserModel) SetDisabled(id int, disabled bool) error {
+	return nil
+}
+
+func (m UserModel) SetRole(id int, role string) error {
+	return nil
 }
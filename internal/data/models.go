@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -10,6 +11,9 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrAccountLocked      = errors.New("models: account locked")
+	ErrAccountDisabled    = errors.New("models: account disabled")
+	ErrEditConflict       = errors.New("models: edit conflict")
 )
 
 type Models struct {
@@ -19,21 +23,40 @@ type Models struct {
 		GetByEmail(string) (*User, error)
 		Authenticate(*Credentials) (*User, error)
 		GetByToken(string, string) (*User, error)
+		Lock(int, time.Time) error
+		Unlock(int) error
+		InsertGuest() (*User, error)
+		Upgrade(int, *CreateUserDTO) (*User, error)
+		Touch(int) error
+		DeleteInactiveGuests(time.Time) (int64, error)
+		Search(string, string, Filters) ([]*User, MetaData, error)
+		SetDisabled(int, bool) error
+		SetRole(int, string) error
 	}
 	Folders interface {
 		Insert(int, *CreateFolderDTO) (*Folder, error)
 		GetByID(int) (*Folder, error)
-		GetByUser(int, Filters) ([]*Folder, MetaData, error)
+		GetByUser(int, string, Filters) ([]*Folder, MetaData, error)
+		GetTree(int, string) ([]*Folder, error)
 		Update(int, *UpdateFolderDTO) (*Folder, error)
-		Delete(int) (int, error)
+		Move(int, int, *MoveFolderDTO) (*Folder, error)
+		Archive(int) (*Folder, error)
+		Unarchive(int) (*Folder, error)
+		Delete(int, int) (int, error)
 	}
 	Tasks interface {
 		Insert(int, *CreateTaskDTO) (*Task, error)
-		GetByUser(int, []string, string, time.Time, time.Time, Filters) ([]*Task, MetaData, error)
-		GetByFolder(int, string, time.Time, time.Time, Filters) ([]*Task, MetaData, error)
+		GetByUser(int, TaskFilters, Filters) ([]*Task, MetaData, error)
+		GetByFolder(int, TaskFilters, Filters) ([]*Task, MetaData, error)
+		GetAgenda(int, time.Time, time.Time) (*AgendaTasks, error)
+		GetOverlapping(int, time.Time, time.Time, int) ([]*Task, error)
 		GetByID(int) (*Task, error)
-		Update(int, *UpdateTaskDTO) (*Task, error)
-		Delete(int) (int, error)
+		Update(int, int, *UpdateTaskDTO) (*Task, error)
+		Move(int, int, *MoveTaskDTO) (*Task, error)
+		Bulk(int, *BulkTaskDTO) (*BulkResult, error)
+		Delete(int, int) (int, error)
+		GetRevisions(int, Filters) ([]*TaskRevision, MetaData, error)
+		GetRevision(int, int) (*TaskRevision, error)
 	}
 	Tokens interface {
 		New(int, time.Time, string) (*Token, error)
@@ -41,13 +64,143 @@ type Models struct {
 		DeleteForUser(string, int) error
 		Delete(string) error
 	}
+	LoginAttempts interface {
+		Record(string, string, time.Time) (*LoginFailures, error)
+		Delete(int) error
+		DeleteForEmail(string) error
+	}
+	Identities interface {
+		GetUser(string, string) (*User, error)
+		Insert(string, string, int) error
+	}
+	Impersonations interface {
+		Insert(int, int, string, time.Time) (*Impersonation, error)
+		GetAll(int, Filters) ([]*Impersonation, MetaData, error)
+	}
+	Usage interface {
+		Global() (*Usage, error)
+		ForUser(int) (*Usage, error)
+	}
+	Audit interface {
+		Insert(*AuditEvent) error
+		GetAll(AuditFilter, Filters) ([]*AuditEvent, MetaData, error)
+	}
+	Comments interface {
+		Insert(int, int, *CreateCommentDTO) (*Comment, error)
+		Get(int, int) (*Comment, error)
+		GetByTask(int, Filters) ([]*Comment, MetaData, error)
+		Update(int, *UpdateCommentDTO) (*Comment, error)
+		Delete(int) error
+	}
+	Attachments interface {
+		Insert(*Attachment, int64) error
+		Get(int) (*Attachment, error)
+		GetByTask(int) ([]*Attachment, error)
+		Used(int) (int64, error)
+		Orphans(int) ([]*Attachment, error)
+		Delete(int) error
+	}
+	Dependencies interface {
+		Insert(int, int, int) error
+		Delete(int, int) error
+		GetBlockers(int) ([]*Task, error)
+		GetDependents(int) ([]*Task, error)
+		Unblocked(int) ([]*Task, error)
+	}
+	Trash interface {
+		GetByUser(int, string, Filters) ([]*TrashItem, MetaData, error)
+		Get(string, int) (*TrashItem, error)
+		Restore(string, int) error
+		Purge(time.Time) (int64, error)
+	}
+	Idempotency interface {
+		Begin(int, string, string, time.Time, time.Time) (*IdempotentResponse, error)
+		Complete(int, string, *IdempotentResponse) error
+		Release(int, string) error
+		DeleteExpired() (int64, error)
+	}
+	Sync interface {
+		Changes(int, SyncCursor) (*ChangeSet, error)
+		Lock(string, int) (*SyncState, error)
+	}
+	SavedViews interface {
+		Insert(int, *CreateSavedViewDTO) (*SavedView, error)
+		GetByID(int) (*SavedView, error)
+		GetByUser(int) ([]*SavedView, error)
+		Update(int, *UpdateSavedViewDTO) (*SavedView, error)
+		Delete(int) error
+	}
+	UserSettings interface {
+		Get(int) (*UserSettings, error)
+		Update(int, *UpdateUserSettingsDTO) (*UserSettings, error)
+	}
+}
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so the models can be
+// bound to a transaction that spans several calls.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-func NewModels(db *sql.DB) Models {
+func NewModels(db DBTX) Models {
 	return Models{
-		Users:   UserModel{DB: db},
-		Folders: FolderModel{DB: db},
-		Tasks:   TaskModel{DB: db},
-		Tokens:  TokenModel{DB: db},
+		Users:          UserModel{DB: db},
+		Folders:        FolderModel{DB: db},
+		Tasks:          TaskModel{DB: db},
+		Tokens:         TokenModel{DB: db},
+		LoginAttempts:  LoginAttemptModel{DB: db},
+		Identities:     IdentityModel{DB: db},
+		Impersonations: ImpersonationModel{DB: db},
+		Usage:          UsageModel{DB: db},
+		Audit:          AuditModel{DB: db},
+		Trash:          TrashModel{DB: db},
+		Comments:       CommentModel{DB: db},
+		Attachments:    AttachmentModel{DB: db},
+		Dependencies:   DependencyModel{DB: db},
+		Sync:           SyncModel{DB: db},
+		Idempotency:    IdempotencyModel{DB: db},
+		SavedViews:     SavedViewModel{DB: db},
+		UserSettings:   UserSettingsModel{DB: db},
 	}
 }
+
+// withTx runs fn in a transaction. When db is already a transaction, fn runs
+// in a savepoint so that its failure does not abort the outer transaction.
+func withTx(ctx context.Context, db DBTX, fn func(*sql.Tx) error) error {
+	if tx, ok := db.(*sql.Tx); ok {
+		return withSavepoint(ctx, tx, fn)
+	}
+
+	tx, err := db.(*sql.DB).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func withSavepoint(ctx context.Context, tx *sql.Tx, fn func(*sql.Tx) error) error {
+	_, err := tx.ExecContext(ctx, "SAVEPOINT models_tx")
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT models_tx"); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT models_tx")
+	return err
+}
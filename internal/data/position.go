@@ -0,0 +1,338 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Positions are base-36 fractional keys compared byte-wise (the columns use
+// COLLATE "C"). A key never ends in '0', so there is always room to insert
+// another key between any two distinct keys.
+const (
+	positionDigits    = "0123456789abcdefghijklmnopqrstuvwxyz"
+	maxPositionLength = 48
+)
+
+var (
+	ErrInvalidNeighbour  = errors.New("models: neighbour is not in the same list")
+	errPositionExhausted = errors.New("models: no room between positions")
+)
+
+// positionAfter returns a key that sorts after a, preferring the shortest
+// one so that repeatedly appending grows keys slowly.
+func positionAfter(a string) string {
+	for i := 0; i < len(a); i++ {
+		if d := strings.IndexByte(positionDigits, a[i]); d < len(positionDigits)-1 {
+			return a[:i] + string(positionDigits[d+1])
+		}
+	}
+
+	return a + string(positionDigits[len(positionDigits)/2])
+}
+
+// positionBetween returns a key strictly between a and b. An empty a means
+// the start of the list and an empty b means the end.
+func positionBetween(a, b string) (string, error) {
+	if b == "" {
+		return positionAfter(a), nil
+	}
+
+	if a >= b {
+		return "", errPositionExhausted
+	}
+
+	return positionMidpoint(a, b), nil
+}
+
+func positionMidpoint(a, b string) string {
+	n := 0
+	for n < len(b) && positionDigit(a, n) == positionDigit(b, n) {
+		n++
+	}
+
+	if n > 0 {
+		return b[:n] + positionMidpoint(tail(a, n), b[n:])
+	}
+
+	da := positionDigit(a, 0)
+	db := len(positionDigits)
+	if b != "" {
+		db = positionDigit(b, 0)
+	}
+
+	if db-da > 1 {
+		return string(positionDigits[(da+db+1)/2])
+	}
+
+	if len(b) > 1 {
+		return b[:1]
+	}
+
+	return string(positionDigits[da]) + positionMidpoint(tail(a, 1), "")
+}
+
+func positionDigit(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+
+	return strings.IndexByte(positionDigits, s[i])
+}
+
+func tail(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+
+	return s[n:]
+}
+
+// evenPositions returns n short, evenly spaced keys in ascending order.
+func evenPositions(n int) []string {
+	base := len(positionDigits)
+
+	width, space := 1, base
+	for space <= n {
+		width++
+		space *= base
+	}
+
+	step := space / (n + 1)
+	keys := make([]string, n)
+
+	for i := range keys {
+		v := (i + 1) * step
+		b := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			b[j] = positionDigits[v%base]
+			v /= base
+		}
+		keys[i] = strings.TrimRight(string(b), "0")
+	}
+
+	return keys
+}
+
+// positionList identifies one independently ordered list: the tasks in a
+// folder, or the folders belonging to a user.
+type positionList struct {
+	table  string
+	column string
+	id     int
+}
+
+func taskList(folderID int) positionList {
+	return positionList{table: "tasks", column: "folder_id", id: folderID}
+}
+
+func folderList(userID int) positionList {
+	return positionList{table: "folders", column: "user_id", id: userID}
+}
+
+// lock serialises position changes within a single list without blocking
+// writers elsewhere in the table.
+func (l positionList) lock(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1), $2)`, l.table, l.id)
+	return err
+}
+
+func (l positionList) last(ctx context.Context, tx *sql.Tx, excludeID int) (string, error) {
+	stmt := fmt.Sprintf(`SELECT COALESCE(max(position), '') FROM %s
+	WHERE %s = $1 AND id <> $2 AND deleted_at IS NULL`, l.table, l.column)
+
+	var pos string
+	err := tx.QueryRowContext(ctx, stmt, l.id, excludeID).Scan(&pos)
+
+	return pos, err
+}
+
+func (l positionList) position(ctx context.Context, tx *sql.Tx, id int) (string, error) {
+	stmt := fmt.Sprintf(`SELECT position FROM %s
+	WHERE id = $1 AND %s = $2 AND deleted_at IS NULL`, l.table, l.column)
+
+	var pos string
+	err := tx.QueryRowContext(ctx, stmt, id, l.id).Scan(&pos)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidNeighbour
+	}
+
+	return pos, err
+}
+
+// adjacent returns the position of the nearest item above or below the item
+// at (pos, id), ignoring excludeID. An empty string means there is none.
+func (l positionList) adjacent(ctx context.Context, tx *sql.Tx, pos string, id, excludeID int, below bool) (string, error) {
+	cmp, dir := "<", "DESC"
+	if below {
+		cmp, dir = ">", "ASC"
+	}
+
+	stmt := fmt.Sprintf(`SELECT position FROM %s
+	WHERE %s = $1 AND id <> $2 AND deleted_at IS NULL
+	AND (position, id) %s ($3, $4)
+	ORDER BY position %s, id %s
+	LIMIT 1`, l.table, l.column, cmp, dir, dir)
+
+	var adj string
+	err := tx.QueryRowContext(ctx, stmt, l.id, excludeID, pos, id).Scan(&adj)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return adj, err
+}
+
+func (l positionList) rebalance(ctx context.Context, tx *sql.Tx) error {
+	stmt := fmt.Sprintf(`SELECT id FROM %s
+	WHERE %s = $1 AND deleted_at IS NULL
+	ORDER BY position, id
+	FOR UPDATE`, l.table, l.column)
+
+	rows, err := tx.QueryContext(ctx, stmt, l.id)
+	if err != nil {
+		return err
+	}
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt = fmt.Sprintf(`UPDATE %s SET position = v.position
+	FROM unnest($1::bigint[], $2::text[]) AS v(id, position)
+	WHERE %s.id = v.id`, l.table, l.table)
+
+	_, err = tx.ExecContext(ctx, stmt, pq.Array(ids), pq.Array(evenPositions(len(ids))))
+	return err
+}
+
+// next returns the position for appending a new item to the end of the list.
+func (l positionList) next(ctx context.Context, tx *sql.Tx) (string, error) {
+	err := l.lock(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+
+	last, err := l.last(ctx, tx, 0)
+	if err != nil {
+		return "", err
+	}
+
+	pos := positionAfter(last)
+	if len(pos) <= maxPositionLength {
+		return pos, nil
+	}
+
+	err = l.rebalance(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+
+	last, err = l.last(ctx, tx, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return positionAfter(last), nil
+}
+
+// move returns a position for id placed immediately after afterID and/or
+// before beforeID. With neither, the item goes to the end of the list. The
+// list is rebalanced if the neighbours have run out of room.
+func (l positionList) move(ctx context.Context, tx *sql.Tx, id int, afterID, beforeID *int) (string, error) {
+	err := l.lock(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 0; ; attempt++ {
+		lower, upper, err := l.bounds(ctx, tx, id, afterID, beforeID)
+		if err != nil {
+			return "", err
+		}
+
+		pos, err := positionBetween(lower, upper)
+		if beforeID != nil && upper == "" {
+			// Only legacy rows without a position can get here.
+			err = errPositionExhausted
+		}
+
+		if err == nil && len(pos) <= maxPositionLength {
+			return pos, nil
+		}
+
+		if attempt > 0 {
+			if err == nil {
+				return pos, nil
+			}
+			return "", ErrInvalidNeighbour
+		}
+
+		err = l.rebalance(ctx, tx)
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func (l positionList) bounds(ctx context.Context, tx *sql.Tx, id int, afterID, beforeID *int) (string, string, error) {
+	if (afterID != nil && *afterID == id) || (beforeID != nil && *beforeID == id) {
+		return "", "", ErrInvalidNeighbour
+	}
+
+	switch {
+	case afterID != nil && beforeID != nil:
+		lower, err := l.position(ctx, tx, *afterID)
+		if err != nil {
+			return "", "", err
+		}
+
+		upper, err := l.position(ctx, tx, *beforeID)
+		if err != nil {
+			return "", "", err
+		}
+
+		if lower > upper || (lower == upper && *afterID > *beforeID) {
+			return "", "", ErrInvalidNeighbour
+		}
+
+		return lower, upper, nil
+
+	case afterID != nil:
+		lower, err := l.position(ctx, tx, *afterID)
+		if err != nil {
+			return "", "", err
+		}
+
+		upper, err := l.adjacent(ctx, tx, lower, *afterID, id, true)
+		return lower, upper, err
+
+	case beforeID != nil:
+		upper, err := l.position(ctx, tx, *beforeID)
+		if err != nil {
+			return "", "", err
+		}
+
+		lower, err := l.adjacent(ctx, tx, upper, *beforeID, id, false)
+		return lower, upper, err
+
+	default:
+		last, err := l.last(ctx, tx, id)
+		return last, "", err
+	}
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// checkPosition fails unless key is a valid position strictly between a and
+// b, where an empty b means the end of the list.
+func checkPosition(t *testing.T, a, b, key string) {
+	t.Helper()
+
+	switch {
+	case key == "":
+		t.Fatalf("between %q and %q: got an empty key", a, b)
+	case strings.HasSuffix(key, "0"):
+		t.Fatalf("between %q and %q: got %q, which ends in 0", a, b, key)
+	case strings.Trim(key, positionDigits) != "":
+		t.Fatalf("between %q and %q: got %q, which is not base 36", a, b, key)
+	case key <= a || (b != "" && key >= b):
+		t.Fatalf("between %q and %q: got %q, which is out of order", a, b, key)
+	}
+}
+
+func TestPositionBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"Empty list", "", "", "i"},
+		{"Append", "i", "", "j"},
+		{"Append after last digit", "z", "", "zi"},
+		{"Prepend", "", "i", "9"},
+		{"Prepend before smallest", "", "1", "0i"},
+		{"Room between", "a", "c", "b"},
+		{"Adjacent digits", "a", "b", "ai"},
+		{"Prefix", "a", "a1", "a0i"},
+		{"Longer first key", "az", "b", "azi"},
+		{"Shared zeros", "i", "i01", "i00i"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := positionBetween(tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+
+			checkPosition(t, tt.a, tt.b, got)
+		})
+	}
+}
+
+func TestPositionBetweenOutOfOrder(t *testing.T) {
+	for _, keys := range [][2]string{{"b", "a"}, {"a", "a"}} {
+		if _, err := positionBetween(keys[0], keys[1]); !errors.Is(err, errPositionExhausted) {
+			t.Errorf("between %q and %q: want errPositionExhausted; got %v", keys[0], keys[1], err)
+		}
+	}
+}
+
+func TestPositionBetweenRepeated(t *testing.T) {
+	// Inserting at the same place over and over must keep finding room.
+	a, b := "a", "b"
+	for i := 0; i < 100; i++ {
+		key, err := positionBetween(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPosition(t, a, b, key)
+
+		if i%2 == 0 {
+			a = key
+		} else {
+			b = key
+		}
+	}
+}
+
+func TestEvenPositions(t *testing.T) {
+	tests := []struct {
+		n        int
+		maxWidth int
+	}{
+		{0, 0},
+		{1, 1},
+		{35, 1},
+		{36, 2},
+		{100, 2},
+		{1295, 2},
+		{1296, 3},
+		{2000, 3},
+	}
+
+	for _, tt := range tests {
+		keys := evenPositions(tt.n)
+		if len(keys) != tt.n {
+			t.Fatalf("evenPositions(%d): want %d keys; got %d", tt.n, tt.n, len(keys))
+		}
+
+		prev := ""
+		for _, key := range keys {
+			checkPosition(t, prev, "", key)
+			if len(key) > tt.maxWidth {
+				t.Errorf("evenPositions(%d): want keys of up to %d digits; got %q", tt.n, tt.maxWidth, key)
+			}
+			prev = key
+		}
+	}
+}
+
+// positionKey turns arbitrary bytes into a valid position, or the empty
+// string for an open end.
+func positionKey(b []byte) string {
+	key := make([]byte, len(b))
+	for i, c := range b {
+		key[i] = positionDigits[int(c)%len(positionDigits)]
+	}
+
+	return strings.TrimRight(string(key), "0")
+}
+
+func FuzzPositionBetween(f *testing.F) {
+	for _, keys := range [][2]string{{"", ""}, {"a", "b"}, {"z", ""}, {"", "01"}, {"i", "i01"}, {"b", "a"}} {
+		f.Add([]byte(keys[0]), []byte(keys[1]))
+	}
+
+	f.Fuzz(func(t *testing.T, ab, bb []byte) {
+		a, b := positionKey(ab), positionKey(bb)
+
+		key, err := positionBetween(a, b)
+		if b != "" && a >= b {
+			if !errors.Is(err, errPositionExhausted) {
+				t.Fatalf("between %q and %q: want errPositionExhausted; got %q, %v", a, b, key, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("between %q and %q: %v", a, b, err)
+		}
+
+		checkPosition(t, a, b, key)
+	})
+}
@@ -0,0 +1,194 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/filter"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+// TaskSortSafeList holds the values tasks can be sorted by.
+var TaskSortSafeList = []string{"id", "created", "datetime", "position", "-id", "-created", "-datetime", "-position"}
+
+type SavedViewModel struct {
+	DB DBTX
+}
+
+// SavedView is a named task filter and sort order. System views are built
+// in and shared by all users, and can't be changed.
+type SavedView struct {
+	ID      int       `json:"id"`
+	UserID  int       `json:"user_id,omitempty"`
+	Name    string    `json:"name"`
+	Filter  string    `json:"filter"`
+	Sort    string    `json:"sort"`
+	System  bool      `json:"system"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+type CreateSavedViewDTO struct {
+	Name   string `json:"name"`
+	Filter string `json:"filter"`
+	Sort   string `json:"sort"`
+}
+
+func (d *CreateSavedViewDTO) Validate(v *validator.Validator) {
+	v.ValidLength("name", d.Name, 1, 100)
+	validateViewFilter(v, d.Filter)
+	if d.Sort != "" {
+		v.PermittedValue("sort", d.Sort, TaskSortSafeList...)
+	}
+}
+
+type UpdateSavedViewDTO struct {
+	Name   *string `json:"name"`
+	Filter *string `json:"filter"`
+	Sort   *string `json:"sort"`
+}
+
+func (d *UpdateSavedViewDTO) Validate(v *validator.Validator) {
+	if d.Name != nil {
+		v.ValidLength("name", *d.Name, 1, 100)
+	}
+	if d.Filter != nil {
+		validateViewFilter(v, *d.Filter)
+	}
+	if d.Sort != nil {
+		v.PermittedValue("sort", *d.Sort, TaskSortSafeList...)
+	}
+}
+
+func validateViewFilter(v *validator.Validator, s string) {
+	if s == "" {
+		return
+	}
+
+	e, err := filter.Parse(s)
+	if err != nil {
+		v.AddError("filter", err.Error())
+		return
+	}
+
+	TaskFilters{Filter: e}.Validate(v)
+}
+
+const savedViewColumns = `id, COALESCE(user_id, 0), name, filter, sort, user_id IS NULL, created, updated`
+
+func scanSavedView(row interface{ Scan(...interface{}) error }) (*SavedView, error) {
+	sv := &SavedView{}
+	err := row.Scan(&sv.ID, &sv.UserID, &sv.Name, &sv.Filter, &sv.Sort, &sv.System, &sv.Created, &sv.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return sv, nil
+}
+
+func (m SavedViewModel) Insert(userID int, dto *CreateSavedViewDTO) (*SavedView, error) {
+	stmt := `INSERT INTO saved_views (user_id, name, filter, sort)
+	VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'datetime'))
+	RETURNING ` + savedViewColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return scanSavedView(m.DB.QueryRowContext(ctx, stmt, userID, dto.Name, dto.Filter, dto.Sort))
+}
+
+func (m SavedViewModel) GetByID(id int) (*SavedView, error) {
+	stmt := `SELECT ` + savedViewColumns + ` FROM saved_views WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sv, err := scanSavedView(m.DB.QueryRowContext(ctx, stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return sv, nil
+}
+
+// GetByUser returns the system views followed by the user's own views.
+func (m SavedViewModel) GetByUser(userID int) ([]*SavedView, error) {
+	stmt := `SELECT ` + savedViewColumns + `
+	FROM saved_views
+	WHERE user_id = $1 OR user_id IS NULL
+	ORDER BY user_id IS NOT NULL, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	views := []*SavedView{}
+
+	for rows.Next() {
+		sv, err := scanSavedView(rows)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, sv)
+	}
+
+	return views, rows.Err()
+}
+
+func (m SavedViewModel) Update(id int, dto *UpdateSavedViewDTO) (*SavedView, error) {
+	stmt := `UPDATE saved_views
+	SET name = COALESCE($1, name),
+		filter = COALESCE($2, filter),
+		sort = COALESCE($3, sort),
+		updated = now()
+	WHERE id = $4
+	AND user_id IS NOT NULL
+	RETURNING ` + savedViewColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sv, err := scanSavedView(m.DB.QueryRowContext(ctx, stmt, dto.Name, dto.Filter, dto.Sort, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return sv, nil
+}
+
+func (m SavedViewModel) Delete(id int) error {
+	stmt := `DELETE FROM saved_views WHERE id = $1 AND user_id IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var insertRX = regexp.MustCompile(`(?is)INSERT INTO\s+(\w+)\s*\(([^)]*)\)\s*VALUES\s*\(`)
+
+// splitValues returns the comma-separated expressions in s, which starts
+// just after the opening parenthesis of a VALUES list, up to its closing
+// parenthesis.
+func splitValues(s string) ([]string, bool) {
+	var values []string
+	depth, start, quoted := 0, 0, false
+
+	for i, c := range s {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')':
+			return append(values, s[start:i]), true
+		case c == ',' && depth == 0:
+			values = append(values, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return nil, false
+}
+
+// TestInsertColumnCounts checks that every INSERT in the package gives as
+// many values as it names columns, which the mocked handler tests can't.
+func TestInsertColumnCounts(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	checked := 0
+
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+
+			for _, loc := range insertRX.FindAllStringSubmatchIndex(s, -1) {
+				table := s[loc[2]:loc[3]]
+				columns := strings.Split(s[loc[4]:loc[5]], ",")
+
+				values, ok := splitValues(s[loc[1]:])
+				if !ok {
+					t.Errorf("%s: INSERT INTO %s: unterminated VALUES", fset.Position(lit.Pos()), table)
+					continue
+				}
+
+				if len(values) != len(columns) {
+					t.Errorf("%s: INSERT INTO %s names %d columns but gives %d values", fset.Position(lit.Pos()), table, len(columns), len(values))
+				}
+				checked++
+			}
+
+			return true
+		})
+	}
+
+	if checked == 0 {
+		t.Fatal("found no INSERT statements to check")
+	}
+}
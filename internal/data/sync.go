@@ -0,0 +1,323 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxSyncChanges caps the number of changed records returned by one sync.
+const MaxSyncChanges = 500
+
+const (
+	SyncFolder = "folder"
+	SyncTask   = "task"
+)
+
+var (
+	ErrInvalidSyncCursor = errors.New("models: invalid sync cursor")
+	ErrSyncCursorExpired = errors.New("models: sync cursor has expired")
+)
+
+type SyncModel struct {
+	DB DBTX
+}
+
+// SyncCursor marks a position in the stream of changes. Changes are ordered
+// by the id of the transaction that made them, then by type and id. A zero
+// cursor is the start of the stream.
+type SyncCursor struct {
+	XID  uint64
+	Type string
+	ID   int
+}
+
+func (c SyncCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%s.%d", c.XID, c.Type, c.ID)))
+}
+
+func ParseSyncCursor(s string) (SyncCursor, error) {
+	if s == "" {
+		return SyncCursor{}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+
+	parts := strings.Split(string(b), ".")
+	if len(parts) != 3 {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+
+	xid, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+
+	return SyncCursor{XID: xid, Type: parts[1], ID: id}, nil
+}
+
+type SyncDeleted struct {
+	Folders []int `json:"folders"`
+	Tasks   []int `json:"tasks"`
+}
+
+type ChangeSet struct {
+	Folders []*Folder   `json:"folders"`
+	Tasks   []*Task     `json:"tasks"`
+	Deleted SyncDeleted `json:"deleted"`
+	Cursor  string      `json:"cursor"`
+	HasMore bool        `json:"has_more"`
+}
+
+// SyncState is the server-side state of a record a client wants to change.
+type SyncState struct {
+	UserID  int
+	Updated time.Time
+	Version int
+	Deleted bool
+}
+
+// Changes returns the folders and tasks of a user that have changed since
+// the cursor. Tasks in a trashed folder are reported as deleted.
+func (m SyncModel) Changes(userID int, since SyncCursor) (*ChangeSet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Changes made by transactions that are still running, or that started
+	// after one that is still running, are held back until the next sync.
+	var horizon, upTo uint64
+
+	stmt := `SELECT sync_horizon.change_xid::text, pg_snapshot_xmin(pg_current_snapshot())::text FROM sync_horizon`
+
+	err := scanXIDs(m.DB.QueryRowContext(ctx, stmt), &horizon, &upTo)
+	if err != nil {
+		return nil, err
+	}
+
+	if since.XID != 0 && since.XID <= horizon {
+		return nil, ErrSyncCursorExpired
+	}
+
+	stmt = `SELECT type, id, deleted, change_xid::text
+	FROM (
+		SELECT 'folder' AS type, folders.id, folders.deleted_at IS NOT NULL AS deleted, folders.change_xid
+		FROM folders
+		WHERE folders.user_id = $1
+		UNION ALL
+		SELECT 'task', tasks.id, tasks.deleted_at IS NOT NULL OR folders.deleted_at IS NOT NULL, tasks.change_xid
+		FROM tasks
+		INNER JOIN folders ON folders.id = tasks.folder_id
+		WHERE folders.user_id = $1
+	) AS changes
+	WHERE (change_xid, type, id) > ($2::text::xid8, $3, $4)
+	AND change_xid < $5::text::xid8
+	ORDER BY change_xid, type, id
+	LIMIT $6`
+
+	args := []interface{}{userID, strconv.FormatUint(since.XID, 10), since.Type, since.ID, strconv.FormatUint(upTo, 10), MaxSyncChanges + 1}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &ChangeSet{
+		Folders: []*Folder{},
+		Tasks:   []*Task{},
+		Deleted: SyncDeleted{Folders: []int{}, Tasks: []int{}},
+	}
+
+	var folderIDs, taskIDs []int
+	last := since
+
+	for rows.Next() {
+		if len(folderIDs)+len(taskIDs)+len(cs.Deleted.Folders)+len(cs.Deleted.Tasks) == MaxSyncChanges {
+			cs.HasMore = true
+			break
+		}
+
+		var c SyncCursor
+		var deleted bool
+		var xid string
+
+		if err := rows.Scan(&c.Type, &c.ID, &deleted, &xid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		c.XID, err = strconv.ParseUint(xid, 10, 64)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		switch {
+		case c.Type == SyncFolder && deleted:
+			cs.Deleted.Folders = append(cs.Deleted.Folders, c.ID)
+		case c.Type == SyncFolder:
+			folderIDs = append(folderIDs, c.ID)
+		case deleted:
+			cs.Deleted.Tasks = append(cs.Deleted.Tasks, c.ID)
+		default:
+			taskIDs = append(taskIDs, c.ID)
+		}
+
+		last = c
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if cs.HasMore {
+		cs.Cursor = last.String()
+	} else {
+		cs.Cursor = SyncCursor{XID: upTo}.String()
+	}
+
+	if len(folderIDs) > 0 {
+		cs.Folders, err = syncFolders(ctx, m.DB, folderIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(taskIDs) > 0 {
+		cs.Tasks, err = syncTasks(ctx, m.DB, taskIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cs, nil
+}
+
+func syncFolders(ctx context.Context, db DBTX, ids []int) ([]*Folder, error) {
+	stmt := `SELECT id, name, created, updated, user_id, parent_id, position, archived_at, version
+	FROM folders
+	WHERE folders.id = ANY ($1::bigint[])
+	ORDER BY folders.id`
+
+	rows, err := db.QueryContext(ctx, stmt, pq.Array(int64s(ids)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	folders := []*Folder{}
+
+	for rows.Next() {
+		f := &Folder{}
+		err := rows.Scan(&f.ID, &f.Name, &f.Created, &f.Updated, &f.UserID, &f.ParentID, &f.Position, &f.ArchivedAt, &f.Version)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+
+	return folders, rows.Err()
+}
+
+func syncTasks(ctx context.Context, db DBTX, ids []int) ([]*Task, error) {
+	stmt := `SELECT ` + taskReturning + `
+	FROM tasks
+	WHERE tasks.id = ANY ($1::bigint[])
+	ORDER BY tasks.id`
+
+	rows, err := db.QueryContext(ctx, stmt, pq.Array(int64s(ids)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tasks := []*Task{}
+
+	for rows.Next() {
+		t := &Task{}
+		if err := scanReturnedTask(rows, t); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, rows.Err()
+}
+
+// Lock returns the state of a folder or task and locks it until the end of
+// the transaction, so that a client's change can be checked against it
+// before being applied. It should be called on models bound to a
+// transaction.
+func (m SyncModel) Lock(recordType string, id int) (*SyncState, error) {
+	var stmt string
+
+	switch recordType {
+	case SyncFolder:
+		stmt = `SELECT user_id, updated, version, deleted_at IS NOT NULL
+		FROM folders
+		WHERE folders.id = $1
+		FOR UPDATE`
+	case SyncTask:
+		stmt = `SELECT folders.user_id, tasks.updated, tasks.version, tasks.deleted_at IS NOT NULL OR folders.deleted_at IS NOT NULL
+		FROM tasks
+		INNER JOIN folders ON folders.id = tasks.folder_id
+		WHERE tasks.id = $1
+		FOR UPDATE OF tasks`
+	default:
+		return nil, ErrNoRecord
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s := &SyncState{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&s.UserID, &s.Updated, &s.Version, &s.Deleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func scanXIDs(row *sql.Row, dst ...*uint64) error {
+	strs := make([]string, len(dst))
+	ptrs := make([]interface{}, len(dst))
+	for i := range strs {
+		ptrs[i] = &strs[i]
+	}
+
+	if err := row.Scan(ptrs...); err != nil {
+		return err
+	}
+
+	for i, s := range strs {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		*dst[i] = n
+	}
+
+	return nil
+}
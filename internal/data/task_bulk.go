@@ -0,0 +1,329 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+// MaxBulkTasks caps the number of tasks a single bulk operation can touch.
+const MaxBulkTasks = 500
+
+const (
+	BulkUpdate    = "update"
+	BulkMove      = "move"
+	BulkComplete  = "complete"
+	BulkDelete    = "delete"
+	BulkAddTag    = "add_tag"
+	BulkRemoveTag = "remove_tag"
+)
+
+var ErrBulkTooLarge = errors.New("models: too many tasks for a bulk operation")
+
+type BulkTaskFilterDTO struct {
+	FolderIDs []int  `json:"folder_id"`
+	Status    string `json:"status"`
+	MinDate   string `json:"min_date"`
+	MaxDate   string `json:"max_date"`
+	Blocked   *bool  `json:"blocked"`
+	Tag       string `json:"tag"`
+	// Location is the time zone dates are in, UTC if nil.
+	Location *time.Location `json:"-"`
+}
+
+func (d *BulkTaskFilterDTO) Validate(v *validator.Validator) {
+	for _, id := range d.FolderIDs {
+		v.Check(id > 0, "filter.folder_id", "must contain positive integers")
+	}
+	if d.MinDate != "" {
+		v.ValidDate("filter.min_date", d.MinDate)
+	}
+	if d.MaxDate != "" {
+		v.ValidDate("filter.max_date", d.MaxDate)
+	}
+}
+
+type BulkTaskDTO struct {
+	IDs      []int              `json:"ids"`
+	Filter   *BulkTaskFilterDTO `json:"filter"`
+	Action   string             `json:"action"`
+	Update   *UpdateTaskDTO     `json:"update"`
+	FolderID *int               `json:"folder_id"`
+	Tag      string             `json:"tag"`
+}
+
+func (d *BulkTaskDTO) Validate(v *validator.Validator) {
+	v.Check((d.IDs == nil) != (d.Filter == nil), "ids", "either ids or filter must be given, but not both")
+	v.Check(len(d.IDs) <= MaxBulkTasks, "ids", fmt.Sprintf("must not contain more than %d ids", MaxBulkTasks))
+	if d.IDs != nil {
+		v.Check(len(d.IDs) > 0, "ids", "must not be empty")
+	}
+	for _, id := range d.IDs {
+		v.Check(id > 0, "ids", "must contain positive integers")
+	}
+
+	if d.Filter != nil {
+		d.Filter.Validate(v)
+	}
+
+	v.PermittedValue("action", d.Action, BulkUpdate, BulkMove, BulkComplete, BulkDelete, BulkAddTag, BulkRemoveTag)
+
+	switch d.Action {
+	case BulkUpdate:
+		v.Check(d.Update != nil, "update", "must be provided for the update action")
+		if d.Update != nil {
+			v.Check(d.Update.FolderID == nil, "update.folder_id", "use the move action to change folders")
+			d.Update.Validate(v)
+		}
+	case BulkMove:
+		v.Check(d.FolderID != nil && *d.FolderID > 0, "folder_id", "must be a positive integer")
+	case BulkAddTag, BulkRemoveTag:
+		ValidateTag(v, "tag", d.Tag)
+	}
+}
+
+type BulkFailure struct {
+	ID    int    `json:"id"`
+	Error string `json:"error"`
+}
+
+// TaskChange pairs the state of a task before and after a bulk operation.
+// After is nil for deleted tasks.
+type TaskChange struct {
+	Before *Task
+	After  *Task
+}
+
+type BulkResult struct {
+	Action    string         `json:"action"`
+	Succeeded []int          `json:"succeeded"`
+	Failed    []*BulkFailure `json:"failed"`
+	Changes   []TaskChange   `json:"-"`
+}
+
+// Bulk applies dto.Action to every matching task in a single transaction.
+// Tasks that do not exist, belong to another user or sit in an archived
+// folder are reported as failures rather than aborting the whole operation.
+func (m TaskModel) Bulk(userID int, dto *BulkTaskDTO) (*BulkResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res := &BulkResult{Action: dto.Action, Succeeded: []int{}, Failed: []*BulkFailure{}}
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		var ids []int
+		var err error
+
+		if dto.Filter != nil {
+			ids, err = bulkFilterTargets(ctx, tx, userID, dto.Filter)
+		} else {
+			ids, err = bulkTargets(ctx, tx, userID, dto.IDs, res)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Lock in a consistent order so concurrent bulk operations cannot
+		// deadlock each other.
+		sort.Ints(ids)
+
+		for _, id := range ids {
+			before, err := lockTask(ctx, tx, id)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					res.Failed = append(res.Failed, &BulkFailure{ID: id, Error: "not found"})
+					continue
+				}
+				return err
+			}
+
+			change := TaskChange{Before: before}
+
+			if dto.Action == BulkDelete {
+				_, err = tx.ExecContext(ctx, `UPDATE tasks SET deleted_at = now() WHERE tasks.id = $1`, id)
+			} else {
+				change.After, err = updateTask(ctx, tx, userID, before, bulkUpdate(dto, before))
+			}
+
+			var se *ScheduleError
+			if errors.As(err, &se) {
+				res.Failed = append(res.Failed, &BulkFailure{ID: id, Error: se.Field + " " + se.Msg})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			res.Succeeded = append(res.Succeeded, id)
+			res.Changes = append(res.Changes, change)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func bulkUpdate(dto *BulkTaskDTO, t *Task) *UpdateTaskDTO {
+	switch dto.Action {
+	case BulkMove:
+		return &UpdateTaskDTO{FolderID: dto.FolderID}
+	case BulkComplete:
+		status := TaskStatusCompleted
+		return &UpdateTaskDTO{Status: &status}
+	case BulkAddTag:
+		tags := append(append([]string{}, t.Tags...), dto.Tag)
+		return &UpdateTaskDTO{Tags: &tags}
+	case BulkRemoveTag:
+		tag := strings.ToLower(strings.TrimSpace(dto.Tag))
+		tags := []string{}
+		for _, existing := range t.Tags {
+			if existing != tag {
+				tags = append(tags, existing)
+			}
+		}
+		return &UpdateTaskDTO{Tags: &tags}
+	default:
+		return dto.Update
+	}
+}
+
+// bulkTargets checks each requested id individually, recording the ones that
+// cannot be changed in res and returning the rest.
+func bulkTargets(ctx context.Context, tx *sql.Tx, userID int, ids []int, res *BulkResult) ([]int, error) {
+	stmt := `SELECT tasks.id, folders.user_id, folders.archived_at IS NOT NULL
+	FROM tasks
+	INNER JOIN folders ON folders.id = tasks.folder_id
+	WHERE tasks.id = ANY ($1::bigint[])
+	AND tasks.deleted_at IS NULL
+	AND folders.deleted_at IS NULL`
+
+	rows, err := tx.QueryContext(ctx, stmt, pq.Array(int64s(ids)))
+	if err != nil {
+		return nil, err
+	}
+
+	type target struct {
+		userID   int
+		archived bool
+	}
+
+	found := make(map[int]target, len(ids))
+
+	for rows.Next() {
+		var id int
+		var t target
+		if err := rows.Scan(&id, &t.userID, &t.archived); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		found[id] = t
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	allowed := []int{}
+	seen := make(map[int]bool, len(ids))
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		t, ok := found[id]
+		switch {
+		case !ok:
+			res.Failed = append(res.Failed, &BulkFailure{ID: id, Error: "not found"})
+		case t.userID != userID:
+			res.Failed = append(res.Failed, &BulkFailure{ID: id, Error: "forbidden"})
+		case t.archived:
+			res.Failed = append(res.Failed, &BulkFailure{ID: id, Error: "folder is archived"})
+		default:
+			allowed = append(allowed, id)
+		}
+	}
+
+	return allowed, nil
+}
+
+func bulkFilterTargets(ctx context.Context, tx *sql.Tx, userID int, f *BulkTaskFilterDTO) ([]int, error) {
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	// Dates have been validated, so parse errors leave them zero.
+	minDate, _ := time.ParseInLocation("2006-01-02", f.MinDate, loc)
+	maxDate, _ := time.ParseInLocation("2006-01-02", f.MaxDate, loc)
+	if !maxDate.IsZero() {
+		maxDate = maxDate.AddDate(0, 0, 1)
+	}
+
+	args := queryArgs{userID, pq.Array(int64s(f.FolderIDs)), f.Status, f.Blocked, strings.ToLower(f.Tag), MaxBulkTasks + 1}
+	dates := append([]string{"TRUE"}, overlapConds(minDate, maxDate, &args)...)
+
+	stmt := `SELECT tasks.id
+	FROM tasks
+	INNER JOIN folders ON folders.id = tasks.folder_id
+	WHERE folders.user_id = $1
+	AND tasks.deleted_at IS NULL
+	AND folders.deleted_at IS NULL
+	AND folders.archived_at IS NULL
+	AND (tasks.folder_id = ANY ($2::bigint[]) OR $2 = '{}')
+	AND (tasks.status LIKE $3 OR $3 = '')
+	AND ($4::boolean IS NULL OR ` + taskBlocked + ` = $4)
+	AND ($5 = '' OR $5 = ANY (tasks.tags))
+	AND ` + strings.Join(dates, " AND ") + `
+	ORDER BY tasks.id
+	LIMIT $6`
+
+	rows, err := tx.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) > MaxBulkTasks {
+		return nil, ErrBulkTooLarge
+	}
+
+	return ids, nil
+}
+
+func int64s(ints []int) []int64 {
+	out := make([]int64, len(ints))
+	for i, n := range ints {
+		out[i] = int64(n)
+	}
+
+	return out
+}
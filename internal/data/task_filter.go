@@ -0,0 +1,283 @@
+package data
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pafirmin/go-todo/internal/filter"
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+// queryArgs collects the arguments of a query as its placeholders are
+// written.
+type queryArgs []interface{}
+
+func (a *queryArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+const (
+	fieldText = iota
+	fieldInt
+	fieldTime
+	// fieldSchedule is a time that all-day tasks have a date for instead.
+	fieldSchedule
+	fieldTag
+	fieldBool
+)
+
+type taskField struct {
+	column   string
+	kind     int
+	nullable bool
+}
+
+// taskFilterFields are the fields a task filter expression may refer to.
+var taskFilterFields = map[string]taskField{
+	"id":           {"tasks.id", fieldInt, false},
+	"title":        {"tasks.title", fieldText, false},
+	"description":  {"tasks.description", fieldText, false},
+	"status":       {"tasks.status", fieldText, false},
+	"datetime":     {"tasks.datetime", fieldSchedule, true},
+	"end_datetime": {"tasks.end_datetime", fieldSchedule, true},
+	"all_day":      {"tasks.all_day", fieldBool, false},
+	"created":      {"tasks.created", fieldTime, false},
+	"updated":      {"tasks.updated", fieldTime, false},
+	"folder_id":    {"tasks.folder_id", fieldInt, false},
+	"tag":          {"tasks.tags", fieldTag, false},
+	"blocked":      {taskBlocked, fieldBool, false},
+}
+
+var fieldOps = map[int][]string{
+	fieldText:     {filter.OpEq, filter.OpNe, filter.OpContains, filter.OpIn},
+	fieldInt:      {filter.OpEq, filter.OpNe, filter.OpLt, filter.OpLe, filter.OpGt, filter.OpGe, filter.OpIn},
+	fieldTime:     {filter.OpEq, filter.OpNe, filter.OpLt, filter.OpLe, filter.OpGt, filter.OpGe},
+	fieldSchedule: {filter.OpEq, filter.OpNe, filter.OpLt, filter.OpLe, filter.OpGt, filter.OpGe},
+	fieldTag:      {filter.OpEq, filter.OpNe, filter.OpIn},
+	fieldBool:     {filter.OpEq, filter.OpNe},
+}
+
+var relativeDateRX = regexp.MustCompile(`^today(?:([+-]\d{1,4})d)?$`)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (f TaskFilters) Validate(v *validator.Validator) {
+	if f.Filter == nil {
+		return
+	}
+
+	var args queryArgs
+	if _, err := compileTaskFilter(f.Filter, &args, f.now()); err != nil {
+		v.AddError("filter", err.Error())
+	}
+}
+
+// now returns the current time in the time zone of the filters.
+func (f TaskFilters) now() time.Time {
+	if f.Location == nil {
+		return time.Now().UTC()
+	}
+
+	return time.Now().In(f.Location)
+}
+
+// where returns the conditions selecting the tasks that match the date range
+// and filter expression, each starting with AND. Tasks match the date range
+// if they take place on any of its days.
+func (f TaskFilters) where(args *queryArgs) (string, error) {
+	var conds []string
+
+	var to time.Time
+	if !f.MaxDate.IsZero() {
+		to = f.MaxDate.AddDate(0, 0, 1)
+	}
+
+	for _, cond := range overlapConds(f.MinDate, to, args) {
+		conds = append(conds, "AND "+cond)
+	}
+
+	if f.Filter != nil {
+		cond, err := compileTaskFilter(f.Filter, args, f.now())
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, "AND "+cond)
+	}
+
+	return strings.Join(conds, "\n"), nil
+}
+
+// compileTaskFilter compiles a filter expression into an SQL condition on
+// tasks, adding the values it compares against to args. Dates are days in
+// the time zone of now, and relative dates are resolved against it.
+func compileTaskFilter(e filter.Expr, args *queryArgs, now time.Time) (string, error) {
+	switch e := e.(type) {
+	case *filter.And:
+		return compileTaskFilterPair(e.Left, e.Right, "AND", args, now)
+	case *filter.Or:
+		return compileTaskFilterPair(e.Left, e.Right, "OR", args, now)
+	case *filter.Not:
+		x, err := compileTaskFilter(e.X, args, now)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + x, nil
+	case *filter.Comparison:
+		return compileTaskComparison(e, args, now)
+	default:
+		return "", fmt.Errorf("unsupported expression %s", e)
+	}
+}
+
+func compileTaskFilterPair(left, right filter.Expr, op string, args *queryArgs, now time.Time) (string, error) {
+	l, err := compileTaskFilter(left, args, now)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := compileTaskFilter(right, args, now)
+	if err != nil {
+		return "", err
+	}
+
+	return "(" + l + " " + op + " " + r + ")", nil
+}
+
+func compileTaskComparison(c *filter.Comparison, args *queryArgs, now time.Time) (string, error) {
+	field, ok := taskFilterFields[c.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", c.Field)
+	}
+
+	supported := false
+	for _, op := range fieldOps[field.kind] {
+		supported = supported || op == c.Op
+	}
+	if !supported {
+		return "", fmt.Errorf("%s does not support %s", c.Field, c.Op)
+	}
+
+	col := field.column
+
+	if field.nullable && len(c.Values) == 1 && strings.EqualFold(c.Values[0], "null") {
+		switch c.Op {
+		case filter.OpEq:
+			return fmt.Sprintf("(%s IS NULL)", col), nil
+		case filter.OpNe:
+			return fmt.Sprintf("(%s IS NOT NULL)", col), nil
+		}
+	}
+
+	switch field.kind {
+	case fieldText:
+		switch c.Op {
+		case filter.OpContains:
+			return fmt.Sprintf("(%s ILIKE '%%' || %s || '%%')", col, args.add(likeEscaper.Replace(c.Values[0]))), nil
+		case filter.OpIn:
+			return fmt.Sprintf("(%s = ANY (%s::text[]))", col, args.add(pq.Array(c.Values))), nil
+		default:
+			return fmt.Sprintf("(%s %s %s)", col, sqlOp(c.Op), args.add(c.Values[0])), nil
+		}
+	case fieldInt:
+		ints := make([]int64, len(c.Values))
+		for i, s := range c.Values {
+			n, err := strconv.ParseInt(s, 10, 32)
+			if err != nil {
+				return "", fmt.Errorf("%s must be compared with an integer", c.Field)
+			}
+			ints[i] = n
+		}
+
+		if c.Op == filter.OpIn {
+			return fmt.Sprintf("(%s = ANY (%s::bigint[]))", col, args.add(pq.Array(ints))), nil
+		}
+		return fmt.Sprintf("(%s %s %s)", col, sqlOp(c.Op), args.add(ints[0])), nil
+	case fieldTime, fieldSchedule:
+		return compileTimeComparison(c, col, field.kind == fieldSchedule, args, now)
+	case fieldTag:
+		tags := make([]string, len(c.Values))
+		for i, s := range c.Values {
+			tags[i] = strings.ToLower(s)
+		}
+
+		switch c.Op {
+		case filter.OpIn:
+			return fmt.Sprintf("(%s && %s::text[])", col, args.add(pq.Array(tags))), nil
+		case filter.OpNe:
+			return fmt.Sprintf("(NOT %s = ANY (%s))", args.add(tags[0]), col), nil
+		default:
+			return fmt.Sprintf("(%s = ANY (%s))", args.add(tags[0]), col), nil
+		}
+	default:
+		b, err := strconv.ParseBool(c.Values[0])
+		if err != nil {
+			return "", fmt.Errorf("%s must be compared with true or false", c.Field)
+		}
+		return fmt.Sprintf("(%s %s %s::boolean)", col, sqlOp(c.Op), args.add(b)), nil
+	}
+}
+
+// compileTimeComparison compares a time column with a time, or with a whole
+// day given as a date or relative to today. Times are compared in UTC, and
+// days start at midnight in the time zone of now. If allDay is set, all-day
+// tasks are compared with the date instead.
+func compileTimeComparison(c *filter.Comparison, col string, allDay bool, args *queryArgs, now time.Time) (string, error) {
+	s := c.Values[0]
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return fmt.Sprintf("(%s %s %s)", col, sqlOp(c.Op), args.add(t.UTC())), nil
+	}
+
+	var day time.Time
+
+	if m := relativeDateRX.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		y, mo, d := now.Date()
+		day = time.Date(y, mo, d+n, 0, 0, 0, 0, now.Location())
+	} else if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		day = t
+	} else {
+		return "", fmt.Errorf("%s must be compared with a date, an RFC3339 time or today, optionally followed by +Nd or -Nd", c.Field)
+	}
+
+	cond := compileDayComparison(c.Op, col, day.UTC(), day.AddDate(0, 0, 1).UTC(), args)
+
+	if allDay {
+		date := dateOf(day)
+		dateCond := compileDayComparison(c.Op, col, date, date.AddDate(0, 0, 1), args)
+		cond = fmt.Sprintf("(CASE WHEN tasks.all_day THEN %s ELSE %s END)", dateCond, cond)
+	}
+
+	return cond, nil
+}
+
+// compileDayComparison compares col with the day from start to next.
+func compileDayComparison(op, col string, start, next time.Time, args *queryArgs) string {
+	switch op {
+	case filter.OpEq:
+		return fmt.Sprintf("(%[1]s >= %[2]s AND %[1]s < %[3]s)", col, args.add(start), args.add(next))
+	case filter.OpNe:
+		return fmt.Sprintf("(%[1]s < %[2]s OR %[1]s >= %[3]s)", col, args.add(start), args.add(next))
+	case filter.OpLt:
+		return fmt.Sprintf("(%s < %s)", col, args.add(start))
+	case filter.OpLe:
+		return fmt.Sprintf("(%s < %s)", col, args.add(next))
+	case filter.OpGt:
+		return fmt.Sprintf("(%s >= %s)", col, args.add(next))
+	default:
+		return fmt.Sprintf("(%s >= %s)", col, args.add(start))
+	}
+}
+
+func sqlOp(op string) string {
+	if op == filter.OpNe {
+		return "<>"
+	}
+
+	return op
+}
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+type TaskSnapshot struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Datetime    *time.Time `json:"datetime"`
+	EndDatetime *time.Time `json:"end_datetime"`
+	AllDay      bool       `json:"all_day"`
+	Status      string     `json:"status"`
+	FolderID    int        `json:"folder_id"`
+	Tags        []string   `json:"tags"`
+}
+
+type TaskRevision struct {
+	ID       int                    `json:"id"`
+	TaskID   int                    `json:"task_id"`
+	UserID   int                    `json:"user_id,omitempty"`
+	Changes  map[string]FieldChange `json:"changes"`
+	Snapshot TaskSnapshot           `json:"snapshot"`
+	Created  time.Time              `json:"created"`
+}
+
+func snapshotTask(t *Task) TaskSnapshot {
+	return TaskSnapshot{
+		Title:       t.Title,
+		Description: t.Description,
+		Datetime:    t.Datetime,
+		EndDatetime: t.EndDatetime,
+		AllDay:      t.AllDay,
+		Status:      t.Status,
+		FolderID:    t.FolderID,
+		Tags:        t.Tags,
+	}
+}
+
+func taskChanges(before, after *Task) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+
+	if before.Title != after.Title {
+		changes["title"] = FieldChange{before.Title, after.Title}
+	}
+	if before.Description != after.Description {
+		changes["description"] = FieldChange{before.Description, after.Description}
+	}
+	if !timesEqual(before.Datetime, after.Datetime) {
+		changes["datetime"] = FieldChange{before.Datetime, after.Datetime}
+	}
+	if !timesEqual(before.EndDatetime, after.EndDatetime) {
+		changes["end_datetime"] = FieldChange{before.EndDatetime, after.EndDatetime}
+	}
+	if before.AllDay != after.AllDay {
+		changes["all_day"] = FieldChange{before.AllDay, after.AllDay}
+	}
+	if before.Status != after.Status {
+		changes["status"] = FieldChange{before.Status, after.Status}
+	}
+	if before.FolderID != after.FolderID {
+		changes["folder_id"] = FieldChange{before.FolderID, after.FolderID}
+	}
+	if strings.Join(before.Tags, ",") != strings.Join(after.Tags, ",") {
+		changes["tags"] = FieldChange{before.Tags, after.Tags}
+	}
+
+	return changes
+}
+
+func timesEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+func insertTaskRevision(ctx context.Context, tx *sql.Tx, actorID int, before, after *Task) error {
+	changes := taskChanges(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	snapshotJSON, err := json.Marshal(snapshotTask(after))
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO task_revisions (task_id, user_id, changes, snapshot)
+	VALUES ($1, NULLIF($2, 0), $3, $4)`
+
+	_, err = tx.ExecContext(ctx, stmt, after.ID, actorID, changesJSON, snapshotJSON)
+	return err
+}
+
+func (m TaskModel) GetRevisions(taskID int, filters Filters) ([]*TaskRevision, MetaData, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), id, task_id, COALESCE(user_id, 0), changes, snapshot, created
+	FROM task_revisions
+	WHERE task_revisions.task_id = $1
+	ORDER BY %s %s, id DESC
+	LIMIT $2 OFFSET $3`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, taskID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*TaskRevision{}
+
+	for rows.Next() {
+		rev, err := scanTaskRevision(rows.Scan, &totalRecords)
+		if err != nil {
+			return nil, MetaData{}, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
+
+func (m TaskModel) GetRevision(taskID, revisionID int) (*TaskRevision, error) {
+	stmt := `SELECT id, task_id, COALESCE(user_id, 0), changes, snapshot, created
+	FROM task_revisions
+	WHERE task_revisions.task_id = $1
+	AND task_revisions.id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rev, err := scanTaskRevision(m.DB.QueryRowContext(ctx, stmt, taskID, revisionID).Scan, nil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+func scanTaskRevision(scan func(...interface{}) error, totalRecords *int) (*TaskRevision, error) {
+	rev := &TaskRevision{}
+	var changes, snapshot []byte
+
+	dest := []interface{}{&rev.ID, &rev.TaskID, &rev.UserID, &changes, &snapshot, &rev.Created}
+	if totalRecords != nil {
+		dest = append([]interface{}{totalRecords}, dest...)
+	}
+
+	err := scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(changes, &rev.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(snapshot, &rev.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
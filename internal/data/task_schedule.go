@@ -0,0 +1,186 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+// ScheduleError reports a datetime, end_datetime or duration that doesn't
+// fit with the rest of a task's schedule.
+type ScheduleError struct {
+	Field string
+	Msg   string
+}
+
+func (e *ScheduleError) Error() string {
+	return fmt.Sprintf("models: %s %s", e.Field, e.Msg)
+}
+
+// schedule is when a task takes place. Start and end are nil for tasks
+// without a date, and end is nil for tasks that take place at an instant or,
+// if they are all day, on a single day. The end is exclusive, so an all-day
+// task ends at midnight after its last day.
+//
+// All-day tasks fall on dates rather than at instants, and are stored at
+// midnight UTC of those dates.
+type schedule struct {
+	start  *time.Time
+	end    *time.Time
+	allDay bool
+}
+
+func taskSchedule(t *Task) schedule {
+	return schedule{start: t.Datetime, end: t.EndDatetime, allDay: t.AllDay}
+}
+
+// parseTaskTime parses an RFC3339 time or, for all-day tasks, a date. All-day
+// tasks take the date as written and drop the time.
+func parseTaskTime(s string, allDay bool) (time.Time, bool) {
+	if allDay {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			return t, true
+		}
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if allDay {
+		return dateOf(t), true
+	}
+
+	return t.UTC(), true
+}
+
+// dateOf returns midnight UTC of the date t falls on in its own location.
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func validTaskTime(s string, allDay bool) bool {
+	_, ok := parseTaskTime(s, allDay)
+	return ok
+}
+
+// apply returns the schedule after a change. An empty datetime or
+// end_datetime removes it, and a duration in minutes sets the end relative
+// to the start. Moving a task keeps its length unless a new end is given,
+// while turning all_day on or off drops the end.
+func (s schedule) apply(datetime, endDatetime *string, duration *int, allDay *bool) (schedule, error) {
+	next := s
+	if allDay != nil {
+		next.allDay = *allDay
+	}
+	toggled := next.allDay != s.allDay
+
+	switch {
+	case datetime != nil && *datetime == "":
+		next.start = nil
+	case datetime != nil:
+		t, ok := parseTaskTime(*datetime, next.allDay)
+		if !ok {
+			return s, &ScheduleError{"datetime", "must be an RFC3339 time, or a date for all-day tasks"}
+		}
+		next.start = &t
+	case toggled && s.start != nil && next.allDay:
+		t := dateOf(*s.start)
+		next.start = &t
+	}
+
+	endField := "end_datetime"
+
+	switch {
+	case endDatetime != nil && *endDatetime == "":
+		next.end = nil
+	case endDatetime != nil:
+		t, ok := parseTaskTime(*endDatetime, next.allDay)
+		if !ok {
+			return s, &ScheduleError{"end_datetime", "must be an RFC3339 time, or a date for all-day tasks"}
+		}
+		next.end = &t
+	case duration != nil:
+		endField = "duration"
+		if next.start == nil {
+			return s, &ScheduleError{"duration", "requires a datetime"}
+		}
+		if next.allDay && *duration%minutesPerDay != 0 {
+			return s, &ScheduleError{"duration", "must be a whole number of days for all-day tasks"}
+		}
+		t := next.start.Add(time.Duration(*duration) * time.Minute)
+		next.end = &t
+	case toggled || next.start == nil || s.end == nil:
+		next.end = nil
+	default:
+		t := next.start.Add(s.end.Sub(*s.start))
+		next.end = &t
+	}
+
+	if next.end != nil {
+		if next.start == nil {
+			return s, &ScheduleError{endField, "requires a datetime"}
+		}
+		if !next.end.After(*next.start) {
+			return s, &ScheduleError{endField, "must end after datetime"}
+		}
+	}
+
+	return next, nil
+}
+
+// interval returns when a timed task with an end takes place, which are the
+// only tasks that can conflict with each other.
+func (s schedule) interval() (start, end time.Time, ok bool) {
+	if s.allDay || s.start == nil || s.end == nil {
+		return start, end, false
+	}
+
+	return *s.start, *s.end, true
+}
+
+// Interval returns when the task created from d would take place, if it
+// would be a timed task with an end.
+func (d *CreateTaskDTO) Interval() (start, end time.Time, ok bool) {
+	s, err := d.schedule()
+	if err != nil {
+		return start, end, false
+	}
+
+	return s.interval()
+}
+
+// Interval returns when t would take place after the update, if it would be
+// a timed task with an end.
+func (d *UpdateTaskDTO) Interval(t *Task) (start, end time.Time, ok bool) {
+	s, err := taskSchedule(t).apply(d.Datetime, d.EndDatetime, d.Duration, d.AllDay)
+	if err != nil {
+		return start, end, false
+	}
+
+	return s.interval()
+}
+
+// overlapConds returns the conditions selecting tasks that take place, at
+// least in part, on or after from and before to, either of which may be
+// zero. Both are midnight in the user's time zone, and all-day tasks are
+// compared with the dates they fall on there.
+func overlapConds(from, to time.Time, args *queryArgs) []string {
+	var conds []string
+
+	if !from.IsZero() {
+		conds = append(conds, fmt.Sprintf(`CASE WHEN tasks.all_day
+		THEN COALESCE(tasks.end_datetime, tasks.datetime + interval '1 day') > %s
+		ELSE COALESCE(tasks.end_datetime > %s, tasks.datetime >= %[2]s) END`, args.add(dateOf(from)), args.add(from.UTC())))
+	}
+	if !to.IsZero() {
+		conds = append(conds, fmt.Sprintf(`CASE WHEN tasks.all_day
+		THEN tasks.datetime < %s
+		ELSE tasks.datetime < %s END`, args.add(dateOf(to)), args.add(to.UTC())))
+	}
+
+	return conds
+}
@@ -5,45 +5,123 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/pafirmin/go-todo/internal/filter"
 	"github.com/pafirmin/go-todo/internal/validator"
 )
 
 type TaskModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 type Task struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Datetime    time.Time `json:"datetime"`
-	Status      string    `json:"status"`
-	Created     time.Time `json:"created"`
-	Updated     time.Time `json:"updated"`
-	FolderID    int       `json:"folder_id"`
+	ID           int        `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Datetime     *time.Time `json:"datetime"`
+	EndDatetime  *time.Time `json:"end_datetime"`
+	AllDay       bool       `json:"all_day"`
+	Status       string     `json:"status"`
+	Created      time.Time  `json:"created"`
+	Updated      time.Time  `json:"updated"`
+	FolderID     int        `json:"folder_id"`
+	Position     string     `json:"position"`
+	Tags         []string   `json:"tags"`
+	Version      int        `json:"version"`
+	CommentCount int        `json:"comment_count"`
+	Blocked      bool       `json:"blocked"`
 }
 
+const (
+	TaskStatusCompleted = "completed"
+	TaskStatusCancelled = "cancelled"
+)
+
+// Finished reports whether t has been completed or cancelled.
+func (t *Task) Finished() bool {
+	return t.Status == TaskStatusCompleted || t.Status == TaskStatusCancelled
+}
+
+// unfinishedCond selects tasks that are neither completed nor cancelled.
+const unfinishedCond = `tasks.status NOT IN ('completed', 'cancelled')`
+
+type TaskFilters struct {
+	FolderIDs []string
+	Status    string
+	MinDate   time.Time
+	MaxDate   time.Time
+	Blocked   *bool
+	Tag       string
+	Recursive bool
+	Archived  string
+	Filter    filter.Expr
+	// Location is the time zone dates are in, UTC if nil.
+	Location *time.Location
+}
+
+const (
+	maxTags      = 20
+	maxTagLength = 30
+)
+
+const taskBlocked = `EXISTS (SELECT 1 FROM task_dependencies
+		INNER JOIN tasks blocker ON blocker.id = task_dependencies.depends_on_id
+		WHERE task_dependencies.task_id = tasks.id
+		AND blocker.status NOT IN ('completed', 'cancelled')
+		AND blocker.deleted_at IS NULL)`
+
+// CreateTaskDTO gives a task's end either as EndDatetime or as a Duration
+// in minutes.
 type CreateTaskDTO struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Datetime    string `json:"datetime"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Datetime    string   `json:"datetime"`
+	EndDatetime string   `json:"end_datetime"`
+	Duration    *int     `json:"duration"`
+	AllDay      bool     `json:"all_day"`
+	Tags        []string `json:"tags"`
 }
 
 func (d *CreateTaskDTO) Validate(v *validator.Validator) {
 	v.ValidLength("title", d.Title, 1, 50)
 	v.ValidLength("description", d.Description, 0, 500)
-	v.ValidDatetime("datetime", d.Datetime)
+	validateDuration(v, d.EndDatetime != "", d.Duration)
+	if _, err := d.schedule(); err != nil {
+		addScheduleError(v, err)
+	}
+	validateTags(v, d.Tags)
 }
 
+func (d *CreateTaskDTO) schedule() (schedule, error) {
+	var datetime, endDatetime *string
+	if d.Datetime != "" {
+		datetime = &d.Datetime
+	}
+	if d.EndDatetime != "" {
+		endDatetime = &d.EndDatetime
+	}
+
+	return schedule{}.apply(datetime, endDatetime, d.Duration, &d.AllDay)
+}
+
+// UpdateTaskDTO removes a task's date when Datetime is empty, and its end
+// when EndDatetime is.
 type UpdateTaskDTO struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Datetime    *string `json:"datetime,omitempty"`
-	Status      *string `json:"status,omitempty"`
-	FolderID    *int    `json:"folder_id,omitempty"`
+	Title       *string   `json:"title,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Datetime    *string   `json:"datetime,omitempty"`
+	EndDatetime *string   `json:"end_datetime,omitempty"`
+	Duration    *int      `json:"duration,omitempty"`
+	AllDay      *bool     `json:"all_day,omitempty"`
+	Status      *string   `json:"status,omitempty"`
+	FolderID    *int      `json:"folder_id,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+
+	// Version, if set, must match the task's current version.
+	Version int `json:"-"`
 }
 
 func (d *UpdateTaskDTO) Validate(v *validator.Validator) {
@@ -53,37 +131,110 @@ func (d *UpdateTaskDTO) Validate(v *validator.Validator) {
 	if d.Description != nil {
 		v.ValidLength("description", *d.Description, 0, 500)
 	}
-	if d.Datetime != nil {
-		v.ValidDatetime("datetime", *d.Datetime)
+	if d.Datetime != nil && *d.Datetime != "" {
+		v.Check(validTaskTime(*d.Datetime, true), "datetime", "must be an RFC3339 time, or a date for all-day tasks")
+	}
+	if d.EndDatetime != nil && *d.EndDatetime != "" {
+		v.Check(validTaskTime(*d.EndDatetime, true), "end_datetime", "must be an RFC3339 time, or a date for all-day tasks")
 	}
+	validateDuration(v, d.EndDatetime != nil, d.Duration)
 	if d.Status != nil {
-		v.PermittedValue("status", *d.Status, "default", "important", "cancelled")
+		v.PermittedValue("status", *d.Status, "default", "important", TaskStatusCancelled, TaskStatusCompleted)
+	}
+	if d.Tags != nil {
+		validateTags(v, *d.Tags)
 	}
 }
 
-func (m TaskModel) Insert(folderID int, dto *CreateTaskDTO) (*Task, error) {
-	stmt := `INSERT INTO tasks (title, description, status, datetime, created, updated, folder_id)
-	VALUES ($1, $2, DEFAULT, $3, DEFAULT, DEFAULT, $4)
-	RETURNING *`
+func validateDuration(v *validator.Validator, hasEnd bool, duration *int) {
+	if duration == nil {
+		return
+	}
+
+	v.Check(!hasEnd, "duration", "must not be given with end_datetime")
+	v.Check(*duration > 0, "duration", "must be a positive number of minutes")
+}
 
+func addScheduleError(v *validator.Validator, err error) {
+	var se *ScheduleError
+	if errors.As(err, &se) {
+		v.AddError(se.Field, se.Msg)
+	}
+}
+
+func validateTags(v *validator.Validator, tags []string) {
+	v.Check(len(tags) <= maxTags, "tags", fmt.Sprintf("must not contain more than %d tags", maxTags))
+
+	for _, tag := range tags {
+		ValidateTag(v, "tags", tag)
+	}
+}
+
+func ValidateTag(v *validator.Validator, key, tag string) {
+	v.ValidLength(key, strings.TrimSpace(tag), 1, maxTagLength)
+}
+
+// normalizeTags lower-cases and trims tags and drops duplicates, keeping the
+// order in which they were first given.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+type MoveTaskDTO struct {
+	AfterID  *int `json:"after_id"`
+	BeforeID *int `json:"before_id"`
+	FolderID *int `json:"folder_id"`
+}
+
+func (d *MoveTaskDTO) Validate(v *validator.Validator) {
+	if d.AfterID != nil {
+		v.Check(*d.AfterID > 0, "after_id", "must be a positive integer")
+	}
+	if d.BeforeID != nil {
+		v.Check(*d.BeforeID > 0, "before_id", "must be a positive integer")
+	}
+	if d.FolderID != nil {
+		v.Check(*d.FolderID > 0, "folder_id", "must be a positive integer")
+	}
+}
+
+func (m TaskModel) Insert(folderID int, dto *CreateTaskDTO) (*Task, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	t := &Task{}
-	args := []interface{}{dto.Title, dto.Description, dto.Datetime, folderID}
 
-	row := m.DB.QueryRowContext(ctx, stmt, args...)
-	err := row.Scan(
-		&t.ID,
-		&t.Title,
-		&t.Description,
-		&t.Status,
-		&t.Datetime,
-		&t.Created,
-		&t.Updated,
-		&t.FolderID,
-	)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		s, err := dto.schedule()
+		if err != nil {
+			return err
+		}
+
+		pos, err := taskList(folderID).next(ctx, tx)
+		if err != nil {
+			return err
+		}
 
+		stmt := `INSERT INTO tasks (title, description, status, datetime, end_datetime, all_day, created, updated, folder_id, position, tags)
+		VALUES ($1, $2, DEFAULT, $3, $4, $5, DEFAULT, DEFAULT, $6, $7, $8)
+		RETURNING ` + taskReturning
+
+		args := []interface{}{dto.Title, dto.Description, s.start, s.end, s.allDay, folderID, pos, pq.Array(normalizeTags(dto.Tags))}
+
+		return scanReturnedTask(tx.QueryRowContext(ctx, stmt, args...), t)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -92,9 +243,13 @@ func (m TaskModel) Insert(folderID int, dto *CreateTaskDTO) (*Task, error) {
 }
 
 func (m TaskModel) GetByID(id int) (*Task, error) {
-	stmt := `SELECT id, title, description, datetime, status, created, updated, folder_id
-	FROM tasks 
-	WHERE tasks.id = $1`
+	stmt := `SELECT tasks.id, tasks.title, tasks.description, tasks.datetime, tasks.end_datetime, tasks.all_day, tasks.status, tasks.created, tasks.updated, tasks.folder_id, tasks.position, tasks.tags, tasks.version,
+	(SELECT count(*) FROM comments WHERE comments.task_id = tasks.id), ` + taskBlocked + `
+	FROM tasks
+	INNER JOIN folders ON folders.id = tasks.folder_id
+	WHERE tasks.id = $1
+	AND tasks.deleted_at IS NULL
+	AND folders.deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -102,7 +257,7 @@ func (m TaskModel) GetByID(id int) (*Task, error) {
 	t := &Task{}
 
 	row := m.DB.QueryRowContext(ctx, stmt, id)
-	err := row.Scan(&t.ID, &t.Title, &t.Description, &t.Datetime, &t.Status, &t.Created, &t.Updated, &t.FolderID)
+	err := row.Scan(&t.ID, &t.Title, &t.Description, &t.Datetime, &t.EndDatetime, &t.AllDay, &t.Status, &t.Created, &t.Updated, &t.FolderID, &t.Position, pq.Array(&t.Tags), &t.Version, &t.CommentCount, &t.Blocked)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -115,41 +270,50 @@ func (m TaskModel) GetByID(id int) (*Task, error) {
 	return t, nil
 }
 
-func (m TaskModel) GetByUser(
-	userID int,
-	folderIDs []string,
-	status string,
-	minDate,
-	maxDate time.Time,
-	filters Filters,
-) ([]*Task, MetaData, error) {
-	var minDateStmt string
-	var maxDateStmt string
-
-	if !minDate.IsZero() {
-		minDateStmt = fmt.Sprintf("AND DATE_TRUNC('day', tasks.datetime) >= '%s'", minDate.Format("2006-01-02"))
+// taskSortColumn is the expression tasks are sorted by. Tasks without a date
+// sort as if they were due at the end of time, which is where Postgres would
+// put them anyway, so that they can be paged through with a cursor.
+func taskSortColumn(filters Filters) string {
+	col := "tasks." + filters.SortColumn()
+	if filters.SortColumn() == "datetime" {
+		return "COALESCE(" + col + ", 'infinity')"
 	}
-	if !maxDate.IsZero() {
-		maxDateStmt = fmt.Sprintf("AND DATE_TRUNC('day', tasks.datetime) <= '%s'", maxDate.Format("2006-01-02"))
+
+	return col
+}
+
+func (m TaskModel) GetByUser(userID int, tf TaskFilters, filters Filters) ([]*Task, MetaData, error) {
+	args := queryArgs{userID, pq.Array(tf.FolderIDs), tf.Status, tf.Blocked, filters.Limit() + 1, filters.Offset(), tf.Archived, tf.Tag}
+
+	where, err := tf.where(&args)
+	if err != nil {
+		return nil, MetaData{}, err
 	}
 
-	stmt := fmt.Sprintf(`SELECT count(*) OVER(),
-	tasks.id, tasks.title, tasks.description, tasks.status, tasks.datetime, tasks.created, tasks.updated, tasks.folder_id
+	sortCol := taskSortColumn(filters)
+	keyset, order := filters.keyset(sortCol, "tasks.id", &args)
+
+	stmt := fmt.Sprintf(`SELECT %[6]s,
+	tasks.id, tasks.title, tasks.description, tasks.status, tasks.datetime, tasks.end_datetime, tasks.all_day, tasks.created, tasks.updated, tasks.folder_id, tasks.position, tasks.tags, tasks.version,
+	(SELECT count(*) FROM comments WHERE comments.task_id = tasks.id), %[1]s, %[4]s::text
 	FROM tasks
-	INNER JOIN folders ON folders.id = tasks.folder_id 
+	INNER JOIN folders ON folders.id = tasks.folder_id
 	WHERE folders.user_id = $1
+	AND tasks.deleted_at IS NULL
+	AND folders.deleted_at IS NULL
 	AND (tasks.folder_id = ANY ($2::int[]) OR $2 = '{}')
 	AND (tasks.status LIKE $3 OR $3 = '')
-	%s
-	%s
-	ORDER BY tasks.%s %s, tasks.id ASC
-	LIMIT $4 OFFSET $5`, minDateStmt, maxDateStmt, filters.SortColumn(), filters.SortDirection())
+	AND ($4::boolean IS NULL OR %[1]s = $4)
+	AND ($7 = 'include' OR (folders.archived_at IS NOT NULL) = ($7 = 'only'))
+	AND ($8 = '' OR $8 = ANY (tasks.tags))
+	AND %[3]s
+	%[2]s
+	ORDER BY %[5]s
+	LIMIT $5 OFFSET $6`, taskBlocked, where, keyset, sortCol, order, filters.countColumn())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	args := []interface{}{userID, pq.Array(folderIDs), status, filters.Limit(), filters.Offset()}
-
 	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, MetaData{}, err
@@ -159,9 +323,11 @@ func (m TaskModel) GetByUser(
 
 	totalRecords := 0
 	tasks := []*Task{}
+	keys := []pageKey{}
 
 	for rows.Next() {
 		t := &Task{}
+		k := pageKey{}
 		err = rows.Scan(
 			&totalRecords,
 			&t.ID,
@@ -169,52 +335,72 @@ func (m TaskModel) GetByUser(
 			&t.Description,
 			&t.Status,
 			&t.Datetime,
+			&t.EndDatetime,
+			&t.AllDay,
 			&t.Created,
 			&t.Updated,
 			&t.FolderID,
+			&t.Position,
+			pq.Array(&t.Tags),
+			&t.Version,
+			&t.CommentCount,
+			&t.Blocked,
+			&k.Value,
 		)
 
 		if err != nil {
 			return nil, MetaData{}, err
 		}
+		k.ID = t.ID
 		tasks = append(tasks, t)
+		keys = append(keys, k)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, err
 	}
 
-	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	tasks, metadata := finishPage(filters, tasks, keys, totalRecords)
 
 	return tasks, metadata, nil
 }
 
-func (m TaskModel) GetByFolder(
-	folderID int,
-	status string,
-	minDate,
-	maxDate time.Time,
-	filters Filters,
-) ([]*Task, MetaData, error) {
-	var minDateStmt string
-	var maxDateStmt string
+func (m TaskModel) GetByFolder(folderID int, tf TaskFilters, filters Filters) ([]*Task, MetaData, error) {
+	args := queryArgs{folderID, tf.Status, tf.Blocked, filters.Limit() + 1, filters.Offset(), tf.Recursive}
 
-	if !minDate.IsZero() {
-		minDateStmt = fmt.Sprintf("AND DATE_TRUNC('day', tasks.datetime) >= '%s'", minDate.Format("2006-01-02"))
-	}
-	if !maxDate.IsZero() {
-		maxDateStmt = fmt.Sprintf("AND DATE_TRUNC('day', tasks.datetime) <= '%s'", maxDate.Format("2006-01-02"))
+	where, err := tf.where(&args)
+	if err != nil {
+		return nil, MetaData{}, err
 	}
 
-	stmt := fmt.Sprintf(`SELECT count(*) OVER(), * FROM tasks
-		WHERE tasks.folder_id = $1
+	sortCol := taskSortColumn(filters)
+	keyset, order := filters.keyset(sortCol, "tasks.id", &args)
+
+	stmt := fmt.Sprintf(`WITH RECURSIVE subtree AS (
+			SELECT id FROM folders WHERE folders.id = $1
+			UNION ALL
+			SELECT folders.id
+			FROM folders
+			INNER JOIN subtree ON folders.parent_id = subtree.id
+			WHERE $6
+			AND folders.deleted_at IS NULL
+		)
+		SELECT %[6]s,
+		id, title, description, status, datetime, end_datetime, all_day, created, updated, folder_id, position, tags, version,
+		(SELECT count(*) FROM comments WHERE comments.task_id = tasks.id), %[1]s, %[4]s::text
+		FROM tasks
+		WHERE tasks.folder_id IN (SELECT id FROM subtree)
+		AND tasks.deleted_at IS NULL
 		AND (tasks.status LIKE $2 OR $2 = '')
-		%s
-		%s
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, minDateStmt, maxDateStmt, filters.SortColumn(), filters.SortDirection())
+		AND ($3::boolean IS NULL OR %[1]s = $3)
+		AND %[3]s
+		%[2]s
+		ORDER BY %[5]s
+		LIMIT $4 OFFSET $5`, taskBlocked, where, keyset, sortCol, order, filters.countColumn())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	args := []interface{}{folderID, status, filters.Limit(), filters.Offset()}
-
 	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, MetaData{}, err
@@ -224,9 +410,11 @@ func (m TaskModel) GetByFolder(
 
 	totalRecords := 0
 	tasks := []*Task{}
+	keys := []pageKey{}
 
 	for rows.Next() {
 		t := &Task{}
+		k := pageKey{}
 		err := rows.Scan(
 			&totalRecords,
 			&t.ID,
@@ -234,51 +422,212 @@ func (m TaskModel) GetByFolder(
 			&t.Description,
 			&t.Status,
 			&t.Datetime,
+			&t.EndDatetime,
+			&t.AllDay,
 			&t.Created,
 			&t.Updated,
 			&t.FolderID,
+			&t.Position,
+			pq.Array(&t.Tags),
+			&t.Version,
+			&t.CommentCount,
+			&t.Blocked,
+			&k.Value,
 		)
 		if err != nil {
 			return nil, MetaData{}, err
 		}
+		k.ID = t.ID
 		tasks = append(tasks, t)
+		keys = append(keys, k)
 	}
 
-	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, err
+	}
+
+	tasks, metadata := finishPage(filters, tasks, keys, totalRecords)
 
 	return tasks, metadata, nil
 }
 
-func (m TaskModel) Update(id int, dto *UpdateTaskDTO) (*Task, error) {
+func (m TaskModel) Update(id, actorID int, dto *UpdateTaskDTO) (*Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var t *Task
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		before, err := lockTask(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if dto.Version != 0 && dto.Version != before.Version {
+			return ErrEditConflict
+		}
+
+		t, err = updateTask(ctx, tx, actorID, before, dto)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// updateTask applies dto to a task previously locked with lockTask and
+// records the revision.
+func updateTask(ctx context.Context, tx *sql.Tx, actorID int, before *Task, dto *UpdateTaskDTO) (*Task, error) {
+	var pos *string
+	if dto.FolderID != nil && *dto.FolderID != before.FolderID {
+		next, err := taskList(*dto.FolderID).next(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		pos = &next
+	}
+
+	var tags interface{}
+	if dto.Tags != nil {
+		tags = pq.Array(normalizeTags(*dto.Tags))
+	}
+
+	s, err := taskSchedule(before).apply(dto.Datetime, dto.EndDatetime, dto.Duration, dto.AllDay)
+	if err != nil {
+		return nil, err
+	}
+
 	stmt := `UPDATE tasks
 	SET title = COALESCE($1, title),
 		description = COALESCE($2, description),
 		status = COALESCE($3, status),
-		datetime = COALESCE($4, datetime),
-		folder_id = COALESCE($5, folder_id),
-		updated = now()
-	WHERE tasks.id = $6
-	RETURNING *
-	`
+		datetime = $4,
+		end_datetime = $5,
+		all_day = $6,
+		folder_id = COALESCE($7, folder_id),
+		position = COALESCE($8, position),
+		tags = COALESCE($9, tags),
+		updated = now(),
+		version = version + 1
+	WHERE tasks.id = $10
+	RETURNING ` + taskReturning
+
+	args := []interface{}{dto.Title, dto.Description, dto.Status, s.start, s.end, s.allDay, dto.FolderID, pos, tags, before.ID}
+
+	t := &Task{}
 
+	err = scanReturnedTask(tx.QueryRowContext(ctx, stmt, args...), t)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertTaskRevision(ctx, tx, actorID, before, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (m TaskModel) Move(id, actorID int, dto *MoveTaskDTO) (*Task, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	t := &Task{}
-	args := []interface{}{dto.Title, dto.Description, dto.Status, dto.Datetime, dto.FolderID, id}
 
-	row := m.DB.QueryRowContext(ctx, stmt, args...)
-	err := row.Scan(
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		before, err := lockTask(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		folderID := before.FolderID
+		if dto.FolderID != nil {
+			folderID = *dto.FolderID
+		}
+
+		pos, err := taskList(folderID).move(ctx, tx, id, dto.AfterID, dto.BeforeID)
+		if err != nil {
+			return err
+		}
+
+		stmt := `UPDATE tasks
+		SET folder_id = $1, position = $2, updated = now(), version = version + 1
+		WHERE tasks.id = $3
+		RETURNING ` + taskReturning
+
+		err = scanReturnedTask(tx.QueryRowContext(ctx, stmt, folderID, pos, id), t)
+		if err != nil {
+			return err
+		}
+
+		return insertTaskRevision(ctx, tx, actorID, before, t)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+const taskReturning = `id, title, description, status, datetime, end_datetime, all_day, created, updated, folder_id, position, tags, version,
+	(SELECT count(*) FROM comments WHERE comments.task_id = tasks.id), ` + taskBlocked
+
+// scanReturnedTask scans the columns listed in taskReturning from a
+// *sql.Row or *sql.Rows.
+func scanReturnedTask(row interface{ Scan(...interface{}) error }, t *Task) error {
+	return row.Scan(
 		&t.ID,
 		&t.Title,
 		&t.Description,
 		&t.Status,
 		&t.Datetime,
+		&t.EndDatetime,
+		&t.AllDay,
 		&t.Created,
 		&t.Updated,
 		&t.FolderID,
+		&t.Position,
+		pq.Array(&t.Tags),
+		&t.Version,
+		&t.CommentCount,
+		&t.Blocked,
 	)
+}
+
+func lockTask(ctx context.Context, tx *sql.Tx, id int) (*Task, error) {
+	stmt := `SELECT id, title, description, datetime, end_datetime, all_day, status, created, updated, folder_id, position, tags, version
+	FROM tasks
+	WHERE tasks.id = $1
+	AND tasks.deleted_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM folders WHERE folders.id = tasks.folder_id AND folders.deleted_at IS NOT NULL)
+	FOR UPDATE`
+
+	t := &Task{}
 
+	err := tx.QueryRowContext(ctx, stmt, id).Scan(
+		&t.ID,
+		&t.Title,
+		&t.Description,
+		&t.Datetime,
+		&t.EndDatetime,
+		&t.AllDay,
+		&t.Status,
+		&t.Created,
+		&t.Updated,
+		&t.FolderID,
+		&t.Position,
+		pq.Array(&t.Tags),
+		&t.Version,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -286,17 +635,30 @@ func (m TaskModel) Update(id int, dto *UpdateTaskDTO) (*Task, error) {
 	return t, nil
 }
 
-func (m TaskModel) Delete(id int) (int, error) {
-	stmt := `DELETE FROM tasks WHERE tasks.id = $1`
+// Delete moves a task to the trash. If version is not 0, it must match the
+// task's current version.
+func (m TaskModel) Delete(id, version int) (int, error) {
+	stmt := `UPDATE tasks SET deleted_at = now()
+	WHERE tasks.id = $1
+	AND tasks.deleted_at IS NULL
+	AND ($2 = 0 OR tasks.version = $2)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, stmt, id)
+	res, err := m.DB.ExecContext(ctx, stmt, id, version)
+	if err != nil {
+		return 0, err
+	}
 
+	n, err := res.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
 
+	if n == 0 && version != 0 {
+		return 0, ErrEditConflict
+	}
+
 	return id, nil
 }
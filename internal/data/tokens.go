@@ -4,17 +4,17 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base32"
 	"time"
 )
 
 const (
 	ScopeRefresh = "refresh"
+	ScopeUnlock  = "unlock"
 )
 
 type TokenModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 type Token struct {
@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrParentTrashed = errors.New("models: parent is in the trash")
+
+const (
+	TrashFolder = "folder"
+	TrashTask   = "task"
+)
+
+type TrashModel struct {
+	DB DBTX
+}
+
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	FolderID  int       `json:"folder_id,omitempty"`
+	UserID    int       `json:"-"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (m TrashModel) GetByUser(userID int, itemType string, filters Filters) ([]*TrashItem, MetaData, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(), type, id, name, folder_id, user_id, deleted_at
+	FROM (
+		SELECT 'folder' AS type, id, name, 0 AS folder_id, user_id, deleted_at
+		FROM folders
+		WHERE folders.user_id = $1
+		AND folders.deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'task', tasks.id, tasks.title, tasks.folder_id, folders.user_id, tasks.deleted_at
+		FROM tasks
+		INNER JOIN folders ON folders.id = tasks.folder_id
+		WHERE folders.user_id = $1
+		AND tasks.deleted_at IS NOT NULL
+	) AS trash
+	WHERE (type = $2 OR $2 = '')
+	ORDER BY %s %s, type ASC, id ASC
+	LIMIT $3 OFFSET $4`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, itemType, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*TrashItem{}
+
+	for rows.Next() {
+		i := &TrashItem{}
+		err := rows.Scan(&totalRecords, &i.Type, &i.ID, &i.Name, &i.FolderID, &i.UserID, &i.DeletedAt)
+		if err != nil {
+			return nil, MetaData{}, err
+		}
+		items = append(items, i)
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+func (m TrashModel) Get(itemType string, id int) (*TrashItem, error) {
+	var stmt string
+
+	switch itemType {
+	case TrashFolder:
+		stmt = `SELECT 'folder', id, name, 0, user_id, deleted_at
+		FROM folders
+		WHERE folders.id = $1
+		AND folders.deleted_at IS NOT NULL`
+	case TrashTask:
+		stmt = `SELECT 'task', tasks.id, tasks.title, tasks.folder_id, folders.user_id, tasks.deleted_at
+		FROM tasks
+		INNER JOIN folders ON folders.id = tasks.folder_id
+		WHERE tasks.id = $1
+		AND tasks.deleted_at IS NOT NULL`
+	default:
+		return nil, ErrNoRecord
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	i := &TrashItem{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&i.Type, &i.ID, &i.Name, &i.FolderID, &i.UserID, &i.DeletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (m TrashModel) Restore(itemType string, id int) error {
+	var stmt string
+
+	switch itemType {
+	case TrashFolder:
+		// Subfolders trashed along with the folder are restored with it.
+		stmt = `WITH RECURSIVE subtree AS (
+			SELECT id, deleted_at FROM folders
+			WHERE folders.id = $1
+			AND folders.deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM folders AS parent WHERE parent.id = folders.parent_id AND parent.deleted_at IS NOT NULL)
+			UNION ALL
+			SELECT folders.id, subtree.deleted_at
+			FROM folders
+			INNER JOIN subtree ON folders.parent_id = subtree.id
+			WHERE folders.deleted_at = subtree.deleted_at
+		)
+		UPDATE folders SET deleted_at = NULL, updated = now(), version = version + 1
+		WHERE folders.id IN (SELECT id FROM subtree)`
+	case TrashTask:
+		stmt = `UPDATE tasks SET deleted_at = NULL, updated = now(), version = version + 1
+		FROM folders
+		WHERE folders.id = tasks.folder_id
+		AND tasks.id = $1
+		AND tasks.deleted_at IS NOT NULL
+		AND folders.deleted_at IS NULL`
+	default:
+		return ErrNoRecord
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrParentTrashed
+	}
+
+	return nil
+}
+
+func (m TrashModel) Purge(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var total int64
+
+	// Purged rows take their tombstones with them, so the sync horizon is
+	// moved past the newest of them.
+	purge := `WITH purged AS (
+		DELETE FROM %s WHERE deleted_at < $1 RETURNING change_xid
+	), horizon AS (
+		UPDATE sync_horizon SET change_xid = latest.change_xid
+		FROM (SELECT change_xid FROM purged ORDER BY change_xid DESC LIMIT 1) AS latest
+		WHERE sync_horizon.change_xid < latest.change_xid
+	)
+	SELECT count(*) FROM purged`
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		for _, table := range []string{"tasks", "folders"} {
+			var n int64
+
+			err := tx.QueryRowContext(ctx, fmt.Sprintf(purge, table), before).Scan(&n)
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+type UsageModel struct {
+	DB DBTX
+}
+
+type Usage struct {
+	Users          int `json:"users,omitempty"`
+	Guests         int `json:"guests,omitempty"`
+	Folders        int `json:"folders"`
+	Tasks          int `json:"tasks"`
+	ActiveSessions int `json:"active_sessions"`
+}
+
+func (m UsageModel) Global() (*Usage, error) {
+	stmt := `SELECT
+		(SELECT count(*) FROM users WHERE NOT is_guest),
+		(SELECT count(*) FROM users WHERE is_guest),
+		(SELECT count(*) FROM folders WHERE deleted_at IS NULL),
+		(SELECT count(*) FROM tasks INNER JOIN folders ON folders.id = tasks.folder_id
+			WHERE tasks.deleted_at IS NULL AND folders.deleted_at IS NULL),
+		(SELECT count(*) FROM tokens WHERE scope = $1 AND expiry > now())`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	u := &Usage{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, ScopeRefresh).Scan(&u.Users, &u.Guests, &u.Folders, &u.Tasks, &u.ActiveSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (m UsageModel) ForUser(userID int) (*Usage, error) {
+	stmt := `SELECT
+		(SELECT count(*) FROM folders WHERE user_id = $1 AND deleted_at IS NULL),
+		(SELECT count(*) FROM tasks INNER JOIN folders ON folders.id = tasks.folder_id
+			WHERE folders.user_id = $1 AND tasks.deleted_at IS NULL AND folders.deleted_at IS NULL),
+		(SELECT count(*) FROM tokens WHERE user_id = $1 AND scope = $2 AND expiry > now())`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	u := &Usage{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, ScopeRefresh).Scan(&u.Folders, &u.Tasks, &u.ActiveSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/pafirmin/go-todo/internal/validator"
+)
+
+const (
+	DefaultTZ        = "UTC"
+	DefaultLocale    = "en"
+	DefaultWeekStart = "monday"
+)
+
+var weekStarts = []string{"monday", "sunday", "saturday"}
+
+// localeRX matches BCP 47 language tags such as en, en-GB or zh-Hant-TW.
+var localeRX = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+type UserSettingsModel struct {
+	DB DBTX
+}
+
+// UserSettings are a user's preferences. Users who have never changed them
+// get the defaults.
+type UserSettings struct {
+	TZ              string `json:"tz"`
+	Locale          string `json:"locale"`
+	WeekStart       string `json:"week_start"`
+	DefaultFolderID *int   `json:"default_folder_id"`
+}
+
+// Location returns the user's time zone, or UTC if it can no longer be
+// loaded.
+func (s *UserSettings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.TZ)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// UpdateUserSettingsDTO changes the settings that are set. A DefaultFolderID
+// of 0 clears the default folder.
+type UpdateUserSettingsDTO struct {
+	TZ              *string `json:"tz"`
+	Locale          *string `json:"locale"`
+	WeekStart       *string `json:"week_start"`
+	DefaultFolderID *int    `json:"default_folder_id"`
+}
+
+func (d *UpdateUserSettingsDTO) Validate(v *validator.Validator) {
+	if d.TZ != nil {
+		_, err := time.LoadLocation(*d.TZ)
+		v.Check(err == nil && *d.TZ != "" && *d.TZ != "Local", "tz", "must be an IANA time zone, such as Europe/London")
+	}
+	if d.Locale != nil {
+		v.Check(len(*d.Locale) <= 35 && localeRX.MatchString(*d.Locale), "locale", "must be a language tag, such as en-GB")
+	}
+	if d.WeekStart != nil {
+		v.PermittedValue("week_start", *d.WeekStart, weekStarts...)
+	}
+	if d.DefaultFolderID != nil {
+		v.Check(*d.DefaultFolderID >= 0, "default_folder_id", "must not be negative")
+	}
+}
+
+func (m UserSettingsModel) Get(userID int) (*UserSettings, error) {
+	stmt := `SELECT COALESCE(s.tz, $2), COALESCE(s.locale, $3), COALESCE(s.week_start, $4), s.default_folder_id
+	FROM users u
+	LEFT JOIN user_settings s ON s.user_id = u.id
+	WHERE u.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s := &UserSettings{}
+	err := m.DB.QueryRowContext(ctx, stmt, userID, DefaultTZ, DefaultLocale, DefaultWeekStart).
+		Scan(&s.TZ, &s.Locale, &s.WeekStart, &s.DefaultFolderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (m UserSettingsModel) Update(userID int, dto *UpdateUserSettingsDTO) (*UserSettings, error) {
+	stmt := `INSERT INTO user_settings (user_id, tz, locale, week_start, default_folder_id)
+	VALUES ($1, COALESCE($2::text, $6), COALESCE($3::text, $7), COALESCE($4::text, $8), NULLIF($5::bigint, 0))
+	ON CONFLICT (user_id) DO UPDATE
+	SET tz = COALESCE($2::text, user_settings.tz),
+		locale = COALESCE($3::text, user_settings.locale),
+		week_start = COALESCE($4::text, user_settings.week_start),
+		default_folder_id = CASE WHEN $5::bigint IS NULL THEN user_settings.default_folder_id ELSE NULLIF($5::bigint, 0) END,
+		updated = now()
+	RETURNING tz, locale, week_start, default_folder_id`
+
+	args := []interface{}{userID, dto.TZ, dto.Locale, dto.WeekStart, dto.DefaultFolderID, DefaultTZ, DefaultLocale, DefaultWeekStart}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s := &UserSettings{}
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&s.TZ, &s.Locale, &s.WeekStart, &s.DefaultFolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
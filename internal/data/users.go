@@ -2,27 +2,39 @@ package data
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/pafirmin/go-todo/internal/validator"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type UserModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 type User struct {
-	ID             int       `json:"id"`
-	FirstName      string    `json:"first_name"`
-	LastName       string    `json:"last_name"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"-"`
-	Created        time.Time `json:"created"`
-	Updated        time.Time `json:"updated"`
+	ID             int        `json:"id"`
+	FirstName      string     `json:"first_name"`
+	LastName       string     `json:"last_name"`
+	Email          string     `json:"email"`
+	HashedPassword string     `json:"-"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	IsGuest        bool       `json:"is_guest"`
+	Role           string     `json:"role"`
+	Disabled       bool       `json:"disabled"`
+	Created        time.Time  `json:"created"`
+	Updated        time.Time  `json:"updated"`
 }
 
 type CreateUserDTO struct {
@@ -46,7 +58,7 @@ type Credentials struct {
 }
 
 func (m UserModel) Get(id int) (*User, error) {
-	stmt := `SELECT id, email, first_name, last_name, hashed_password, created, updated FROM users WHERE users.id = $1`
+	stmt := `SELECT id, email, first_name, last_name, hashed_password, locked_until, is_guest, role, disabled, created, updated FROM users WHERE users.id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -55,7 +67,7 @@ func (m UserModel) Get(id int) (*User, error) {
 
 	rows := m.DB.QueryRowContext(ctx, stmt, id)
 
-	err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.Created, &u.Updated)
+	err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -67,7 +79,7 @@ func (m UserModel) Get(id int) (*User, error) {
 }
 
 func (m UserModel) GetByEmail(email string) (*User, error) {
-	stmt := `SELECT id, email, first_name, last_name, hashed_password, created, updated FROM users WHERE users.email = $1`
+	stmt := `SELECT id, email, first_name, last_name, hashed_password, locked_until, is_guest, role, disabled, created, updated FROM users WHERE lower(users.email) = lower($1)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -76,7 +88,7 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 
 	rows := m.DB.QueryRowContext(ctx, stmt, email)
 
-	err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.Created, &u.Updated)
+	err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -95,7 +107,7 @@ func (m UserModel) Insert(dto *CreateUserDTO) (*User, error) {
 
 	stmt := `INSERT INTO users (email, first_name, last_name, hashed_password, created, updated)
 	VALUES($1, $2, $3, $4, DEFAULT, DEFAULT)
-	RETURNING *`
+	RETURNING id, email, first_name, last_name, hashed_password, locked_until, is_guest, role, disabled, created, updated`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -105,7 +117,7 @@ func (m UserModel) Insert(dto *CreateUserDTO) (*User, error) {
 
 	rows := m.DB.QueryRowContext(ctx, stmt, args...)
 
-	err = rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.Created, &u.Updated)
+	err = rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -119,7 +131,7 @@ func (m UserModel) Insert(dto *CreateUserDTO) (*User, error) {
 }
 
 func (m UserModel) Authenticate(creds *Credentials) (*User, error) {
-	stmt := `SELECT id, email, first_name, last_name, hashed_password, created, updated FROM users WHERE email = $1`
+	stmt := `SELECT id, email, first_name, last_name, hashed_password, locked_until, is_guest, role, disabled, created, updated FROM users WHERE lower(email) = lower($1)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -127,7 +139,7 @@ func (m UserModel) Authenticate(creds *Credentials) (*User, error) {
 	u := User{}
 
 	row := m.DB.QueryRowContext(ctx, stmt, creds.Email)
-	if err := row.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.Created, &u.Updated); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrInvalidCredentials
 		} else {
@@ -135,6 +147,18 @@ func (m UserModel) Authenticate(creds *Credentials) (*User, error) {
 		}
 	}
 
+	if u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		return nil, ErrAccountLocked
+	}
+
+	if u.HashedPassword == noPassword {
+		return nil, ErrInvalidCredentials
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(u.HashedPassword), []byte(creds.Password))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
@@ -149,13 +173,14 @@ func (m UserModel) Authenticate(creds *Credentials) (*User, error) {
 func (m UserModel) GetByToken(scope string, tokenText string) (*User, error) {
 	hash := sha256.Sum256([]byte(tokenText))
 
-	stmt := `SELECT users.id, users.email, users.first_name, users.last_name, users.hashed_password, users.created, users.updated
+	stmt := `SELECT users.id, users.email, users.first_name, users.last_name, users.hashed_password, users.locked_until, users.is_guest, users.role, users.disabled, users.created, users.updated
 	FROM users
 	INNER JOIN tokens
 	ON users.id = tokens.user_id
 	WHERE tokens.hash = $1
 	AND tokens.scope = $2
-	AND tokens.expiry > $3`
+	AND tokens.expiry > $3
+	AND NOT users.disabled`
 
 	u := &User{}
 
@@ -166,7 +191,7 @@ func (m UserModel) GetByToken(scope string, tokenText string) (*User, error) {
 
 	rows := m.DB.QueryRowContext(ctx, stmt, args...)
 
-	err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.Created, &u.Updated)
+	err := rows.Scan(&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -176,3 +201,228 @@ func (m UserModel) GetByToken(scope string, tokenText string) (*User, error) {
 
 	return u, nil
 }
+
+func (m UserModel) Lock(id int, until time.Time) error {
+	stmt := `UPDATE users SET locked_until = $1, updated = now() WHERE users.id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, until, id)
+	return err
+}
+
+func (m UserModel) Unlock(id int) error {
+	stmt := `UPDATE users SET locked_until = NULL, updated = now() WHERE users.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// noPassword is stored in place of a hash for users who can't log in with a
+// password. It is never produced by bcrypt, so no password matches it.
+const noPassword = "!"
+
+// InsertGuest creates a guest account with some example folders and tasks.
+// Guests have no password, so their sessions can't be resumed by logging in.
+func (m UserModel) InsertGuest() (*User, error) {
+	randomBytes := make([]byte, 8)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	email := fmt.Sprintf("guest-%s@guest.go-todo.local", hex.EncodeToString(randomBytes))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	u := &User{}
+
+	err = withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO users (email, first_name, last_name, hashed_password, is_guest, created, updated)
+		VALUES ($1, 'Guest', 'User', $2, true, DEFAULT, DEFAULT)
+		RETURNING id, email, first_name, last_name, hashed_password, locked_until, is_guest, role, disabled, created, updated`
+
+		err := tx.QueryRowContext(ctx, stmt, email, noPassword).Scan(
+			&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated,
+		)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		folderPositions := evenPositions(len(guestFolders))
+
+		for i, gf := range guestFolders {
+			var folderID int
+
+			stmt := `INSERT INTO folders (name, user_id, position) VALUES ($1, $2, $3) RETURNING id`
+
+			err := tx.QueryRowContext(ctx, stmt, gf.name, u.ID, folderPositions[i]).Scan(&folderID)
+			if err != nil {
+				return err
+			}
+
+			taskPositions := evenPositions(len(gf.tasks))
+
+			for j, gt := range gf.tasks {
+				stmt := `INSERT INTO tasks (title, description, status, datetime, folder_id, position)
+				VALUES ($1, $2, $3, $4, $5, $6)`
+
+				args := []interface{}{gt.title, gt.description, gt.status, now.Add(gt.offset).UTC(), folderID, taskPositions[j]}
+
+				_, err := tx.ExecContext(ctx, stmt, args...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (m UserModel) Upgrade(id int, dto *CreateUserDTO) (*User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(dto.Password), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `UPDATE users
+	SET email = $1, first_name = $2, last_name = $3, hashed_password = $4, is_guest = false, updated = now()
+	WHERE users.id = $5 AND users.is_guest
+	RETURNING id, email, first_name, last_name, hashed_password, locked_until, is_guest, role, disabled, created, updated`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	u := &User{}
+	args := []interface{}{dto.Email, dto.FirstName, dto.LastName, string(hashedPassword), id}
+
+	err = m.DB.QueryRowContext(ctx, stmt, args...).Scan(
+		&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecord
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return nil, ErrDuplicateEmail
+		default:
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+func (m UserModel) Touch(id int) error {
+	stmt := `UPDATE users SET last_active = now() WHERE users.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+func (m UserModel) DeleteInactiveGuests(before time.Time) (int64, error) {
+	stmt := `DELETE FROM users WHERE users.is_guest AND users.last_active < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, stmt, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (m UserModel) Search(query, role string, filters Filters) ([]*User, MetaData, error) {
+	stmt := fmt.Sprintf(`SELECT count(*) OVER(),
+	id, email, first_name, last_name, hashed_password, locked_until, is_guest, role, disabled, created, updated
+	FROM users
+	WHERE (email ILIKE '%%' || $1 || '%%' OR first_name ILIKE '%%' || $1 || '%%' OR last_name ILIKE '%%' || $1 || '%%' OR $1 = '')
+	AND (role = $2 OR $2 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $3 OFFSET $4`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := []interface{}{query, role, filters.Limit(), filters.Offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, MetaData{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*User{}
+
+	for rows.Next() {
+		u := &User{}
+		err := rows.Scan(
+			&totalRecords,
+			&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.HashedPassword, &u.LockedUntil, &u.IsGuest, &u.Role, &u.Disabled, &u.Created, &u.Updated,
+		)
+		if err != nil {
+			return nil, MetaData{}, err
+		}
+		users = append(users, u)
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return users, metadata, nil
+}
+
+func (m UserModel) SetDisabled(id int, disabled bool) error {
+	stmt := `UPDATE users SET disabled = $1, updated = now() WHERE users.id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, stmt, disabled, id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoRecord
+	}
+
+	return err
+}
+
+func (m UserModel) SetRole(id int, role string) error {
+	stmt := `UPDATE users SET role = $1, updated = now() WHERE users.id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, stmt, role, id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoRecord
+	}
+
+	return err
+}
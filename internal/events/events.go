@@ -0,0 +1,73 @@
+package events
+
+import "sync"
+
+const (
+	TaskCompleted = "task.completed"
+	TaskCancelled = "task.cancelled"
+	TaskUnblocked = "task.unblocked"
+)
+
+type Event struct {
+	Type   string
+	UserID int
+	TaskID int
+}
+
+type Handler func(Event)
+
+// Bus is an in-process publish/subscribe hub. Handlers run synchronously on
+// the publishing goroutine, so anything slow should hand off its own work.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+
+	parent *Bus
+	queue  []Event
+}
+
+func New() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+func (b *Bus) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+func (b *Bus) Publish(e Event) {
+	if b.parent != nil {
+		b.mu.Lock()
+		b.queue = append(b.queue, e)
+		b.mu.Unlock()
+		return
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers[e.Type]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
+
+// Deferred returns a bus that holds on to published events until Flush is
+// called, for work whose effects may yet be rolled back.
+func (b *Bus) Deferred() *Bus {
+	return &Bus{handlers: make(map[string][]Handler), parent: b}
+}
+
+// Flush publishes the queued events to the parent bus.
+func (b *Bus) Flush() {
+	b.mu.Lock()
+	queue := b.queue
+	b.queue = nil
+	b.mu.Unlock()
+
+	for _, e := range queue {
+		b.parent.Publish(e)
+	}
+}
@@ -0,0 +1,311 @@
+// Package filter parses filter expressions such as
+//
+//	status = important AND (datetime < today+7d OR NOT tag in (work, home))
+//
+// into a syntax tree. It knows nothing about which fields exist or what
+// their values mean; that is up to whoever compiles the tree.
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxLength is the longest expression Parse accepts.
+	MaxLength = 2000
+	// MaxDepth is how deeply NOT and parentheses may be nested.
+	MaxDepth = 20
+)
+
+const (
+	OpEq       = "="
+	OpNe       = "!="
+	OpLt       = "<"
+	OpLe       = "<="
+	OpGt       = ">"
+	OpGe       = ">="
+	OpContains = "~"
+	OpIn       = "in"
+)
+
+// Expr is a node of the syntax tree: an *And, *Or, *Not or *Comparison.
+// String formats it back into an expression that parses to the same tree.
+type Expr interface {
+	String() string
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+}
+
+type Not struct {
+	X Expr
+}
+
+// Comparison compares a field with one value, or several for OpIn.
+type Comparison struct {
+	Field  string
+	Op     string
+	Values []string
+}
+
+func (e *And) String() string {
+	return group(e.Left, precAnd) + " AND " + group(e.Right, precAnd+1)
+}
+
+func (e *Or) String() string {
+	return group(e.Left, precOr) + " OR " + group(e.Right, precOr+1)
+}
+
+func (e *Not) String() string {
+	return "NOT " + group(e.X, precNot)
+}
+
+func (e *Comparison) String() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = quote(v)
+	}
+
+	if e.Op == OpIn {
+		return e.Field + " in (" + strings.Join(values, ", ") + ")"
+	}
+
+	return e.Field + " " + e.Op + " " + values[0]
+}
+
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+)
+
+// group formats e, in parentheses if it binds less tightly than prec.
+func group(e Expr, prec int) string {
+	p := precNot
+	switch e.(type) {
+	case *Or:
+		p = precOr
+	case *And:
+		p = precAnd
+	}
+
+	if p < prec {
+		return "(" + e.String() + ")"
+	}
+
+	return e.String()
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// SyntaxError reports where an expression could not be parsed.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at character %d", e.Msg, e.Pos+1)
+}
+
+// Parse parses an expression. AND binds more tightly than OR, and NOT more
+// tightly than both.
+func Parse(s string) (Expr, error) {
+	if len(s) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Msg: fmt.Sprintf("expression is longer than %d characters", MaxLength)}
+	}
+
+	p := &parser{lex: lexer{src: s}}
+	p.next()
+
+	e, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lexer reports errors as the end of the expression.
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	return e, nil
+}
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err == nil {
+		p.tok, p.err = p.lex.next()
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) keyword(kw string) bool {
+	return p.tok.kind == tokWord && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("OR") {
+		p.next()
+
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("AND") {
+		p.next()
+
+		right, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot(depth int) (Expr, error) {
+	if depth >= MaxDepth {
+		return nil, p.errorf("expression is nested more than %d deep", MaxDepth)
+	}
+
+	switch {
+	case p.keyword("NOT"):
+		p.next()
+
+		x, err := p.parseNot(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	case p.tok.kind == tokLParen:
+		p.next()
+
+		e, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ) but found %s", p.tok)
+		}
+		p.next()
+
+		return e, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	if p.tok.kind != tokWord || !isField(p.tok.text) || p.keyword("AND") || p.keyword("OR") {
+		return nil, p.errorf("expected a field but found %s", p.tok)
+	}
+
+	c := &Comparison{Field: strings.ToLower(p.tok.text)}
+	p.next()
+
+	switch {
+	case p.tok.kind == tokOp:
+		c.Op = p.tok.text
+		p.next()
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		c.Values = []string{v}
+	case p.keyword(OpIn):
+		c.Op = OpIn
+		p.next()
+
+		if p.tok.kind != tokLParen {
+			return nil, p.errorf("expected ( but found %s", p.tok)
+		}
+		p.next()
+
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			c.Values = append(c.Values, v)
+
+			if p.tok.kind != tokComma {
+				break
+			}
+			p.next()
+		}
+
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected , or ) but found %s", p.tok)
+		}
+		p.next()
+	default:
+		return nil, p.errorf("expected an operator but found %s", p.tok)
+	}
+
+	return c, nil
+}
+
+func (p *parser) parseValue() (string, error) {
+	if p.tok.kind != tokWord && p.tok.kind != tokString {
+		return "", p.errorf("expected a value but found %s", p.tok)
+	}
+
+	v := p.tok.text
+	p.next()
+
+	return v, nil
+}
+
+func isField(s string) bool {
+	for i, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{"Comparison", "status = important", `status = "important"`, ""},
+		{"Precedence", "a = 1 OR b = 2 AND NOT c = 3", `a = "1" OR b = "2" AND NOT c = "3"`, ""},
+		{"Grouping", "(a = 1 OR b = 2) AND c = 3", `(a = "1" OR b = "2") AND c = "3"`, ""},
+		{"Right grouping", "a = 1 AND (b = 2 AND c = 3)", `a = "1" AND (b = "2" AND c = "3")`, ""},
+		{"Not group", "NOT (a = 1 OR b = 2)", `NOT (a = "1" OR b = "2")`, ""},
+		{"Keywords", "a=1 and not b!=2 or c<=3", `a = "1" AND NOT b != "2" OR c <= "3"`, ""},
+		{"In", "folder_id in (1, 2,3)", `folder_id in ("1", "2", "3")`, ""},
+		{"Quoted", `title ~ "say \"hi\" \\ bye"`, `title ~ "say \"hi\" \\ bye"`, ""},
+		{"Dates", "datetime >= 2030-01-01T09:00:00Z AND datetime < today+7d", `datetime >= "2030-01-01T09:00:00Z" AND datetime < "today+7d"`, ""},
+		{"Field case", "Title = x", `title = "x"`, ""},
+		{"Empty", "", "", "expected a field but found end of expression at character 1"},
+		{"Missing value", "a =", "", "expected a value but found end of expression at character 4"},
+		{"Missing operator", "a 1", "", `expected an operator but found "1" at character 3`},
+		{"Unbalanced", "(a = 1", "", "expected ) but found end of expression at character 7"},
+		{"Trailing", "a = 1 b = 2", "", `unexpected "b" at character 7`},
+		{"Bad character", "a = 1 AND b = $", "", `unexpected '$' at character 15`},
+		{"Bad character at end", "a = 1 $", "", `unexpected '$' at character 7`},
+		{"Bang", "a ! 1", "", `unexpected "!" at character 3`},
+		{"Unterminated", `a = "x`, "", "unterminated string at character 5"},
+		{"Empty in", "a in ()", "", `expected a value but found ")" at character 7`},
+		{"Value as field", "1 = a", "", `expected a field but found "1" at character 1`},
+		{"Too deep", strings.Repeat("(", MaxDepth) + "a = 1" + strings.Repeat(")", MaxDepth), "", "nested more than"},
+		{"Too long", "a = " + strings.Repeat("x", MaxLength), "", "longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+
+			if tt.wantErr != "" {
+				var se *SyntaxError
+				if !errors.As(err, &se) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want error %q; got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want no error; got %v", err)
+			}
+
+			if got := e.String(); got != tt.want {
+				t.Errorf("want %s; got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{
+		"status = important",
+		`title ~ "invoice" AND NOT folder_id in (1, 2)`,
+		"(datetime >= today AND datetime < today+7d) OR tag = work",
+		`a = "\"\\"`,
+		"NOT NOT (a != 1)",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		e, err := Parse(s)
+		if err != nil {
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("want a *SyntaxError; got %T", err)
+			}
+			return
+		}
+
+		// Formatting the tree must give an expression that parses back to
+		// the same tree.
+		out := e.String()
+		if len(out) > MaxLength {
+			return
+		}
+
+		e2, err := Parse(out)
+		if err != nil {
+			t.Fatalf("parsing %q formatted as %q: %v", s, out, err)
+		}
+
+		if !reflect.DeepEqual(e, e2) {
+			t.Fatalf("%q formatted as %q parses to %s", s, out, e2)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	tokEOF = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+
+	return strconv.Quote(t.text)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// isWordByte reports whether b can appear in an unquoted value, which
+// covers identifiers, numbers, dates and times like 2030-01-01T09:00:00Z
+// and relative dates like today+7d.
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte("_-+.:", b) >= 0
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+
+	start := l.pos
+	if start == len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	switch c := l.src[start]; {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}, nil
+	case c == ',':
+		l.pos++
+		return token{kind: tokComma, text: ",", pos: start}, nil
+	case c == '"':
+		return l.quoted()
+	case strings.IndexByte("=!<>~", c) >= 0:
+		op := l.src[start : start+1]
+		if start+1 < len(l.src) && l.src[start+1] == '=' && c != '=' && c != '~' {
+			op = l.src[start : start+2]
+		}
+		if op == "!" {
+			return token{}, &SyntaxError{Pos: start, Msg: "unexpected \"!\""}
+		}
+		l.pos += len(op)
+		return token{kind: tokOp, text: op, pos: start}, nil
+	case isWordByte(c):
+		for l.pos < len(l.src) && isWordByte(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokWord, text: l.src[start:l.pos], pos: start}, nil
+	default:
+		r, _ := utf8.DecodeRuneInString(l.src[start:])
+		return token{}, &SyntaxError{Pos: start, Msg: "unexpected " + strconv.QuoteRune(r)}
+	}
+}
+
+// quoted reads a double-quoted string, in which \" and \\ stand for " and \.
+func (l *lexer) quoted() (token, error) {
+	start := l.pos
+	l.pos++
+
+	var b strings.Builder
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return token{kind: tokString, text: b.String(), pos: start}, nil
+		case c == '\\' && l.pos+1 < len(l.src) && (l.src[l.pos+1] == '"' || l.src[l.pos+1] == '\\'):
+			b.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+
+	return token{}, &SyntaxError{Pos: start, Msg: "unterminated string"}
+}
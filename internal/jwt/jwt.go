@@ -12,7 +12,9 @@ type Service struct {
 }
 
 type UserClaims struct {
-	UserID int `json:"user_id"`
+	UserID         int    `json:"user_id"`
+	Role           string `json:"role,omitempty"`
+	ImpersonatorID int    `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -26,15 +28,12 @@ func NewService(secret []byte) *Service {
 	}
 }
 
-func (j *Service) Sign(id int, expires time.Time) (string, error) {
-	claims := &UserClaims{
-		UserID: id,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expires),
-		},
+func (j *Service) Sign(claims UserClaims, expires time.Time) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expires),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
 	ret, err := token.SignedString(j.Secret)
 	if err != nil {
 		return "", err
@@ -21,15 +21,25 @@ func (j *JWTService) Parse(tokenStr string) (*jwt.UserClaims, error) {
 		UserID: 1,
 	}
 	claims.ExpiresAt = goJwt.NewNumericDate(time.Now().Add(24 * time.Hour))
-	if tokenStr == "123" {
+	switch tokenStr {
+	case "123":
 		claims.UserID = 1
-	} else {
+		claims.Role = "user"
+	case "admin":
+		claims.UserID = 3
+		claims.Role = "admin"
+	case "impersonated-admin":
+		claims.UserID = 3
+		claims.Role = "admin"
+		claims.ImpersonatorID = 4
+	default:
 		claims.UserID = 2
+		claims.Role = "user"
 	}
 
 	return &claims, nil
 }
 
-func (j *JWTService) Sign(id int, expires time.Time) (string, error) {
+func (j *JWTService) Sign(claims jwt.UserClaims, expires time.Time) (string, error) {
 	return "123", nil
 }
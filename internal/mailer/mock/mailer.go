@@ -0,0 +1,7 @@
+package mock
+
+type Mailer struct{}
+
+func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
+	return nil
+}